@@ -26,6 +26,10 @@ func HandleUserCommand(args []string) {
 		changePassword(args[1:])
 	case "list":
 		listUsers(args[1:])
+	case "reset-2fa":
+		resetTwoFactor(args[1:])
+	case "unlock":
+		unlockUser(args[1:])
 	case "help", "-h", "--help":
 		printUserUsage()
 	default:
@@ -44,12 +48,16 @@ func printUserUsage() {
 	fmt.Println("Available Subcommands:")
 	fmt.Println("  change-password   Change a user's password")
 	fmt.Println("  list              List all users")
+	fmt.Println("  reset-2fa         Disable two-factor authentication for a locked out user")
+	fmt.Println("  unlock            Unlock an account locked after failed logins")
 	fmt.Println("  help              Show this help message")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  mist-cli user change-password --username admin")
 	fmt.Println("  mist-cli user change-password --username admin --password newpass123")
 	fmt.Println("  mist-cli user list")
+	fmt.Println("  mist-cli user reset-2fa --username admin")
+	fmt.Println("  mist-cli user unlock --username admin")
 }
 
 func changePassword(args []string) {
@@ -127,6 +135,90 @@ func changePassword(args []string) {
 	fmt.Printf("✓ Password changed successfully for user '%s'\n", *username)
 }
 
+func resetTwoFactor(args []string) {
+	fs := flag.NewFlagSet("reset-2fa", flag.ExitOnError)
+	username := fs.String("username", "", "Username (required)")
+	yes := fs.Bool("yes", false, "Skip confirmation")
+	fs.Parse(args)
+
+	if *username == "" {
+		fmt.Println("Error: --username is required")
+		fmt.Println()
+		printUserUsage()
+		os.Exit(1)
+	}
+
+	if err := initDB(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	user, err := models.GetUserByUsername(*username)
+	if err != nil {
+		fmt.Printf("Error: User '%s' not found\n", *username)
+		os.Exit(1)
+	}
+
+	if !user.TwoFactorEnabled && user.TwoFactorSecret == nil {
+		fmt.Printf("Two-factor authentication is not enabled for user '%s'\n", *username)
+		return
+	}
+
+	if !*yes && !promptConfirm(fmt.Sprintf("Disable two-factor authentication for '%s'?", *username)) {
+		fmt.Println("Aborted")
+		return
+	}
+
+	if err := models.DisableTwoFactor(user.ID); err != nil {
+		fmt.Printf("Error resetting two-factor authentication: %v\n", err)
+		os.Exit(1)
+	}
+
+	models.LogAudit(nil, "reset_2fa", "user", &user.ID, map[string]interface{}{
+		"username":     user.Username,
+		"trigger_type": "cli",
+	})
+
+	fmt.Printf("✓ Two-factor authentication disabled for user '%s', they can enable it again after logging in\n", *username)
+}
+
+func unlockUser(args []string) {
+	fs := flag.NewFlagSet("unlock", flag.ExitOnError)
+	username := fs.String("username", "", "Username (required)")
+	fs.Parse(args)
+
+	if *username == "" {
+		fmt.Println("Error: --username is required")
+		fmt.Println()
+		printUserUsage()
+		os.Exit(1)
+	}
+
+	if err := initDB(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	user, err := models.GetUserByUsername(*username)
+	if err != nil {
+		fmt.Printf("Error: User '%s' not found\n", *username)
+		os.Exit(1)
+	}
+
+	if err := models.UnlockUser(user.ID); err != nil {
+		fmt.Printf("Error unlocking user: %v\n", err)
+		os.Exit(1)
+	}
+
+	models.LogAudit(nil, "unlock", "user", &user.ID, map[string]interface{}{
+		"username":        user.Username,
+		"failed_attempts": user.FailedLoginAttempts,
+		"trigger_type":    "cli",
+	})
+
+	fmt.Printf("✓ User '%s' unlocked\n", *username)
+}
+
 func listUsers(args []string) {
 	if err := initDB(); err != nil {
 		fmt.Printf("Error: %v\n", err)
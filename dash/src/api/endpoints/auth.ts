@@ -1,5 +1,5 @@
 import { apiClient, type ApiResponse } from "..";
-import type { User } from "@/types";
+import type { TwoFactorChallenge, User } from "@/types";
 
 
 
@@ -10,8 +10,12 @@ export const authApi = {
     return apiClient.get('/auth/me');
   },
 
-  async login(email: string, password: string): Promise<ApiResponse<User>> {
-    return apiClient.post<User>('/auth/login', { email, password });
+  async login(email: string, password: string): Promise<ApiResponse<User | TwoFactorChallenge>> {
+    return apiClient.post<User | TwoFactorChallenge>('/auth/login', { email, password });
+  },
+
+  async loginTwoFactor(challenge: string, code: string): Promise<ApiResponse<User>> {
+    return apiClient.post<User>('/auth/login/2fa', { challenge, code });
   },
 
   async signup(email: string, password: string, username: string): Promise<ApiResponse<User>> {
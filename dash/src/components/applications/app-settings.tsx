@@ -19,6 +19,7 @@ export const AppSettings = ({ app, onUpdate }: AppSettingsProps) => {
   const [startCommand, setStartCommand] = useState(app.startCommand || "");
   const [rootDirectory, setRootDirectory] = useState(app.rootDirectory || "");
   const [dockerfilePath, setDockerfilePath] = useState(app.dockerfilePath || "");
+  const [outputDirectory, setOutputDirectory] = useState(app.outputDirectory || "");
   const [healthcheckPath, setHealthcheckPath] = useState(app.healthcheckPath || "");
   const [cpuLimit, setCpuLimit] = useState(app.cpuLimit?.toString() || "");
   const [memoryLimit, setMemoryLimit] = useState(app.memoryLimit?.toString() || "");
@@ -33,6 +34,7 @@ export const AppSettings = ({ app, onUpdate }: AppSettingsProps) => {
       const updates: Partial<{
         rootDirectory: string;
         dockerfilePath: string | null;
+        outputDirectory: string;
         buildCommand: string | null;
         startCommand: string | null;
         healthcheckPath: string | null;
@@ -44,6 +46,7 @@ export const AppSettings = ({ app, onUpdate }: AppSettingsProps) => {
       }> = {
         rootDirectory,
         dockerfilePath: dockerfilePath || null,
+        outputDirectory,
         buildCommand: buildCommand || null,
         startCommand: startCommand || null,
         healthcheckPath: healthcheckPath || null,
@@ -242,6 +245,22 @@ export const AppSettings = ({ app, onUpdate }: AppSettingsProps) => {
             </p>
           </div>
 
+          <div className="space-y-2">
+            <Label htmlFor="outputDirectory">Output Directory</Label>
+            <Input
+              id="outputDirectory"
+              placeholder="dist"
+              value={outputDirectory}
+              onChange={(e) => setOutputDirectory(e.target.value)}
+              disabled={app.appType === 'database'}
+            />
+            <p className="text-sm text-muted-foreground">
+              {app.appType === 'database'
+                ? 'Not applicable for database apps'
+                : 'Directory served for static sites without a Dockerfile (optional, detects dist, build, out or public)'}
+            </p>
+          </div>
+
           <div className="space-y-2">
             <Label htmlFor="healthcheckPath">Health Check Path</Label>
             <Input
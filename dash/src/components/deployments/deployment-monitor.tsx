@@ -141,6 +141,11 @@ export const DeploymentMonitor = ({ deploymentId, open, onClose, onComplete }: P
               <span className="text-sm text-muted-foreground">
                 {status.message}
               </span>
+              {status.health && status.health !== 'none' && (
+                <Badge variant="outline" className="text-xs px-2 py-0.5">
+                  {status.health}
+                </Badge>
+              )}
             </div>
 
             {/* Progress Bar */}
@@ -11,6 +11,7 @@ import { Loader2, Eye, EyeOff } from "lucide-react"
 import { useAuth } from "@/providers"
 import { toast } from "sonner"
 import { authApi } from "@/api/endpoints/auth"
+import { isTwoFactorChallenge } from "@/types"
 
 export function LoginForm({
   className,
@@ -20,6 +21,9 @@ export function LoginForm({
   const [error, setError] = useState<string | null>(null)
   const [isLoading, setIsLoading] = useState(false)
   const [showPassword, setShowPassword] = useState(false)
+  // set once the password was accepted for a user with two factor enabled
+  const [challenge, setChallenge] = useState<string | null>(null)
+  const [code, setCode] = useState("")
   const { setUser
   } = useAuth()
   const handleChange = (e: React.ChangeEvent<HTMLInputElement>) => {
@@ -31,16 +35,41 @@ export function LoginForm({
     event.preventDefault();
     setIsLoading(true);
     setError(null);
+    if (challenge) {
+      const res = await authApi.loginTwoFactor(challenge, code)
+      if (res.success) {
+        setUser(res.data)
+        toast.success("Logged in successfully!")
+      } else {
+        // a challenge only allows one attempt, so any failure needs the password again
+        setChallenge(null)
+        setCode("")
+        setError(res.message || "An error occurred during login.")
+      }
+      setIsLoading(false);
+      return
+    }
+
     const res = await authApi.login(formData.email, formData.password)
     if (res.success) {
-      setUser(res.data)
-      toast.success("Logged in successfully!")
+      if (isTwoFactorChallenge(res.data)) {
+        setChallenge(res.data.challenge)
+      } else {
+        setUser(res.data)
+        toast.success("Logged in successfully!")
+      }
     } else {
       setError(res.message || "An error occurred during login.")
     }
     setIsLoading(false);
   };
 
+  const cancelTwoFactor = () => {
+    setChallenge(null)
+    setCode("")
+    setError(null)
+  }
+
 
   return (
     <form onSubmit={handleSubmit} className={cn("flex flex-col gap-6", className)} {...props}>
@@ -48,7 +77,9 @@ export function LoginForm({
         <div className="flex flex-col gap-2 text-center">
           <h1 className="text-3xl font-bold tracking-tight">Welcome back</h1>
           <p className="text-sm text-muted-foreground">
-            Enter your credentials to access your account
+            {challenge
+              ? "Enter the code from your authenticator app or a backup code"
+              : "Enter your credentials to access your account"}
           </p>
         </div>
 
@@ -58,61 +89,103 @@ export function LoginForm({
           </Alert>
         )}
 
-        <div className="flex flex-col gap-4">
-          <Field>
-            <FieldLabel htmlFor="email">Email</FieldLabel>
-            <Input
-              id="email"
-              type="email"
-              placeholder="name@example.com"
-              value={formData.email}
-              onChange={handleChange}
-              required
-              className="h-11"
-            />
-          </Field>
+        {challenge ? (
+          <div className="flex flex-col gap-4">
+            <Field>
+              <FieldLabel htmlFor="code">Authentication code</FieldLabel>
+              <Input
+                id="code"
+                inputMode="numeric"
+                autoComplete="one-time-code"
+                placeholder="123456"
+                value={code}
+                onChange={(e) => setCode(e.target.value)}
+                required
+                autoFocus
+                className="h-11"
+              />
+            </Field>
 
-          <Field>
-            <FieldLabel htmlFor="password">Password</FieldLabel>
-            <div className="relative">
+            <Button
+              type="submit"
+              disabled={isLoading}
+              className="w-full h-11 text-base font-medium"
+            >
+              {isLoading ? (
+                <>
+                  <Loader2 className="mr-2 h-4 w-4 animate-spin" />
+                  Verifying...
+                </>
+              ) : (
+                "Verify"
+              )}
+            </Button>
+            <Button
+              type="button"
+              variant="ghost"
+              onClick={cancelTwoFactor}
+              disabled={isLoading}
+            >
+              Back to sign in
+            </Button>
+          </div>
+        ) : (
+          <div className="flex flex-col gap-4">
+            <Field>
+              <FieldLabel htmlFor="email">Email</FieldLabel>
               <Input
-                id="password"
-                type={showPassword ? "text" : "password"}
-                value={formData.password}
+                id="email"
+                type="email"
+                placeholder="name@example.com"
+                value={formData.email}
                 onChange={handleChange}
                 required
-                className="pr-10 h-11"
+                className="h-11"
               />
-              <button
-                type="button"
-                onClick={() => setShowPassword(!showPassword)}
-                className="absolute right-3 top-1/2 -translate-y-1/2 text-muted-foreground hover:text-foreground transition-colors"
-                tabIndex={-1}
-              >
-                {showPassword ? (
-                  <EyeOff className="h-4 w-4" />
-                ) : (
-                  <Eye className="h-4 w-4" />
-                )}
-              </button>
-            </div>
-          </Field>
+            </Field>
 
-          <Button
-            type="submit"
-            disabled={isLoading}
-            className="w-full h-11 text-base font-medium"
-          >
-            {isLoading ? (
-              <>
-                <Loader2 className="mr-2 h-4 w-4 animate-spin" />
-                Signing in...
-              </>
-            ) : (
-              "Sign in"
-            )}
-          </Button>
-        </div>
+            <Field>
+              <FieldLabel htmlFor="password">Password</FieldLabel>
+              <div className="relative">
+                <Input
+                  id="password"
+                  type={showPassword ? "text" : "password"}
+                  value={formData.password}
+                  onChange={handleChange}
+                  required
+                  className="pr-10 h-11"
+                />
+                <button
+                  type="button"
+                  onClick={() => setShowPassword(!showPassword)}
+                  className="absolute right-3 top-1/2 -translate-y-1/2 text-muted-foreground hover:text-foreground transition-colors"
+                  tabIndex={-1}
+                >
+                  {showPassword ? (
+                    <EyeOff className="h-4 w-4" />
+                  ) : (
+                    <Eye className="h-4 w-4" />
+                  )}
+                </button>
+              </div>
+            </Field>
+
+            <Button
+              type="submit"
+              disabled={isLoading}
+              className="w-full h-11 text-base font-medium"
+            >
+              {isLoading ? (
+                <>
+                  <Loader2 className="mr-2 h-4 w-4 animate-spin" />
+                  Signing in...
+                </>
+              ) : (
+                "Sign in"
+              )}
+            </Button>
+          </div>
+        )}
       </div>
     </form>
   )
@@ -1,4 +1,4 @@
-import type { User } from '@/types';
+import type { TwoFactorChallenge, User } from '@/types';
 
 const API_BASE = '/api';
 
@@ -15,9 +15,9 @@ export interface SignUpRequest {
 
 export const authService = {
   /**
-   * Login user
+   * Login user, users with two factor enabled get a challenge for loginTwoFactor instead
    */
-  async login(credentials: LoginRequest): Promise<User> {
+  async login(credentials: LoginRequest): Promise<User | TwoFactorChallenge> {
     const response = await fetch(`${API_BASE}/auth/login`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
@@ -33,6 +33,25 @@ export const authService = {
     return data.data;
   },
 
+  /**
+   * Complete a login with a TOTP or backup code
+   */
+  async loginTwoFactor(challenge: string, code: string): Promise<User> {
+    const response = await fetch(`${API_BASE}/auth/login/2fa`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      credentials: 'include',
+      body: JSON.stringify({ challenge, code }),
+    });
+
+    const data = await response.json();
+    if (!data.success) {
+      throw new Error(data.error || 'Login failed');
+    }
+
+    return data.data;
+  },
+
   /**
    * Sign up new user
    */
@@ -19,6 +19,7 @@ export type App = {
   rootDirectory: string;
   buildCommand: string | null;
   startCommand: string | null;
+  outputDirectory: string | null;
   dockerfilePath: string | null;
   cpuLimit: number | null;
   memoryLimit: number | null;
@@ -41,5 +41,6 @@ export interface StatusUpdate {
   progress: number;
   message: string;
   error_message?: string;
+  health?: string;
   duration?: number;
 }
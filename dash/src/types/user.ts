@@ -15,6 +15,15 @@ export interface LoginCredentials {
   password: string;
 }
 
+// returned by the login instead of the user when two factor authentication is enabled
+export interface TwoFactorChallenge {
+  twoFactorRequired: true;
+  challenge: string;
+}
+
+export const isTwoFactorChallenge = (data: unknown): data is TwoFactorChallenge =>
+  typeof data === 'object' && data !== null && (data as TwoFactorChallenge).twoFactorRequired === true;
+
 export interface SignupCredentials {
   username: string;
   email: string;
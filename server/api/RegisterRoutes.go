@@ -9,6 +9,7 @@ import (
 	"github.com/corecollectives/mist/api/handlers/auth"
 	"github.com/corecollectives/mist/api/handlers/deployments"
 	"github.com/corecollectives/mist/api/handlers/github"
+	"github.com/corecollectives/mist/api/handlers/notifications"
 	"github.com/corecollectives/mist/api/handlers/projects"
 	"github.com/corecollectives/mist/api/handlers/settings"
 	"github.com/corecollectives/mist/api/handlers/templates"
@@ -16,24 +17,41 @@ import (
 	"github.com/corecollectives/mist/api/handlers/users"
 	"github.com/corecollectives/mist/api/middleware"
 	"github.com/corecollectives/mist/constants"
+	"github.com/corecollectives/mist/models"
 	"github.com/corecollectives/mist/websockets"
 )
 
+// routes passing scopes to AuthMiddleware can also be called with an api token holding one of them.
+// routes taking the id of a row that belongs to an app resolve it, so project limited tokens can be checked
 func RegisterRoutes(mux *http.ServeMux) {
 
+	// per ip limits on the unauthenticated entry points, account lockout covers guessing against a single user
+	authLimit := middleware.RateLimit(middleware.NewRateLimiter(1.0/3, 20))
+	webhookLimit := middleware.RateLimit(middleware.NewRateLimiter(1, 60))
+
 	avatarDir := constants.Constants["AvatarDirPath"].(string)
 	mux.Handle("/uploads/avatar/", http.StripPrefix("/uploads/avatar/", http.FileServer(http.Dir(avatarDir))))
 
 	mux.Handle("/api/ws/stats", middleware.AuthMiddleware()(http.HandlerFunc(websockets.StatWsHandler)))
-	mux.HandleFunc("/api/ws/container/logs", websockets.ContainerLogsHandler)
-	mux.HandleFunc("/api/ws/container/stats", websockets.ContainerStatsHandler)
+	mux.Handle("/api/ws/container/logs", middleware.AuthMiddleware("apps:read")(http.HandlerFunc(websockets.ContainerLogsHandler)))
+	mux.Handle("/api/ws/container/stats", middleware.AuthMiddleware("apps:read")(http.HandlerFunc(websockets.ContainerStatsHandler)))
+	mux.Handle("/api/ws/crons/run", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(websockets.CronRunHandler)))
 	mux.Handle("/api/ws/system/logs", middleware.AuthMiddleware()(http.HandlerFunc(websockets.SystemLogsHandler)))
 	mux.HandleFunc("GET /api/health", handlers.HealthCheckHandler)
 
-	mux.HandleFunc("POST /api/auth/signup", auth.SignUpHandler)
-	mux.HandleFunc("POST /api/auth/login", auth.LoginHandler)
+	mux.Handle("POST /api/auth/signup", authLimit(http.HandlerFunc(auth.SignUpHandler)))
+	mux.Handle("POST /api/auth/login", authLimit(http.HandlerFunc(auth.LoginHandler)))
 	mux.HandleFunc("GET /api/auth/me", auth.MeHandler)
+	mux.Handle("POST /api/auth/login/2fa", authLimit(http.HandlerFunc(auth.LoginTwoFactorHandler)))
 	mux.HandleFunc("POST /api/auth/logout", auth.LogoutHandler)
+	mux.Handle("GET /api/auth/2fa", middleware.AuthMiddleware()(http.HandlerFunc(auth.GetTwoFactorStatus)))
+	mux.Handle("POST /api/auth/2fa/setup", middleware.AuthMiddleware()(http.HandlerFunc(auth.SetupTwoFactor)))
+	mux.Handle("POST /api/auth/2fa/enable", middleware.AuthMiddleware()(http.HandlerFunc(auth.EnableTwoFactor)))
+	mux.Handle("POST /api/auth/2fa/disable", middleware.AuthMiddleware()(http.HandlerFunc(auth.DisableTwoFactor)))
+	mux.Handle("POST /api/auth/2fa/backup-codes", middleware.AuthMiddleware()(http.HandlerFunc(auth.RegenerateBackupCodes)))
+	mux.Handle("GET /api/auth/sessions", middleware.AuthMiddleware()(http.HandlerFunc(auth.GetSessions)))
+	mux.Handle("DELETE /api/auth/sessions/revoke", middleware.AuthMiddleware()(http.HandlerFunc(auth.RevokeSession)))
+	mux.Handle("DELETE /api/auth/sessions/revokeAll", middleware.AuthMiddleware()(http.HandlerFunc(auth.RevokeAllSessions)))
 
 	mux.Handle("POST /api/users/create", middleware.AuthMiddleware()(http.HandlerFunc(users.CreateUser)))
 	mux.Handle("GET /api/users/getAll", middleware.AuthMiddleware()(http.HandlerFunc(users.GetUsers)))
@@ -43,45 +61,72 @@ func RegisterRoutes(mux *http.ServeMux) {
 	mux.Handle("POST /api/users/avatar", middleware.AuthMiddleware()(http.HandlerFunc(users.UploadAvatar)))
 	mux.Handle("DELETE /api/users/avatar", middleware.AuthMiddleware()(http.HandlerFunc(users.DeleteAvatar)))
 	mux.Handle("DELETE /api/users/delete", middleware.AuthMiddleware()(http.HandlerFunc(users.DeleteUser)))
+	mux.Handle("POST /api/users/2fa/reset", middleware.AuthMiddleware()(http.HandlerFunc(users.ResetUserTwoFactor)))
+	mux.Handle("POST /api/users/unlock", middleware.AuthMiddleware()(http.HandlerFunc(users.UnlockUser)))
 	mux.Handle("GET /api/users/git-providers", middleware.AuthMiddleware()(http.HandlerFunc(users.GetUserGitProviders)))
+	mux.Handle("GET /api/users/tokens", middleware.AuthMiddleware()(http.HandlerFunc(users.GetApiTokens)))
+	mux.Handle("POST /api/users/tokens/create", middleware.AuthMiddleware()(http.HandlerFunc(users.CreateApiToken)))
+	mux.Handle("DELETE /api/users/tokens/revoke", middleware.AuthMiddleware()(http.HandlerFunc(users.RevokeApiToken)))
 
 	mux.Handle("POST /api/projects/create", middleware.AuthMiddleware()(http.HandlerFunc(projects.CreateProject)))
-	mux.Handle("GET /api/projects/getAll", middleware.AuthMiddleware()(http.HandlerFunc(projects.GetProjects)))
-	mux.Handle("GET /api/projects/getFromId", middleware.AuthMiddleware()(http.HandlerFunc(projects.GetProjectFromId)))
+	mux.Handle("GET /api/projects/getAll", middleware.AuthMiddleware("projects:read")(http.HandlerFunc(projects.GetProjects)))
+	mux.Handle("GET /api/projects/getFromId", middleware.AuthMiddleware("projects:read")(http.HandlerFunc(projects.GetProjectFromId)))
 	mux.Handle("PUT /api/projects/update", middleware.AuthMiddleware()(http.HandlerFunc(projects.UpdateProject)))
 	mux.Handle("DELETE /api/projects/delete", middleware.AuthMiddleware()(http.HandlerFunc(projects.DeleteProject)))
 	mux.Handle("PUT /api/projects/updateMembers", middleware.AuthMiddleware()(http.HandlerFunc(projects.UpdateMembers)))
-
-	mux.Handle("POST /api/apps/create", middleware.AuthMiddleware()(http.HandlerFunc(applications.CreateApplication)))
-	mux.Handle("POST /api/apps/getByProjectId", middleware.AuthMiddleware()(http.HandlerFunc(applications.GetApplicationByProjectID)))
-	mux.Handle("POST /api/apps/getById", middleware.AuthMiddleware()(http.HandlerFunc(applications.GetApplicationById)))
-	mux.Handle("PUT /api/apps/update", middleware.AuthMiddleware()(http.HandlerFunc(applications.UpdateApplication)))
-	mux.Handle("DELETE /api/apps/delete", middleware.AuthMiddleware()(http.HandlerFunc(applications.DeleteApplication)))
-	mux.Handle("POST /api/apps/getLatestCommit", middleware.AuthMiddleware()(http.HandlerFunc(applications.GetLatestCommit)))
-	mux.Handle("POST /api/apps/getPreviewUrl", middleware.AuthMiddleware()(http.HandlerFunc(applications.GetPreviewURL)))
-
-	mux.Handle("POST /api/apps/envs/create", middleware.AuthMiddleware()(http.HandlerFunc(applications.CreateEnvVariable)))
-	mux.Handle("POST /api/apps/envs/get", middleware.AuthMiddleware()(http.HandlerFunc(applications.GetEnvVariables)))
-	mux.Handle("PUT /api/apps/envs/update", middleware.AuthMiddleware()(http.HandlerFunc(applications.UpdateEnvVariable)))
-	mux.Handle("DELETE /api/apps/envs/delete", middleware.AuthMiddleware()(http.HandlerFunc(applications.DeleteEnvVariable)))
-
-	mux.Handle("POST /api/apps/domains/create", middleware.AuthMiddleware()(http.HandlerFunc(applications.CreateDomain)))
-	mux.Handle("POST /api/apps/domains/get", middleware.AuthMiddleware()(http.HandlerFunc(applications.GetDomains)))
-	mux.Handle("PUT /api/apps/domains/update", middleware.AuthMiddleware()(http.HandlerFunc(applications.UpdateDomain)))
-	mux.Handle("DELETE /api/apps/domains/delete", middleware.AuthMiddleware()(http.HandlerFunc(applications.DeleteDomain)))
-	mux.Handle("POST /api/apps/domains/verify", middleware.AuthMiddleware()(http.HandlerFunc(applications.VerifyDomainDNS)))
-	mux.Handle("POST /api/apps/domains/instructions", middleware.AuthMiddleware()(http.HandlerFunc(applications.GetDNSInstructions)))
-
-	mux.Handle("POST /api/apps/volumes/get", middleware.AuthMiddleware()(http.HandlerFunc(applications.GetVolumes)))
-	mux.Handle("POST /api/apps/volumes/create", middleware.AuthMiddleware()(http.HandlerFunc(applications.CreateVolume)))
-	mux.Handle("PUT /api/apps/volumes/update", middleware.AuthMiddleware()(http.HandlerFunc(applications.UpdateVolume)))
-	mux.Handle("DELETE /api/apps/volumes/delete", middleware.AuthMiddleware()(http.HandlerFunc(applications.DeleteVolume)))
-
-	mux.Handle("POST /api/apps/container/stop", middleware.AuthMiddleware()(http.HandlerFunc(applications.StopContainerHandler)))
-	mux.Handle("POST /api/apps/container/start", middleware.AuthMiddleware()(http.HandlerFunc(applications.StartContainerHandler)))
-	mux.Handle("POST /api/apps/container/restart", middleware.AuthMiddleware()(http.HandlerFunc(applications.RestartContainerHandler)))
-	mux.Handle("GET /api/apps/container/status", middleware.AuthMiddleware()(http.HandlerFunc(applications.GetContainerStatusHandler)))
-	mux.Handle("GET /api/apps/container/logs", middleware.AuthMiddleware()(http.HandlerFunc(applications.GetContainerLogsHandler)))
+	mux.Handle("GET /api/projects/registries", middleware.AuthMiddleware("projects:read")(http.HandlerFunc(projects.GetRegistries)))
+	mux.Handle("POST /api/projects/registries/create", middleware.AuthMiddleware()(http.HandlerFunc(projects.CreateRegistry)))
+	mux.Handle("PUT /api/projects/registries/update", middleware.AuthMiddleware()(http.HandlerFunc(projects.UpdateRegistry)))
+	mux.Handle("DELETE /api/projects/registries/delete", middleware.AuthMiddleware()(http.HandlerFunc(projects.DeleteRegistry)))
+
+	mux.Handle("POST /api/apps/create", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.CreateApplication)))
+	mux.Handle("POST /api/apps/getByProjectId", middleware.AuthMiddleware("apps:read")(http.HandlerFunc(applications.GetApplicationByProjectID)))
+	mux.Handle("POST /api/apps/getById", middleware.AuthMiddleware("apps:read")(http.HandlerFunc(applications.GetApplicationById)))
+	mux.Handle("PUT /api/apps/update", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.UpdateApplication)))
+	mux.Handle("DELETE /api/apps/delete", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.DeleteApplication)))
+	mux.Handle("POST /api/apps/getLatestCommit", middleware.AuthMiddleware("apps:read")(http.HandlerFunc(applications.GetLatestCommit)))
+	mux.Handle("POST /api/apps/getPreviewUrl", middleware.AuthMiddleware("apps:read")(http.HandlerFunc(applications.GetPreviewURL)))
+
+	mux.Handle("POST /api/apps/envs/create", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.CreateEnvVariable)))
+	mux.Handle("POST /api/apps/envs/get", middleware.AuthMiddleware("apps:read")(http.HandlerFunc(applications.GetEnvVariables)))
+	mux.Handle("PUT /api/apps/envs/update", middleware.AuthMiddlewareWithID(models.GetAppIDByEnvVariableID, "apps:write")(http.HandlerFunc(applications.UpdateEnvVariable)))
+	mux.Handle("DELETE /api/apps/envs/delete", middleware.AuthMiddlewareWithID(models.GetAppIDByEnvVariableID, "apps:write")(http.HandlerFunc(applications.DeleteEnvVariable)))
+
+	mux.Handle("POST /api/apps/domains/create", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.CreateDomain)))
+	mux.Handle("POST /api/apps/domains/get", middleware.AuthMiddleware("apps:read")(http.HandlerFunc(applications.GetDomains)))
+	mux.Handle("PUT /api/apps/domains/update", middleware.AuthMiddlewareWithID(models.GetAppIDByDomainID, "apps:write")(http.HandlerFunc(applications.UpdateDomain)))
+	mux.Handle("PUT /api/apps/domains/ssl", middleware.AuthMiddlewareWithID(models.GetAppIDByDomainID, "apps:write")(http.HandlerFunc(applications.UpdateDomainSSL)))
+	mux.Handle("DELETE /api/apps/domains/delete", middleware.AuthMiddlewareWithID(models.GetAppIDByDomainID, "apps:write")(http.HandlerFunc(applications.DeleteDomain)))
+	mux.Handle("POST /api/apps/domains/verify", middleware.AuthMiddlewareWithID(models.GetAppIDByDomainID, "apps:write")(http.HandlerFunc(applications.VerifyDomainDNS)))
+	mux.Handle("POST /api/apps/domains/instructions", middleware.AuthMiddlewareWithID(models.GetAppIDByDomainID, "apps:read")(http.HandlerFunc(applications.GetDNSInstructions)))
+
+	mux.Handle("POST /api/apps/volumes/get", middleware.AuthMiddleware("apps:read")(http.HandlerFunc(applications.GetVolumes)))
+	mux.Handle("POST /api/apps/volumes/create", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.CreateVolume)))
+	mux.Handle("PUT /api/apps/volumes/update", middleware.AuthMiddlewareWithID(models.GetAppIDByVolumeID, "apps:write")(http.HandlerFunc(applications.UpdateVolume)))
+	mux.Handle("DELETE /api/apps/volumes/delete", middleware.AuthMiddlewareWithID(models.GetAppIDByVolumeID, "apps:write")(http.HandlerFunc(applications.DeleteVolume)))
+
+	mux.Handle("POST /api/apps/backups/get", middleware.AuthMiddleware("apps:read")(http.HandlerFunc(applications.GetBackups)))
+	mux.Handle("POST /api/apps/backups/create", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.CreateBackup)))
+	mux.Handle("GET /api/apps/backups/download", middleware.AuthMiddleware("apps:read")(http.HandlerFunc(applications.DownloadBackup)))
+	mux.Handle("POST /api/apps/backups/restore", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.RestoreBackup)))
+	mux.Handle("DELETE /api/apps/backups/delete", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.DeleteBackup)))
+
+	mux.Handle("POST /api/apps/middlewares/get", middleware.AuthMiddleware("apps:read")(http.HandlerFunc(applications.GetMiddlewares)))
+	mux.Handle("POST /api/apps/middlewares/create", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.CreateMiddleware)))
+	mux.Handle("PUT /api/apps/middlewares/update", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.UpdateMiddleware)))
+	mux.Handle("DELETE /api/apps/middlewares/delete", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.DeleteMiddleware)))
+	mux.Handle("POST /api/apps/crons/get", middleware.AuthMiddleware("apps:read")(http.HandlerFunc(applications.GetCrons)))
+	mux.Handle("POST /api/apps/crons/create", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.CreateCron)))
+	mux.Handle("PUT /api/apps/crons/update", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.UpdateCron)))
+	mux.Handle("DELETE /api/apps/crons/delete", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.DeleteCron)))
+	mux.Handle("POST /api/apps/crons/runs", middleware.AuthMiddleware("apps:read")(http.HandlerFunc(applications.GetCronRuns)))
+	mux.Handle("POST /api/apps/crons/runs/get", middleware.AuthMiddleware("apps:read")(http.HandlerFunc(applications.GetCronRun)))
+
+	mux.Handle("POST /api/apps/container/stop", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.StopContainerHandler)))
+	mux.Handle("POST /api/apps/container/start", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.StartContainerHandler)))
+	mux.Handle("POST /api/apps/container/restart", middleware.AuthMiddleware("apps:write")(http.HandlerFunc(applications.RestartContainerHandler)))
+	mux.Handle("GET /api/apps/container/status", middleware.AuthMiddleware("apps:read")(http.HandlerFunc(applications.GetContainerStatusHandler)))
+	mux.Handle("GET /api/apps/container/logs", middleware.AuthMiddleware("apps:read")(http.HandlerFunc(applications.GetContainerLogsHandler)))
 
 	mux.Handle("GET /api/github/app", middleware.AuthMiddleware()(http.HandlerFunc(github.GetApp)))
 	mux.Handle("GET /api/github/app/create", middleware.AuthMiddleware()(http.HandlerFunc(github.CreateGithubApp)))
@@ -89,13 +134,17 @@ func RegisterRoutes(mux *http.ServeMux) {
 	mux.Handle("GET /api/github/installation/callback", http.HandlerFunc(github.HandleInstallationEvent))
 	mux.Handle("GET /api/github/repositories", middleware.AuthMiddleware()(http.HandlerFunc(github.GetRepositories)))
 	mux.Handle("POST /api/github/branches", middleware.AuthMiddleware()(http.HandlerFunc(github.GetBranches)))
-	mux.HandleFunc("POST /api/github/webhook", github.GithubWebhook)
-
-	mux.HandleFunc("/api/deployments/logs/stream", deployments.LogsHandler)
-	mux.Handle("POST /api/deployments", middleware.AuthMiddleware()(http.HandlerFunc(deployments.AddDeployHandler)))
-	mux.Handle("POST /api/deployments/create", middleware.AuthMiddleware()(http.HandlerFunc(deployments.AddDeployHandler)))
-	mux.Handle("POST /api/deployments/getByAppId", middleware.AuthMiddleware()(http.HandlerFunc(deployments.GetByApplicationID)))
-	mux.Handle("GET /api/deployments/logs", middleware.AuthMiddleware()(http.HandlerFunc(deployments.GetCompletedDeploymentLogsHandler)))
+	mux.Handle("POST /api/github/webhook", webhookLimit(http.HandlerFunc(github.GithubWebhook)))
+
+	mux.Handle("/api/deployments/logs/stream", middleware.AuthMiddlewareWithID(models.GetAppIDByDeploymentID, "deploy:read")(http.HandlerFunc(deployments.LogsHandler)))
+	mux.Handle("POST /api/deployments", middleware.AuthMiddleware("deploy:write")(http.HandlerFunc(deployments.AddDeployHandler)))
+	mux.Handle("POST /api/deployments/create", middleware.AuthMiddleware("deploy:write")(http.HandlerFunc(deployments.AddDeployHandler)))
+	mux.Handle("POST /api/deployments/rollback", middleware.AuthMiddleware("deploy:write")(http.HandlerFunc(deployments.RollbackHandler)))
+	mux.Handle("POST /api/deployments/cancel", middleware.AuthMiddleware("deploy:write")(http.HandlerFunc(deployments.CancelDeploymentHandler)))
+	mux.Handle("GET /api/deployments/queue", middleware.AuthMiddleware("deploy:read")(http.HandlerFunc(deployments.GetQueueStatus)))
+	mux.Handle("POST /api/deployments/queue/reorder", middleware.AuthMiddleware()(http.HandlerFunc(deployments.ReorderQueueHandler)))
+	mux.Handle("POST /api/deployments/getByAppId", middleware.AuthMiddleware("deploy:read")(http.HandlerFunc(deployments.GetByApplicationID)))
+	mux.Handle("GET /api/deployments/logs", middleware.AuthMiddlewareWithID(models.GetAppIDByDeploymentID, "deploy:read")(http.HandlerFunc(deployments.GetCompletedDeploymentLogsHandler)))
 
 	mux.Handle("GET /api/templates/list", middleware.AuthMiddleware()(http.HandlerFunc(templates.ListServiceTemplates)))
 	mux.Handle("GET /api/templates/get", middleware.AuthMiddleware()(http.HandlerFunc(templates.GetServiceTemplateByName)))
@@ -104,8 +153,28 @@ func RegisterRoutes(mux *http.ServeMux) {
 
 	mux.Handle("GET /api/settings/system", middleware.AuthMiddleware()(http.HandlerFunc(settings.GetSystemSettings)))
 	mux.Handle("PUT /api/settings/system", middleware.AuthMiddleware()(http.HandlerFunc(settings.UpdateSystemSettings)))
+	mux.Handle("GET /api/settings/backup-storage", middleware.AuthMiddleware()(http.HandlerFunc(settings.GetBackupStorage)))
+	mux.Handle("PUT /api/settings/backup-storage", middleware.AuthMiddleware()(http.HandlerFunc(settings.UpdateBackupStorage)))
+	mux.Handle("POST /api/settings/backup-storage/test", middleware.AuthMiddleware()(http.HandlerFunc(settings.TestBackupStorage)))
+	mux.Handle("GET /api/settings/smtp", middleware.AuthMiddleware()(http.HandlerFunc(settings.GetSMTPSettings)))
+	mux.Handle("PUT /api/settings/smtp", middleware.AuthMiddleware()(http.HandlerFunc(settings.UpdateSMTPSettings)))
+	mux.Handle("POST /api/settings/smtp/test", middleware.AuthMiddleware()(http.HandlerFunc(settings.TestSMTPSettings)))
+	mux.Handle("GET /api/settings/dns-provider", middleware.AuthMiddleware()(http.HandlerFunc(settings.GetDNSProvider)))
+	mux.Handle("PUT /api/settings/dns-provider", middleware.AuthMiddleware()(http.HandlerFunc(settings.UpdateDNSProvider)))
+	mux.Handle("DELETE /api/settings/dns-provider", middleware.AuthMiddleware()(http.HandlerFunc(settings.DeleteDNSProvider)))
 	mux.Handle("POST /api/settings/docker/cleanup", middleware.AuthMiddleware()(http.HandlerFunc(settings.DockerCleanup)))
 
+	mux.Handle("GET /api/notifications", middleware.AuthMiddleware()(http.HandlerFunc(notifications.GetNotifications)))
+	mux.Handle("POST /api/notifications/read", middleware.AuthMiddleware()(http.HandlerFunc(notifications.MarkNotificationRead)))
+	mux.Handle("POST /api/notifications/read-all", middleware.AuthMiddleware()(http.HandlerFunc(notifications.MarkAllNotificationsRead)))
+	mux.Handle("DELETE /api/notifications/delete", middleware.AuthMiddleware()(http.HandlerFunc(notifications.DeleteNotification)))
+	mux.Handle("GET /api/notifications/channels", middleware.AuthMiddleware()(http.HandlerFunc(notifications.GetChannels)))
+	mux.Handle("POST /api/notifications/channels/create", middleware.AuthMiddleware()(http.HandlerFunc(notifications.CreateChannel)))
+	mux.Handle("PUT /api/notifications/channels/update", middleware.AuthMiddleware()(http.HandlerFunc(notifications.UpdateChannel)))
+	mux.Handle("DELETE /api/notifications/channels/delete", middleware.AuthMiddleware()(http.HandlerFunc(notifications.DeleteChannel)))
+	mux.Handle("POST /api/notifications/channels/test", middleware.AuthMiddleware()(http.HandlerFunc(notifications.TestChannel)))
+	mux.Handle("GET /api/notifications/channels/deliveries", middleware.AuthMiddleware()(http.HandlerFunc(notifications.GetChannelDeliveries)))
+
 	mux.Handle("GET /api/updates/version", middleware.AuthMiddleware()(http.HandlerFunc(updates.GetCurrentVersion)))
 	mux.Handle("GET /api/updates/check", middleware.AuthMiddleware()(http.HandlerFunc(updates.CheckForUpdates)))
 	mux.Handle("POST /api/updates/trigger", middleware.AuthMiddleware()(http.HandlerFunc(updates.TriggerUpdate)))
@@ -0,0 +1,282 @@
+package applications
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/backup"
+	"github.com/corecollectives/mist/models"
+)
+
+func GetBackups(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		AppID int64 `json:"appId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", err.Error())
+		return
+	}
+
+	app, ok := getAccessibleApp(w, userInfo.ID, req.AppID)
+	if !ok {
+		return
+	}
+
+	backups, err := models.GetBackupsByAppID(app.ID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to get backups", err.Error())
+		return
+	}
+
+	backupsJSON := make([]map[string]interface{}, 0, len(backups))
+	for _, b := range backups {
+		backupsJSON = append(backupsJSON, b.ToJson())
+	}
+
+	handlers.SendResponse(w, http.StatusOK, true, map[string]interface{}{
+		"backups":   backupsJSON,
+		"supported": backup.Supported(app),
+		"running":   backup.IsRunning(app.ID),
+	}, "Backups retrieved successfully", "")
+}
+
+func CreateBackup(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		AppID         int64   `json:"appId"`
+		RetentionDays *int    `json:"retentionDays"`
+		Notes         *string `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", err.Error())
+		return
+	}
+	if req.RetentionDays != nil && *req.RetentionDays < 0 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Retention days can't be negative", "")
+		return
+	}
+
+	app, ok := getAccessibleApp(w, userInfo.ID, req.AppID)
+	if !ok {
+		return
+	}
+
+	b, err := backup.Start(app, backup.Options{
+		Type:          models.BackupTypeManual,
+		CreatedBy:     &userInfo.ID,
+		RetentionDays: req.RetentionDays,
+		Notes:         req.Notes,
+	})
+	if err != nil {
+		sendBackupError(w, "Failed to start backup", err)
+		return
+	}
+
+	models.LogUserAudit(userInfo.ID, "create", "backup", &b.ID, map[string]interface{}{
+		"app_id":      app.ID,
+		"backup_name": b.BackupName,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, b.ToJson(), "Backup started", "")
+}
+
+func DownloadBackup(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	backupID, err := strconv.ParseInt(r.URL.Query().Get("backupId"), 10, 64)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid backup ID", "Backup ID must be an integer")
+		return
+	}
+
+	b, ok := getAccessibleBackup(w, userInfo.ID, backupID)
+	if !ok {
+		return
+	}
+	if b.Status != models.BackupStatusCompleted {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Only completed backups can be downloaded", "")
+		return
+	}
+
+	file, err := backup.Open(r.Context(), b)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "Backup file not found", err.Error())
+		return
+	}
+	defer file.Close()
+
+	models.LogUserAudit(userInfo.ID, "download", "backup", &b.ID, map[string]interface{}{
+		"app_id":       b.AppID,
+		"storage_type": b.StorageType,
+	})
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", b.BackupName))
+	if b.Checksum != nil {
+		w.Header().Set("X-Checksum-Sha256", *b.Checksum)
+	}
+	// local files support range requests, remote ones are streamed through
+	if local, ok := file.(*os.File); ok {
+		if stat, err := local.Stat(); err == nil {
+			http.ServeContent(w, r, b.BackupName, stat.ModTime(), local)
+			return
+		}
+	}
+	if b.FileSize != nil {
+		w.Header().Set("Content-Length", strconv.FormatInt(*b.FileSize, 10))
+	}
+	io.Copy(w, file)
+}
+
+func RestoreBackup(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		BackupID int64 `json:"backupId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", err.Error())
+		return
+	}
+
+	b, ok := getAccessibleBackup(w, userInfo.ID, req.BackupID)
+	if !ok {
+		return
+	}
+	app, err := models.GetApplicationByID(b.AppID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to get application", err.Error())
+		return
+	}
+
+	if err := backup.Restore(b, app, &userInfo.ID); err != nil {
+		sendBackupError(w, "Failed to start restore", err)
+		return
+	}
+
+	models.LogUserAudit(userInfo.ID, "restore", "backup", &b.ID, map[string]interface{}{
+		"app_id":      app.ID,
+		"backup_name": b.BackupName,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, b.ToJson(), "Restore started", "")
+}
+
+func DeleteBackup(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		BackupID int64 `json:"backupId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", err.Error())
+		return
+	}
+
+	b, ok := getAccessibleBackup(w, userInfo.ID, req.BackupID)
+	if !ok {
+		return
+	}
+	if backup.IsRunning(b.AppID) {
+		handlers.SendResponse(w, http.StatusConflict, false, nil, "Wait for the running backup or restore to finish", backup.ErrBusy.Error())
+		return
+	}
+
+	if err := backup.Delete(b); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to delete backup", err.Error())
+		return
+	}
+
+	models.LogUserAudit(userInfo.ID, "delete", "backup", &b.ID, map[string]interface{}{
+		"app_id":      b.AppID,
+		"backup_name": b.BackupName,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, nil, "Backup deleted successfully", "")
+}
+
+func getAccessibleApp(w http.ResponseWriter, userID, appID int64) (*models.App, bool) {
+	if appID == 0 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "App ID is required", "")
+		return nil, false
+	}
+
+	app, err := models.GetApplicationByID(appID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to get application", err.Error())
+		return nil, false
+	}
+	if app == nil {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "Application not found", "")
+		return nil, false
+	}
+
+	isUserMember, err := models.HasUserAccessToProject(userID, app.ProjectID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify access", err.Error())
+		return nil, false
+	}
+	if !isUserMember {
+		handlers.SendResponse(w, http.StatusForbidden, false, nil, "You do not have access to this application", "")
+		return nil, false
+	}
+	return app, true
+}
+
+func getAccessibleBackup(w http.ResponseWriter, userID, backupID int64) (*models.Backup, bool) {
+	if backupID == 0 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Backup ID is required", "")
+		return nil, false
+	}
+
+	b, err := models.GetBackupByID(backupID)
+	if err != nil || b.Status == models.BackupStatusDeleted {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "Backup not found", "")
+		return nil, false
+	}
+	if _, ok := getAccessibleApp(w, userID, b.AppID); !ok {
+		return nil, false
+	}
+	return b, true
+}
+
+func sendBackupError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, backup.ErrBusy):
+		handlers.SendResponse(w, http.StatusConflict, false, nil, message, err.Error())
+	case errors.Is(err, backup.ErrNotSupported), errors.Is(err, backup.ErrNotRunning), errors.Is(err, backup.ErrNotRestorable):
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, message, err.Error())
+	default:
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, message, err.Error())
+	}
+}
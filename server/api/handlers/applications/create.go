@@ -3,9 +3,11 @@ package applications
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
 	"github.com/corecollectives/mist/api/handlers"
 	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/docker"
 	"github.com/corecollectives/mist/models"
 )
 
@@ -24,6 +26,8 @@ func CreateApplication(w http.ResponseWriter, r *http.Request) {
 		TemplateName *string           `json:"templateName"` // For database type
 		Port         *int              `json:"port"`         // For web type
 		EnvVars      map[string]string `json:"envVars"`
+		SourceType   string            `json:"sourceType"` // "git" or "image", web and service only
+		Image        *string           `json:"image"`      // For image source
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
@@ -49,6 +53,24 @@ func CreateApplication(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.SourceType == "" || req.AppType == "database" {
+		req.SourceType = string(models.SourceTypeGit)
+	}
+	if req.SourceType != string(models.SourceTypeGit) && req.SourceType != string(models.SourceTypeImage) {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid source type", "Must be 'git' or 'image'")
+		return
+	}
+	if req.SourceType == string(models.SourceTypeImage) && (req.Image == nil || strings.TrimSpace(*req.Image) == "") {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Image is required for image source apps", "Missing fields")
+		return
+	}
+	if req.SourceType == string(models.SourceTypeImage) {
+		if _, err := docker.ImageRegistryHost(strings.TrimSpace(*req.Image)); err != nil {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid image reference", err.Error())
+			return
+		}
+	}
+
 	isUserMember, err := models.HasUserAccessToProject(userInfo.ID, req.ProjectID)
 	if err != nil {
 		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify project access", err.Error())
@@ -65,6 +87,11 @@ func CreateApplication(w http.ResponseWriter, r *http.Request) {
 		ProjectID:   req.ProjectID,
 		CreatedBy:   userInfo.ID,
 		AppType:     models.AppType(req.AppType),
+		SourceType:  models.SourceType(req.SourceType),
+	}
+	if app.IsImageSource() {
+		image := strings.TrimSpace(*req.Image)
+		app.Image = &image
 	}
 
 	switch req.AppType {
@@ -123,7 +150,7 @@ func CreateApplication(w http.ResponseWriter, r *http.Request) {
 			autoDomain, err := models.GenerateAutoDomain(project.Name, app.Name)
 			if err == nil && autoDomain != "" {
 				// Create the auto-generated domain
-				_, err = models.CreateDomain(app.ID, autoDomain)
+				_, err = models.CreateDomain(app.ID, autoDomain, "", nil)
 				if err != nil {
 					// Log the error but don't fail the app creation
 					// The user can manually add domains later
@@ -0,0 +1,306 @@
+package applications
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/scheduler"
+)
+
+const (
+	defaultCronTimeout = 3600
+	maxCronTimeout     = 24 * 3600
+	cronRunsLimit      = 50
+)
+
+type cronRequest struct {
+	Name     string             `json:"name"`
+	Schedule string             `json:"schedule"`
+	Command  string             `json:"command"`
+	RunMode  models.CronRunMode `json:"runMode"`
+	// seconds, defaults to an hour
+	Timeout int   `json:"timeout"`
+	Enable  *bool `json:"enable"`
+}
+
+func GetCrons(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		AppID int64 `json:"appId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", err.Error())
+		return
+	}
+
+	app, ok := getAccessibleApp(w, userInfo.ID, req.AppID)
+	if !ok {
+		return
+	}
+
+	crons, err := models.GetCronsByAppID(app.ID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to get cron jobs", err.Error())
+		return
+	}
+
+	cronsJSON := make([]map[string]interface{}, 0, len(crons))
+	for _, c := range crons {
+		cronJSON := c.ToJson()
+		cronJSON["running"] = scheduler.IsRunning(c.ID)
+		cronsJSON = append(cronsJSON, cronJSON)
+	}
+
+	handlers.SendResponse(w, http.StatusOK, true, cronsJSON, "Cron jobs retrieved successfully", "")
+}
+
+func CreateCron(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		AppID int64 `json:"appId"`
+		cronRequest
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", err.Error())
+		return
+	}
+
+	app, ok := getAccessibleApp(w, userInfo.ID, req.AppID)
+	if !ok {
+		return
+	}
+
+	cron := &models.Cron{AppID: app.ID, Enable: true, CreatedBy: &userInfo.ID}
+	if !applyCronRequest(w, cron, &req.cronRequest) {
+		return
+	}
+	if err := cron.InsertInDB(); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to create cron job", err.Error())
+		return
+	}
+
+	models.LogUserAudit(userInfo.ID, "create", "cron", &cron.ID, map[string]interface{}{
+		"app_id":   app.ID,
+		"name":     cron.Name,
+		"schedule": cron.Schedule,
+		"command":  cron.Command,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, cron.ToJson(), "Cron job created successfully", "")
+}
+
+func UpdateCron(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		CronID int64 `json:"cronId"`
+		cronRequest
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", err.Error())
+		return
+	}
+
+	cron, ok := getAccessibleCron(w, userInfo.ID, req.CronID)
+	if !ok {
+		return
+	}
+	if !applyCronRequest(w, cron, &req.cronRequest) {
+		return
+	}
+	if err := cron.UpdateCron(); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to update cron job", err.Error())
+		return
+	}
+
+	models.LogUserAudit(userInfo.ID, "update", "cron", &cron.ID, map[string]interface{}{
+		"app_id":   cron.AppID,
+		"name":     cron.Name,
+		"schedule": cron.Schedule,
+		"command":  cron.Command,
+		"enable":   cron.Enable,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, cron.ToJson(), "Cron job updated successfully", "")
+}
+
+func DeleteCron(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		CronID int64 `json:"cronId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", err.Error())
+		return
+	}
+
+	cron, ok := getAccessibleCron(w, userInfo.ID, req.CronID)
+	if !ok {
+		return
+	}
+	if err := models.DeleteCron(cron.ID); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to delete cron job", err.Error())
+		return
+	}
+
+	models.LogUserAudit(userInfo.ID, "delete", "cron", &cron.ID, map[string]interface{}{
+		"app_id": cron.AppID,
+		"name":   cron.Name,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, nil, "Cron job deleted successfully", "")
+}
+
+// latest runs of a job without their output, which is fetched per run
+func GetCronRuns(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		CronID int64 `json:"cronId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", err.Error())
+		return
+	}
+
+	cron, ok := getAccessibleCron(w, userInfo.ID, req.CronID)
+	if !ok {
+		return
+	}
+
+	runs, err := models.GetCronRuns(cron.ID, cronRunsLimit)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to get cron runs", err.Error())
+		return
+	}
+
+	runsJSON := make([]map[string]interface{}, 0, len(runs))
+	for _, run := range runs {
+		runJSON := run.ToJson()
+		delete(runJSON, "output")
+		runsJSON = append(runsJSON, runJSON)
+	}
+
+	handlers.SendResponse(w, http.StatusOK, true, runsJSON, "Cron runs retrieved successfully", "")
+}
+
+func GetCronRun(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		CronRunID int64 `json:"cronRunId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", err.Error())
+		return
+	}
+	if req.CronRunID == 0 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Cron run ID is required", "")
+		return
+	}
+
+	run, err := models.GetCronRunByID(req.CronRunID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "Cron run not found", "")
+		return
+	}
+	if _, ok := getAccessibleApp(w, userInfo.ID, run.AppID); !ok {
+		return
+	}
+
+	handlers.SendResponse(w, http.StatusOK, true, run.ToJson(), "Cron run retrieved successfully", "")
+}
+
+// validates the request and copies it onto the job, the next run is recomputed from now
+func applyCronRequest(w http.ResponseWriter, cron *models.Cron, req *cronRequest) bool {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Schedule = strings.TrimSpace(req.Schedule)
+	req.Command = strings.TrimSpace(req.Command)
+	if req.Name == "" || req.Schedule == "" || req.Command == "" {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Name, schedule and command are required", "Missing fields")
+		return false
+	}
+	if req.RunMode == "" {
+		req.RunMode = models.CronRunModeContainer
+	}
+	if req.RunMode != models.CronRunModeContainer && req.RunMode != models.CronRunModeOneOff {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Run mode must be container or oneoff", "Invalid value")
+		return false
+	}
+	if req.Timeout == 0 {
+		req.Timeout = defaultCronTimeout
+	}
+	if req.Timeout < 1 || req.Timeout > maxCronTimeout {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Timeout must be between 1 second and 24 hours", "Invalid value")
+		return false
+	}
+
+	next, err := scheduler.NextRun(req.Schedule, time.Now())
+	if err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid cron schedule", err.Error())
+		return false
+	}
+
+	cron.Name = req.Name
+	cron.Schedule = req.Schedule
+	cron.Command = req.Command
+	cron.RunMode = req.RunMode
+	cron.Timeout = req.Timeout
+	if req.Enable != nil {
+		cron.Enable = *req.Enable
+	}
+	cron.NextRun = nil
+	if cron.Enable {
+		cron.NextRun = &next
+	}
+	return true
+}
+
+func getAccessibleCron(w http.ResponseWriter, userID, cronID int64) (*models.Cron, bool) {
+	if cronID == 0 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Cron ID is required", "")
+		return nil, false
+	}
+
+	cron, err := models.GetCronByID(cronID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "Cron job not found", "")
+		return nil, false
+	}
+	if _, ok := getAccessibleApp(w, userID, cron.AppID); !ok {
+		return nil, false
+	}
+	return cron, true
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/corecollectives/mist/api/handlers"
 	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/backup"
 	"github.com/corecollectives/mist/constants"
 	"github.com/corecollectives/mist/docker"
 	"github.com/corecollectives/mist/models"
@@ -163,6 +164,14 @@ func DeleteApplication(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	backup.DeleteAppBackups(app.ID)
+	if err := models.DeleteAppCrons(app.ID); err != nil {
+		log.Warn().Err(err).Int64("app_id", app.ID).Msg("Failed to delete cron jobs during app deletion")
+	}
+	if err := models.DeleteAppMiddlewares(app.ID); err != nil {
+		log.Warn().Err(err).Int64("app_id", app.ID).Msg("Failed to delete middlewares during app deletion")
+	}
+
 	err = models.DeleteApplication(appID)
 	if err != nil {
 		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to delete application from database", err.Error())
@@ -0,0 +1,187 @@
+package applications
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/docker"
+	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/utils"
+)
+
+// two years, the longest max-age browsers keep
+const maxHstsMaxAge = 63072000
+
+// changes how a domain is served: where its certificate comes from, https and www redirects
+// and hsts. fields left out keep their current value
+func UpdateDomainSSL(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		ID          int64  `json:"id"`
+		AppID       int64  `json:"appId"`
+		SslProvider string `json:"sslProvider"`
+		// pem encoded, required when switching to a custom certificate
+		Certificate       string `json:"certificate"`
+		PrivateKey        string `json:"privateKey"`
+		ForceHttps        *bool  `json:"forceHttps"`
+		HstsEnabled       *bool  `json:"hstsEnabled"`
+		HstsMaxAge        *int   `json:"hstsMaxAge"`
+		RedirectWww       *bool  `json:"redirectWww"`
+		RedirectWwwToRoot *bool  `json:"redirectWwwToRoot"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return
+	}
+
+	if req.ID == 0 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "ID is required", "Missing fields")
+		return
+	}
+
+	domain, err := models.GetDomainByID(req.ID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to get domain", err.Error())
+		return
+	}
+
+	if req.AppID != 0 && req.AppID != domain.AppID {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Domain does not belong to this application", "Application mismatch")
+		return
+	}
+
+	isApplicationOwner, err := models.IsUserApplicationOwner(userInfo.ID, domain.AppID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify application ownership", err.Error())
+		return
+	}
+	if !isApplicationOwner {
+		handlers.SendResponse(w, http.StatusForbidden, false, nil, "You do not have permission to modify this application", "Forbidden")
+		return
+	}
+
+	before := domain.SslProvider
+	if req.SslProvider != "" {
+		provider, ok := models.ParseSSLProvider(req.SslProvider)
+		if !ok {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, "SSL provider must be letsencrypt, custom or none", "Invalid value")
+			return
+		}
+		domain.SslProvider = provider
+	}
+	if req.ForceHttps != nil {
+		domain.ForceHttps = *req.ForceHttps
+	}
+	if req.HstsEnabled != nil {
+		domain.HstsEnabled = *req.HstsEnabled
+	}
+	if req.HstsMaxAge != nil {
+		if *req.HstsMaxAge < 0 || *req.HstsMaxAge > maxHstsMaxAge {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, "HSTS max age must be between 0 and 63072000 seconds", "Invalid value")
+			return
+		}
+		domain.HstsMaxAge = *req.HstsMaxAge
+	}
+	if req.RedirectWww != nil {
+		domain.RedirectWww = *req.RedirectWww
+	}
+	if req.RedirectWwwToRoot != nil {
+		domain.RedirectWwwToRoot = *req.RedirectWwwToRoot
+	}
+
+	if domain.SslProvider == models.SSLProviderNone && domain.HstsEnabled {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "HSTS can't be enabled on a domain served without SSL", "Invalid value")
+		return
+	}
+
+	// a www redirect serves both hosts from the same router, so the certificate has to cover both
+	hosts := []string{domain.Domain}
+	if domain.RedirectWww {
+		hosts = append(hosts, domain.WwwAlias())
+	}
+
+	switch domain.SslProvider {
+	case models.SSLProviderCustom:
+		certPEM, keyPEM := strings.TrimSpace(req.Certificate), strings.TrimSpace(req.PrivateKey)
+		if certPEM == "" && keyPEM == "" && domain.CertificateData != nil {
+			certPEM = *domain.CertificateData
+			if keyPEM, err = domain.DecryptedKey(); err != nil {
+				handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to read stored key", err.Error())
+				return
+			}
+		}
+		if certPEM == "" || keyPEM == "" {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Certificate and private key are required for a custom certificate", "Missing fields")
+			return
+		}
+		leaf, err := utils.ValidateCertificate(certPEM, keyPEM, hosts...)
+		if err != nil {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid certificate", err.Error())
+			return
+		}
+		if err := domain.SetCertificate(certPEM, keyPEM, leaf); err != nil {
+			handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to store certificate", err.Error())
+			return
+		}
+		domain.SslStatus = models.SSLStatusActive
+	case models.SSLProviderNone:
+		clearCertificate(domain)
+		domain.SslStatus = models.SSLStatusDisabled
+	default:
+		// traefik requests the certificate once the new routers are up
+		if before != domain.SslProvider {
+			clearCertificate(domain)
+			domain.SslStatus = models.SSLStatusPending
+		}
+	}
+
+	if err := domain.UpdateSSLSettings(); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to update domain", err.Error())
+		return
+	}
+
+	// certificates have to be in place before the container's routers ask for them
+	if before == models.SSLProviderCustom || domain.SslProvider == models.SSLProviderCustom {
+		if err := docker.SyncTraefikConfig(); err != nil {
+			handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to update Traefik configuration", err.Error())
+			return
+		}
+	}
+
+	go func() {
+		app, err := models.GetApplicationByID(domain.AppID)
+		if err == nil {
+			docker.RecreateContainer(app)
+		}
+	}()
+
+	models.LogUserAudit(userInfo.ID, "update_ssl", "domain", &domain.ID, map[string]interface{}{
+		"appId":             domain.AppID,
+		"domain":            domain.Domain,
+		"sslProvider":       domain.SslProvider,
+		"forceHttps":        domain.ForceHttps,
+		"hstsEnabled":       domain.HstsEnabled,
+		"redirectWww":       domain.RedirectWww,
+		"redirectWwwToRoot": domain.RedirectWwwToRoot,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, domain, "Domain SSL settings updated successfully", "")
+}
+
+func clearCertificate(domain *models.Domain) {
+	domain.CertificateData = nil
+	domain.KeyData = nil
+	domain.Issuer = nil
+	domain.IssuedAt = nil
+	domain.ExpiresAt = nil
+	domain.ExpiryWarningSentAt = nil
+}
@@ -3,6 +3,7 @@ package applications
 import (
 	"encoding/json"
 	"net/http"
+	"regexp"
 	"strings"
 	"time"
 
@@ -11,8 +12,65 @@ import (
 	"github.com/corecollectives/mist/docker"
 	"github.com/corecollectives/mist/models"
 	"github.com/corecollectives/mist/utils"
+	"github.com/rs/zerolog/log"
 )
 
+var pathPrefixPattern = regexp.MustCompile(`^(/[A-Za-z0-9._~!$&()*+,;=:@%-]+)+$`)
+
+// trims the path prefix to how it's stored, "/" and "" both route the whole host
+func normalizePathPrefix(prefix string) (string, bool) {
+	prefix = strings.TrimRight(strings.TrimSpace(prefix), "/")
+	if prefix == "" {
+		return "", true
+	}
+	return prefix, pathPrefixPattern.MatchString(prefix)
+}
+
+// checks the path and port of a domain, writes the error response when they're invalid
+func validateDomainRoute(w http.ResponseWriter, userID, appID int64, domain, pathPrefix string, targetPort *int, excludeID int64) bool {
+	if targetPort != nil && (*targetPort < 1 || *targetPort > 65535) {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Target port must be between 1 and 65535", "Invalid value")
+		return false
+	}
+	exists, err := models.DomainRouteExists(domain, pathPrefix, excludeID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to check existing domains", err.Error())
+		return false
+	}
+	if exists {
+		handlers.SendResponse(w, http.StatusConflict, false, nil, domain+pathPrefix+" is already routed to an app", "Domain in use")
+		return false
+	}
+
+	// paths of a host can only be split between apps of the same project or projects the
+	// user can access, otherwise anyone could take over a path of someone else's domain
+	app, err := models.GetApplicationByID(appID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to get application", err.Error())
+		return false
+	}
+	projectIDs, err := models.GetDomainHostProjectIDs(domain, excludeID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to check existing domains", err.Error())
+		return false
+	}
+	for _, projectID := range projectIDs {
+		if projectID == app.ProjectID {
+			continue
+		}
+		hasAccess, err := models.HasUserAccessToProject(userID, projectID)
+		if err != nil {
+			handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify project access", err.Error())
+			return false
+		}
+		if !hasAccess {
+			handlers.SendResponse(w, http.StatusConflict, false, nil, domain+" is already used by an app you don't have access to", "Domain in use")
+			return false
+		}
+	}
+	return true
+}
+
 func CreateDomain(w http.ResponseWriter, r *http.Request) {
 	userInfo, ok := middleware.GetUser(r)
 	if !ok {
@@ -21,8 +79,10 @@ func CreateDomain(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		AppID  int64  `json:"appId"`
-		Domain string `json:"domain"`
+		AppID      int64  `json:"appId"`
+		Domain     string `json:"domain"`
+		PathPrefix string `json:"pathPrefix"`
+		TargetPort *int   `json:"targetPort"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -45,7 +105,16 @@ func CreateDomain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	domain, err := models.CreateDomain(req.AppID, strings.TrimSpace(req.Domain))
+	pathPrefix, ok := normalizePathPrefix(req.PathPrefix)
+	if !ok {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Path prefix must be a URL path like /api", "Invalid value")
+		return
+	}
+	if !validateDomainRoute(w, userInfo.ID, req.AppID, strings.TrimSpace(req.Domain), pathPrefix, req.TargetPort, 0) {
+		return
+	}
+
+	domain, err := models.CreateDomain(req.AppID, strings.TrimSpace(req.Domain), pathPrefix, req.TargetPort)
 	if err != nil {
 		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to create domain", err.Error())
 		return
@@ -59,8 +128,10 @@ func CreateDomain(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	models.LogUserAudit(userInfo.ID, "create", "domain", &domain.ID, map[string]interface{}{
-		"appId":  req.AppID,
-		"domain": domain.Domain,
+		"appId":      req.AppID,
+		"domain":     domain.Domain,
+		"pathPrefix": domain.PathPrefix,
+		"targetPort": domain.TargetPort,
 	})
 
 	handlers.SendResponse(w, http.StatusOK, true, domain, "Domain created successfully", "")
@@ -115,7 +186,11 @@ func UpdateDomain(w http.ResponseWriter, r *http.Request) {
 
 	var req struct {
 		ID     int64  `json:"id"`
+		AppID  int64  `json:"appId"`
 		Domain string `json:"domain"`
+		// left out to keep the current value, a target port of 0 goes back to the app's port
+		PathPrefix *string `json:"pathPrefix"`
+		TargetPort *int    `json:"targetPort"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -134,6 +209,11 @@ func UpdateDomain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.AppID != 0 && req.AppID != domain.AppID {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Domain does not belong to this application", "Application mismatch")
+		return
+	}
+
 	isApplicationOwner, err := models.IsUserApplicationOwner(userInfo.ID, domain.AppID)
 	if err != nil {
 		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify application ownership", err.Error())
@@ -146,7 +226,44 @@ func UpdateDomain(w http.ResponseWriter, r *http.Request) {
 
 	oldDomain := domain.Domain
 
-	err = models.UpdateDomain(req.ID, strings.TrimSpace(req.Domain))
+	pathPrefix := domain.PathPrefix
+	if req.PathPrefix != nil {
+		if pathPrefix, ok = normalizePathPrefix(*req.PathPrefix); !ok {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Path prefix must be a URL path like /api", "Invalid value")
+			return
+		}
+	}
+	targetPort := domain.TargetPort
+	if req.TargetPort != nil {
+		targetPort = req.TargetPort
+		if *targetPort == 0 {
+			targetPort = nil
+		}
+	}
+	if !validateDomainRoute(w, userInfo.ID, domain.AppID, strings.TrimSpace(req.Domain), pathPrefix, targetPort, domain.ID) {
+		return
+	}
+
+	// an uploaded certificate has to cover the new name too
+	if domain.SslProvider == models.SSLProviderCustom && domain.CertificateData != nil {
+		renamed := *domain
+		renamed.Domain = strings.TrimSpace(req.Domain)
+		hosts := []string{renamed.Domain}
+		if renamed.RedirectWww {
+			hosts = append(hosts, renamed.WwwAlias())
+		}
+		key, err := domain.DecryptedKey()
+		if err != nil {
+			handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to read stored key", err.Error())
+			return
+		}
+		if _, err := utils.ValidateCertificate(*domain.CertificateData, key, hosts...); err != nil {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, "The domain's certificate doesn't match the new name, upload a new one first", err.Error())
+			return
+		}
+	}
+
+	err = models.UpdateDomain(req.ID, strings.TrimSpace(req.Domain), pathPrefix, targetPort)
 	if err != nil {
 		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to update domain", err.Error())
 		return
@@ -169,10 +286,14 @@ func UpdateDomain(w http.ResponseWriter, r *http.Request) {
 	models.LogUserAudit(userInfo.ID, "update", "domain", &req.ID, map[string]interface{}{
 		"appId": domain.AppID,
 		"before": map[string]interface{}{
-			"domain": oldDomain,
+			"domain":     oldDomain,
+			"pathPrefix": domain.PathPrefix,
+			"targetPort": domain.TargetPort,
 		},
 		"after": map[string]interface{}{
-			"domain": strings.TrimSpace(req.Domain),
+			"domain":     strings.TrimSpace(req.Domain),
+			"pathPrefix": pathPrefix,
+			"targetPort": targetPort,
 		},
 	})
 
@@ -187,7 +308,8 @@ func DeleteDomain(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		ID int64 `json:"id"`
+		ID    int64 `json:"id"`
+		AppID int64 `json:"appId"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -206,6 +328,11 @@ func DeleteDomain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.AppID != 0 && req.AppID != domain.AppID {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Domain does not belong to this application", "Application mismatch")
+		return
+	}
+
 	isApplicationOwner, err := models.IsUserApplicationOwner(userInfo.ID, domain.AppID)
 	if err != nil {
 		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify application ownership", err.Error())
@@ -222,6 +349,12 @@ func DeleteDomain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if domain.SslProvider == models.SSLProviderCustom {
+		if err := docker.SyncTraefikConfig(); err != nil {
+			log.Warn().Err(err).Int64("domain_id", req.ID).Msg("Failed to remove certificate from Traefik configuration")
+		}
+	}
+
 	// Recreate container in background to apply domain changes
 	go func() {
 		app, err := models.GetApplicationByID(domain.AppID)
@@ -246,7 +379,8 @@ func VerifyDomainDNS(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		ID int64 `json:"id"`
+		ID    int64 `json:"id"`
+		AppID int64 `json:"appId"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -265,6 +399,11 @@ func VerifyDomainDNS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.AppID != 0 && req.AppID != domain.AppID {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Domain does not belong to this application", "Application mismatch")
+		return
+	}
+
 	isApplicationOwner, err := models.IsUserApplicationOwner(userInfo.ID, domain.AppID)
 	if err != nil {
 		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify application ownership", err.Error())
@@ -323,7 +462,8 @@ func GetDNSInstructions(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		ID int64 `json:"id"`
+		ID    int64 `json:"id"`
+		AppID int64 `json:"appId"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -342,6 +482,11 @@ func GetDNSInstructions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.AppID != 0 && req.AppID != domain.AppID {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Domain does not belong to this application", "Application mismatch")
+		return
+	}
+
 	isApplicationOwner, err := models.IsUserApplicationOwner(userInfo.ID, domain.AppID)
 	if err != nil {
 		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify application ownership", err.Error())
@@ -106,6 +106,7 @@ func UpdateEnvVariable(w http.ResponseWriter, r *http.Request) {
 
 	var req struct {
 		ID    int64  `json:"id"`
+		AppID int64  `json:"appId"`
 		Key   string `json:"key"`
 		Value string `json:"value"`
 	}
@@ -126,6 +127,11 @@ func UpdateEnvVariable(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.AppID != 0 && req.AppID != env.AppID {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Environment variable does not belong to this application", "Application mismatch")
+		return
+	}
+
 	isApplicationOwner, err := models.IsUserApplicationOwner(userInfo.ID, env.AppID)
 	if err != nil {
 		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify application ownership", err.Error())
@@ -158,7 +164,8 @@ func DeleteEnvVariable(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		ID int64 `json:"id"`
+		ID    int64 `json:"id"`
+		AppID int64 `json:"appId"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -177,6 +184,11 @@ func DeleteEnvVariable(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.AppID != 0 && req.AppID != env.AppID {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Environment variable does not belong to this application", "Application mismatch")
+		return
+	}
+
 	isApplicationOwner, err := models.IsUserApplicationOwner(userInfo.ID, env.AppID)
 	if err != nil {
 		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify application ownership", err.Error())
@@ -0,0 +1,435 @@
+package applications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/docker"
+	"github.com/corecollectives/mist/models"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	maxMiddlewareNameLength = 64
+	maxIPStrategyDepth      = 10
+)
+
+var headerName = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+type middlewareRequest struct {
+	Name string                   `json:"name"`
+	Type models.AppMiddlewareType `json:"type"`
+	// the type's config, basic auth takes plain passwords
+	Config   json.RawMessage `json:"config"`
+	Priority *int            `json:"priority"`
+	Enabled  *bool           `json:"enabled"`
+}
+
+type basicAuthRequest struct {
+	Users []struct {
+		Username string `json:"username"`
+		// left empty to keep the stored password of the user
+		Password string `json:"password"`
+	} `json:"users"`
+	Realm        string `json:"realm"`
+	RemoveHeader bool   `json:"removeHeader"`
+}
+
+func GetMiddlewares(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		AppID int64 `json:"appId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", err.Error())
+		return
+	}
+
+	app, ok := getAccessibleApp(w, userInfo.ID, req.AppID)
+	if !ok {
+		return
+	}
+
+	middlewares, err := models.GetAppMiddlewares(app.ID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to get middlewares", err.Error())
+		return
+	}
+
+	middlewaresJSON := make([]map[string]interface{}, 0, len(middlewares))
+	for _, m := range middlewares {
+		middlewaresJSON = append(middlewaresJSON, m.ToJson())
+	}
+
+	handlers.SendResponse(w, http.StatusOK, true, middlewaresJSON, "Middlewares retrieved successfully", "")
+}
+
+// a middleware that can't be applied fails the new container rather than leaving the app unprotected
+func recreateWithMiddlewares(app *models.App) {
+	if err := docker.RecreateContainer(app); err != nil {
+		log.Error().Err(err).Int64("app_id", app.ID).Msg("Failed to recreate container with the app's middlewares")
+	}
+}
+
+func CreateMiddleware(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		AppID int64 `json:"appId"`
+		middlewareRequest
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", err.Error())
+		return
+	}
+
+	app, ok := getAccessibleApp(w, userInfo.ID, req.AppID)
+	if !ok {
+		return
+	}
+
+	if !models.IsAppMiddlewareType(req.Type) {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Type must be basic_auth, ip_allowlist, rate_limit, headers, strip_prefix or compress", "Invalid value")
+		return
+	}
+	if len(req.Config) == 0 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Config is required", "Missing fields")
+		return
+	}
+
+	m := &models.AppMiddleware{AppID: app.ID, Type: req.Type, Enabled: true, CreatedBy: &userInfo.ID}
+	if !applyMiddlewareRequest(w, m, &req.middlewareRequest) {
+		return
+	}
+	if err := m.InsertInDB(); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to create middleware", err.Error())
+		return
+	}
+
+	go recreateWithMiddlewares(app)
+
+	models.LogUserAudit(userInfo.ID, "create", "middleware", &m.ID, map[string]interface{}{
+		"app_id": app.ID,
+		"name":   m.Name,
+		"type":   m.Type,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, m.ToJson(), "Middleware created successfully", "")
+}
+
+func UpdateMiddleware(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		MiddlewareID int64 `json:"middlewareId"`
+		middlewareRequest
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", err.Error())
+		return
+	}
+
+	m, app, ok := getAccessibleMiddleware(w, userInfo.ID, req.MiddlewareID)
+	if !ok {
+		return
+	}
+	if req.Type != "" && req.Type != m.Type {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "The type of a middleware can't be changed", "Invalid value")
+		return
+	}
+	if !applyMiddlewareRequest(w, m, &req.middlewareRequest) {
+		return
+	}
+	if err := m.UpdateMiddleware(); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to update middleware", err.Error())
+		return
+	}
+
+	go recreateWithMiddlewares(app)
+
+	models.LogUserAudit(userInfo.ID, "update", "middleware", &m.ID, map[string]interface{}{
+		"app_id":  m.AppID,
+		"name":    m.Name,
+		"type":    m.Type,
+		"enabled": m.Enabled,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, m.ToJson(), "Middleware updated successfully", "")
+}
+
+func DeleteMiddleware(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		MiddlewareID int64 `json:"middlewareId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", err.Error())
+		return
+	}
+
+	m, app, ok := getAccessibleMiddleware(w, userInfo.ID, req.MiddlewareID)
+	if !ok {
+		return
+	}
+	if err := models.DeleteAppMiddleware(m.ID); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to delete middleware", err.Error())
+		return
+	}
+
+	go recreateWithMiddlewares(app)
+
+	models.LogUserAudit(userInfo.ID, "delete", "middleware", &m.ID, map[string]interface{}{
+		"app_id": m.AppID,
+		"name":   m.Name,
+		"type":   m.Type,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, nil, "Middleware deleted successfully", "")
+}
+
+func applyMiddlewareRequest(w http.ResponseWriter, m *models.AppMiddleware, req *middlewareRequest) bool {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name != "" {
+		m.Name = req.Name
+	}
+	if m.Name == "" {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Name is required", "Missing fields")
+		return false
+	}
+	if len(m.Name) > maxMiddlewareNameLength {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, fmt.Sprintf("Name can't be longer than %d characters", maxMiddlewareNameLength), "Invalid value")
+		return false
+	}
+	if req.Priority != nil {
+		m.Priority = *req.Priority
+	}
+	if req.Enabled != nil {
+		m.Enabled = *req.Enabled
+	}
+
+	if len(req.Config) > 0 {
+		config, err := middlewareConfig(m, req.Config)
+		if err != nil {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid config", err.Error())
+			return false
+		}
+		if err := m.SetConfig(config); err != nil {
+			handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to encode config", err.Error())
+			return false
+		}
+	}
+	return true
+}
+
+// validates the config of the middleware's type. values end up in docker labels, where
+// lists are comma separated
+func middlewareConfig(m *models.AppMiddleware, raw json.RawMessage) (interface{}, error) {
+	decode := func(v interface{}) error {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		return decoder.Decode(v)
+	}
+
+	switch m.Type {
+	case models.MiddlewareBasicAuth:
+		var req basicAuthRequest
+		if err := decode(&req); err != nil {
+			return nil, err
+		}
+		if len(req.Users) == 0 {
+			return nil, fmt.Errorf("at least one user is required")
+		}
+
+		// passwords left out keep their hash
+		var current models.BasicAuthConfig
+		if m.Config != "" {
+			_ = m.DecodeConfig(&current)
+		}
+		hashes := map[string]string{}
+		for _, user := range current.Users {
+			hashes[user.Username] = user.PasswordHash
+		}
+
+		config := models.BasicAuthConfig{Realm: strings.TrimSpace(req.Realm), RemoveHeader: req.RemoveHeader}
+		seen := map[string]bool{}
+		for _, user := range req.Users {
+			username := strings.TrimSpace(user.Username)
+			if username == "" || strings.ContainsAny(username, ":, ") {
+				return nil, fmt.Errorf("usernames can't be empty or contain colons, commas or spaces")
+			}
+			if seen[username] {
+				return nil, fmt.Errorf("user %s is listed twice", username)
+			}
+			seen[username] = true
+
+			hash := hashes[username]
+			if user.Password != "" {
+				hashed, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+				if err != nil {
+					return nil, fmt.Errorf("failed to hash password: %w", err)
+				}
+				hash = string(hashed)
+			}
+			if hash == "" {
+				return nil, fmt.Errorf("password is required for user %s", username)
+			}
+			config.Users = append(config.Users, models.BasicAuthUser{Username: username, PasswordHash: hash})
+		}
+		return config, nil
+
+	case models.MiddlewareIPAllowList:
+		var config models.IPAllowListConfig
+		if err := decode(&config); err != nil {
+			return nil, err
+		}
+		if len(config.SourceRange) == 0 {
+			return nil, fmt.Errorf("at least one IP or CIDR range is required")
+		}
+		for i, source := range config.SourceRange {
+			source = strings.TrimSpace(source)
+			if net.ParseIP(source) == nil {
+				if _, _, err := net.ParseCIDR(source); err != nil {
+					return nil, fmt.Errorf("%s is not an IP or CIDR range", source)
+				}
+			}
+			config.SourceRange[i] = source
+		}
+		if config.Depth < 0 || config.Depth > maxIPStrategyDepth {
+			return nil, fmt.Errorf("depth must be between 0 and %d", maxIPStrategyDepth)
+		}
+		return config, nil
+
+	case models.MiddlewareRateLimit:
+		var config models.RateLimitConfig
+		if err := decode(&config); err != nil {
+			return nil, err
+		}
+		if config.Average < 1 {
+			return nil, fmt.Errorf("average must be at least 1")
+		}
+		if config.Burst < 0 {
+			return nil, fmt.Errorf("burst can't be negative")
+		}
+		if config.Period != "" {
+			period, err := time.ParseDuration(config.Period)
+			if err != nil || period <= 0 {
+				return nil, fmt.Errorf("period must be a duration like 1s or 1m")
+			}
+		}
+		return config, nil
+
+	case models.MiddlewareHeaders:
+		var config models.HeadersConfig
+		if err := decode(&config); err != nil {
+			return nil, err
+		}
+		for _, headers := range []map[string]string{config.RequestHeaders, config.ResponseHeaders} {
+			for name, value := range headers {
+				if !headerName.MatchString(name) {
+					return nil, fmt.Errorf("%s is not a valid header name", name)
+				}
+				if strings.ContainsAny(value, "\r\n") {
+					return nil, fmt.Errorf("value of %s can't contain line breaks", name)
+				}
+			}
+		}
+		for _, list := range [][]string{config.CorsAllowOrigins, config.CorsAllowMethods, config.CorsAllowHeaders} {
+			if err := checkLabelList(list); err != nil {
+				return nil, err
+			}
+		}
+		if strings.ContainsAny(config.ReferrerPolicy+config.ContentSecurityPolicy, "\r\n") {
+			return nil, fmt.Errorf("header values can't contain line breaks")
+		}
+		if config.CorsMaxAge < 0 {
+			return nil, fmt.Errorf("corsMaxAge can't be negative")
+		}
+		return config, nil
+
+	case models.MiddlewareStripPrefix:
+		var config models.StripPrefixConfig
+		if err := decode(&config); err != nil {
+			return nil, err
+		}
+		if len(config.Prefixes) == 0 {
+			return nil, fmt.Errorf("at least one prefix is required")
+		}
+		for _, prefix := range config.Prefixes {
+			if !strings.HasPrefix(prefix, "/") {
+				return nil, fmt.Errorf("prefix %s must start with /", prefix)
+			}
+		}
+		if err := checkLabelList(config.Prefixes); err != nil {
+			return nil, err
+		}
+		return config, nil
+
+	case models.MiddlewareCompress:
+		var config models.CompressConfig
+		if err := decode(&config); err != nil {
+			return nil, err
+		}
+		if config.MinResponseBodyBytes < 0 {
+			return nil, fmt.Errorf("minResponseBodyBytes can't be negative")
+		}
+		if err := checkLabelList(config.ExcludedContentTypes); err != nil {
+			return nil, err
+		}
+		return config, nil
+	}
+	return nil, fmt.Errorf("unknown middleware type %s", m.Type)
+}
+
+func checkLabelList(values []string) error {
+	for _, value := range values {
+		if strings.TrimSpace(value) == "" || strings.ContainsAny(value, ",\r\n") {
+			return fmt.Errorf("%q can't be empty or contain commas or line breaks", value)
+		}
+	}
+	return nil
+}
+
+func getAccessibleMiddleware(w http.ResponseWriter, userID, middlewareID int64) (*models.AppMiddleware, *models.App, bool) {
+	if middlewareID == 0 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Middleware ID is required", "")
+		return nil, nil, false
+	}
+
+	m, err := models.GetAppMiddlewareByID(middlewareID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "Middleware not found", "")
+		return nil, nil, false
+	}
+	app, ok := getAccessibleApp(w, userID, m.AppID)
+	if !ok {
+		return nil, nil, false
+	}
+	return m, app, true
+}
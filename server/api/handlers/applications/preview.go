@@ -85,10 +85,10 @@ func GetPreviewURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	previewURL := "http://" + domain.Domain
 	handlers.SendResponse(w, http.StatusOK, true, map[string]interface{}{
-		"url":    previewURL,
-		"domain": domain.Domain,
-		"type":   "domain",
+		"url":        domain.URL(),
+		"domain":     domain.Domain,
+		"pathPrefix": domain.PathPrefix,
+		"type":       "domain",
 	}, "Preview URL retrieved successfully", "")
 }
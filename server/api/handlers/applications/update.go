@@ -33,11 +33,15 @@ func UpdateApplication(w http.ResponseWriter, r *http.Request) {
 		Port               *int     `json:"port"`
 		RootDirectory      *string  `json:"rootDirectory"`
 		DockerfilePath     *string  `json:"dockerfilePath"`
+		OutputDirectory    *string  `json:"outputDirectory"`
 		DeploymentStrategy *string  `json:"deploymentStrategy"`
 		Status             *string  `json:"status"`
 		CPULimit           *float64 `json:"cpuLimit"`
 		MemoryLimit        *int     `json:"memoryLimit"`
 		RestartPolicy      *string  `json:"restartPolicy"`
+		SourceType         *string  `json:"sourceType"`
+		Image              *string  `json:"image"`
+		PushRegistryID     *int64   `json:"pushRegistryId"` // 0 disables pushing
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -96,12 +100,36 @@ func UpdateApplication(w http.ResponseWriter, r *http.Request) {
 		app.Port = &port
 	}
 	if req.RootDirectory != nil {
-		app.RootDirectory = strings.TrimSpace(*req.RootDirectory)
+		root := strings.TrimSpace(*req.RootDirectory)
+		if root == "" {
+			root = "."
+		}
+		if err := docker.ValidateRepoPath(root); err != nil {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid root directory", err.Error())
+			return
+		}
+		app.RootDirectory = root
 	}
 	if req.DockerfilePath != nil {
 		trimmed := strings.TrimSpace(*req.DockerfilePath)
+		if trimmed != "" {
+			if err := docker.ValidateRepoPath(trimmed); err != nil {
+				handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid Dockerfile path", err.Error())
+				return
+			}
+		}
 		app.DockerfilePath = &trimmed
 	}
+	if req.OutputDirectory != nil {
+		trimmed := strings.TrimSpace(*req.OutputDirectory)
+		if trimmed != "" {
+			if err := docker.ValidateRepoPath(trimmed); err != nil {
+				handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid output directory", err.Error())
+				return
+			}
+		}
+		app.OutputDirectory = &trimmed
+	}
 	if req.DeploymentStrategy != nil {
 		app.DeploymentStrategy = models.DeploymentStrategy(strings.TrimSpace(*req.DeploymentStrategy))
 	}
@@ -117,6 +145,40 @@ func UpdateApplication(w http.ResponseWriter, r *http.Request) {
 	if req.RestartPolicy != nil {
 		app.RestartPolicy = models.RestartPolicy(strings.TrimSpace(*req.RestartPolicy))
 	}
+	if req.SourceType != nil {
+		sourceType := models.SourceType(strings.TrimSpace(*req.SourceType))
+		if sourceType != models.SourceTypeGit && sourceType != models.SourceTypeImage {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid source type", "Must be 'git' or 'image'")
+			return
+		}
+		app.SourceType = sourceType
+	}
+	if req.Image != nil {
+		trimmed := strings.TrimSpace(*req.Image)
+		if trimmed != "" {
+			if _, err := docker.ImageRegistryHost(trimmed); err != nil {
+				handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid image reference", err.Error())
+				return
+			}
+		}
+		app.Image = &trimmed
+	}
+	if app.IsImageSource() && (app.Image == nil || *app.Image == "") {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Image is required for image source apps", "Missing fields")
+		return
+	}
+	if req.PushRegistryID != nil {
+		if *req.PushRegistryID == 0 {
+			app.PushRegistryID = nil
+		} else {
+			registry, err := models.GetRegistryByID(*req.PushRegistryID)
+			if err != nil || registry == nil || registry.ProjectID != app.ProjectID {
+				handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Registry not found in this project", "Invalid registry")
+				return
+			}
+			app.PushRegistryID = req.PushRegistryID
+		}
+	}
 
 	app.UpdatedAt = time.Now()
 
@@ -156,9 +218,21 @@ func UpdateApplication(w http.ResponseWriter, r *http.Request) {
 	if req.Port != nil {
 		changes["port"] = *req.Port
 	}
+	if req.OutputDirectory != nil {
+		changes["output_directory"] = *req.OutputDirectory
+	}
 	if req.Status != nil {
 		changes["status"] = *req.Status
 	}
+	if req.SourceType != nil {
+		changes["source_type"] = *req.SourceType
+	}
+	if req.Image != nil {
+		changes["image"] = *req.Image
+	}
+	if req.PushRegistryID != nil {
+		changes["push_registry_id"] = *req.PushRegistryID
+	}
 	models.LogUserAudit(userInfo.ID, "update", "application", &app.ID, map[string]interface{}{
 		"changes": changes,
 	})
@@ -20,6 +20,7 @@ type CreateVolumeRequest struct {
 
 type UpdateVolumeRequest struct {
 	ID            int64  `json:"id"`
+	AppID         int64  `json:"appId"`
 	Name          string `json:"name"`
 	HostPath      string `json:"hostPath"`
 	ContainerPath string `json:"containerPath"`
@@ -27,7 +28,8 @@ type UpdateVolumeRequest struct {
 }
 
 type DeleteVolumeRequest struct {
-	ID int64 `json:"id"`
+	ID    int64 `json:"id"`
+	AppID int64 `json:"appId"`
 }
 
 func GetVolumes(w http.ResponseWriter, r *http.Request) {
@@ -172,6 +174,11 @@ func UpdateVolume(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.AppID != 0 && req.AppID != volume.AppID {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Volume does not belong to this application", "Application mismatch")
+		return
+	}
+
 	app, err := models.GetApplicationByID(volume.AppID)
 	if err != nil {
 		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to get application", err.Error())
@@ -237,6 +244,11 @@ func DeleteVolume(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.AppID != 0 && req.AppID != volume.AppID {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Volume does not belong to this application", "Application mismatch")
+		return
+	}
+
 	app, err := models.GetApplicationByID(volume.AppID)
 	if err != nil {
 		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to get application", err.Error())
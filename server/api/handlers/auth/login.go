@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/corecollectives/mist/api/handlers"
 	"github.com/corecollectives/mist/api/middleware"
@@ -38,14 +40,125 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the password isn't checked at all while locked, so guessing can't continue in the background.
+	// answered like an unknown email, so the lock doesn't tell which accounts exist
+	if user.IsLocked() {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Invalid email or password", "Unauthorized")
+		return
+	}
+
 	passwordMatch := user.MatchPassword(cred.Password)
 	if !passwordMatch {
+		recordFailedLogin(r, user, "invalid password")
 		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Invalid email or password", "Unauthorized")
 		return
 	}
-	token, err := middleware.GenerateJWT(user.ID, user.Email, user.Role)
+	if user.TwoFactorEnabled {
+		challenge, err := middleware.GenerateTwoFactorChallenge(user.ID)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to generate two factor challenge during login")
+			handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to generate token", "Internal Server Error")
+			return
+		}
+		handlers.SendResponse(w, http.StatusOK, true, map[string]interface{}{
+			"twoFactorRequired": true,
+			"challenge":         challenge,
+		}, "Two factor authentication required", "")
+		return
+	}
+
+	completeLogin(w, r, user, "password")
+}
+
+// second login step for users with two factor enabled, accepts a TOTP or a backup code
+func LoginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Challenge string `json:"challenge"`
+		Code      string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return
+	}
+	if req.Challenge == "" || strings.TrimSpace(req.Code) == "" {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Challenge and code are required", "Missing fields")
+		return
+	}
+
+	challenge, err := middleware.VerifyTwoFactorChallenge(req.Challenge)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to generate JWT token during login")
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Login expired, please sign in again", "Invalid challenge")
+		return
+	}
+
+	user, err := models.GetUserByID(challenge.UserID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Login expired, please sign in again", "Unauthorized")
+		return
+	}
+	if !user.TwoFactorEnabled {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Two factor authentication is not enabled", "")
+		return
+	}
+	if user.IsLocked() {
+		sendLocked(w, user)
+		return
+	}
+
+	// claimed before the code is checked, so a replayed challenge can't burn a backup code or try another code.
+	// a wrong code needs the password again
+	if !challenge.Use() {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Login expired, please sign in again", "Challenge already used")
+		return
+	}
+
+	method := "totp"
+	if !user.ValidateTOTP(req.Code) {
+		used, err := user.ConsumeBackupCode(req.Code)
+		if err != nil {
+			log.Error().Err(err).Int64("user_id", user.ID).Msg("Failed to check backup code")
+		}
+		if !used {
+			recordFailedLogin(r, user, "invalid two factor code")
+			handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Invalid two factor code, please sign in again", "Unauthorized")
+			return
+		}
+		method = "backup_code"
+	}
+
+	completeLogin(w, r, user, method)
+}
+
+// only sent once the password was verified, the login form answers locked accounts like unknown ones
+func sendLocked(w http.ResponseWriter, user *models.User) {
+	retryAfter := int(time.Until(*user.AccountLockedUntil).Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	handlers.SendResponse(w, http.StatusLocked, false, map[string]interface{}{
+		"lockedUntil": user.AccountLockedUntil,
+	}, "Account is temporarily locked after too many failed login attempts", "Account locked")
+}
+
+func recordFailedLogin(r *http.Request, user *models.User, reason string) {
+	lockedUntil, err := user.RecordFailedLogin()
+	if err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("Failed to record failed login")
+		return
+	}
+	if lockedUntil != nil {
+		log.Warn().Int64("user_id", user.ID).Time("locked_until", *lockedUntil).Msg("Account locked after failed logins")
+		models.LogUserAudit(user.ID, "lockout", "user", &user.ID, map[string]interface{}{
+			"reason":          reason,
+			"failed_attempts": user.FailedLoginAttempts,
+			"locked_until":    lockedUntil,
+			"ip":              middleware.ClientIP(r),
+		})
+	}
+}
+
+func completeLogin(w http.ResponseWriter, r *http.Request, user *models.User, method string) {
+	_, token, err := middleware.CreateSession(r, user)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create session during login")
 		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to generate token", "Internal Server Error")
 		return
 	}
@@ -66,8 +179,13 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		MaxAge:   3600 * 24 * 30,
 	})
 
+	if err := user.RecordSuccessfulLogin(middleware.ClientIP(r)); err != nil {
+		log.Error().Err(err).Int64("user_id", user.ID).Msg("Failed to record login")
+	}
+
 	models.LogUserAudit(user.ID, "login", "user", &user.ID, map[string]interface{}{
-		"email": user.Email,
+		"email":  user.Email,
+		"method": method,
 	})
 
 	handlers.SendResponse(w, http.StatusOK, true, user, "Login successful", "")
@@ -6,6 +6,7 @@ import (
 
 	"github.com/corecollectives/mist/api/middleware"
 	"github.com/corecollectives/mist/models"
+	"github.com/rs/zerolog/log"
 )
 
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
@@ -16,6 +17,15 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	// the route isn't behind the auth middleware, so the session is looked up from the cookie
+	if cookie, err := r.Cookie("mist_token"); err == nil {
+		if _, session, err := middleware.VerifySession(cookie.Value); err == nil {
+			if err := session.Revoke("logout"); err != nil {
+				log.Error().Err(err).Str("session_id", session.ID).Msg("Failed to revoke session on logout")
+			}
+		}
+	}
+
 	http.SetCookie(w, &http.Cookie{
 		Name:     "mist_token",
 		Value:    "",
@@ -21,7 +21,7 @@ func MeHandler(w http.ResponseWriter, r *http.Request) {
 
 	tokenStr := cookie.Value
 
-	claims, err := middleware.VerifyJWT(tokenStr)
+	claims, _, err := middleware.VerifySession(tokenStr)
 	if err != nil {
 		handlers.SendResponse(w, http.StatusOK, true, map[string]interface{}{"setupRequired": setupRequired, "user": nil}, "Invalid token", "")
 		return
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/models"
+)
+
+func GetSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	sessions, err := models.GetSessionsByUserID(user.ID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to fetch sessions", err.Error())
+		return
+	}
+
+	currentID := ""
+	if current := middleware.GetSession(r); current != nil {
+		currentID = current.ID
+	}
+
+	result := make([]map[string]interface{}, 0, len(sessions))
+	for i := range sessions {
+		data := sessions[i].ToJson()
+		data["current"] = sessions[i].ID == currentID
+		result = append(result, data)
+	}
+	handlers.SendResponse(w, http.StatusOK, true, result, "Sessions fetched successfully", "")
+}
+
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	sessionID := r.URL.Query().Get("id")
+	if sessionID == "" {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Session ID is required", "Missing 'id' parameter")
+		return
+	}
+
+	session, err := models.GetSessionByID(sessionID)
+	// sessions of other users are reported as missing so their ids can't be probed
+	if err != nil || session.UserID != user.ID {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "Session not found", "No such session")
+		return
+	}
+
+	if err := session.Revoke("revoked_by_user"); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to revoke session", err.Error())
+		return
+	}
+
+	models.LogUserAudit(user.ID, "revoke", "session", &user.ID, map[string]interface{}{
+		"session_id": session.ID,
+		"ip_address": session.IPAddress,
+		"browser":    session.Browser,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, nil, "Session revoked successfully", "")
+}
+
+// revokes all sessions but the one making the request, ?includeCurrent=true logs that one out as well
+func RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	keepSessionID := ""
+	if current := middleware.GetSession(r); current != nil && r.URL.Query().Get("includeCurrent") != "true" {
+		keepSessionID = current.ID
+	}
+
+	if err := models.RevokeOtherUserSessions(user.ID, keepSessionID, "revoked_by_user"); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to revoke sessions", err.Error())
+		return
+	}
+
+	models.LogUserAudit(user.ID, "revoke", "session", &user.ID, map[string]interface{}{
+		"all":             true,
+		"include_current": keepSessionID == "",
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, nil, "Sessions revoked successfully", "")
+}
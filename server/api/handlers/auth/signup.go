@@ -59,9 +59,9 @@ func SignUpHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := middleware.GenerateJWT(user.ID, user.Email, user.Role)
+	_, token, err := middleware.CreateSession(r, &user)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to generate JWT token during signup")
+		log.Error().Err(err).Msg("Failed to create session during signup")
 		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to generate token", "Internal Server Error")
 		return
 	}
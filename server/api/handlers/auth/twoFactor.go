@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/utils"
+)
+
+const totpIssuer = "Mist"
+
+// starts enrollment with a new secret, two factor is only enabled once EnableTwoFactor confirms a code
+func SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+	if user.TwoFactorEnabled {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Two factor authentication is already enabled", "")
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to generate secret", err.Error())
+		return
+	}
+	if err := user.SetPendingTwoFactorSecret(secret); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to save secret", err.Error())
+		return
+	}
+
+	handlers.SendResponse(w, http.StatusOK, true, map[string]interface{}{
+		"secret": secret,
+		"uri":    utils.TOTPProvisioningURI(secret, user.Email, totpIssuer),
+	}, "Scan the code with your authenticator app and confirm it with a code", "")
+}
+
+func EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return
+	}
+	if user.TwoFactorEnabled {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Two factor authentication is already enabled", "")
+		return
+	}
+	if user.TwoFactorSecret == nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Two factor setup has not been started", "")
+		return
+	}
+	if !user.ValidateTOTP(req.Code) {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid code", "Invalid code")
+		return
+	}
+
+	codes, err := user.EnableTwoFactor()
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to enable two factor authentication", err.Error())
+		return
+	}
+
+	models.LogUserAudit(user.ID, "enable_2fa", "user", &user.ID, nil)
+
+	// backup codes are only shown here and when regenerated
+	handlers.SendResponse(w, http.StatusOK, true, map[string]interface{}{
+		"backupCodes": codes,
+	}, "Two factor authentication enabled", "")
+}
+
+// disabling needs the password and a current code, a stolen session alone isn't enough
+func DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return
+	}
+	if !user.TwoFactorEnabled {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Two factor authentication is not enabled", "")
+		return
+	}
+	if !user.MatchPassword(req.Password) {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Password is incorrect", "Unauthorized")
+		return
+	}
+	if !verifySecondFactor(user, req.Code) {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Invalid two factor code", "Unauthorized")
+		return
+	}
+
+	if err := models.DisableTwoFactor(user.ID); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to disable two factor authentication", err.Error())
+		return
+	}
+
+	models.LogUserAudit(user.ID, "disable_2fa", "user", &user.ID, nil)
+
+	handlers.SendResponse(w, http.StatusOK, true, nil, "Two factor authentication disabled", "")
+}
+
+func RegenerateBackupCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return
+	}
+	if !user.TwoFactorEnabled {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Two factor authentication is not enabled", "")
+		return
+	}
+	if !user.ValidateTOTP(req.Code) {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Invalid two factor code", "Unauthorized")
+		return
+	}
+
+	codes, err := user.RegenerateBackupCodes()
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to generate backup codes", err.Error())
+		return
+	}
+
+	models.LogUserAudit(user.ID, "regenerate_backup_codes", "user", &user.ID, nil)
+
+	handlers.SendResponse(w, http.StatusOK, true, map[string]interface{}{
+		"backupCodes": codes,
+	}, "Backup codes regenerated", "")
+}
+
+func GetTwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	handlers.SendResponse(w, http.StatusOK, true, map[string]interface{}{
+		"enabled":              user.TwoFactorEnabled,
+		"remainingBackupCodes": user.RemainingBackupCodes(),
+	}, "Two factor status fetched successfully", "")
+}
+
+func verifySecondFactor(user *models.User, code string) bool {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return false
+	}
+	if user.ValidateTOTP(code) {
+		return true
+	}
+	used, _ := user.ConsumeBackupCode(code)
+	return used
+}
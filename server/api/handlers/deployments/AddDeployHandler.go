@@ -9,6 +9,7 @@ import (
 	"github.com/corecollectives/mist/github"
 	"github.com/corecollectives/mist/models"
 	"github.com/corecollectives/mist/queue"
+	"github.com/distribution/reference"
 	"github.com/rs/zerolog/log"
 )
 
@@ -34,10 +35,33 @@ func AddDeployHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	hasAccess, err := models.HasUserAccessToProject(user.ID, app.ProjectID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify project access", err.Error())
+		return
+	}
+	if !hasAccess {
+		handlers.SendResponse(w, http.StatusForbidden, false, nil, "Access denied", "You don't have access to this application")
+		return
+	}
+
 	var commitHash string
 	var commitMessage string
 
-	if app.AppType != models.AppTypeDatabase {
+	if app.IsImageSource() && app.AppType != models.AppTypeDatabase {
+		if app.Image == nil || *app.Image == "" {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, "app has no image configured", "")
+			return
+		}
+		// the tag stands in for the commit, it is used in the image and log file names
+		commitHash = "latest"
+		if named, err := reference.ParseNormalizedNamed(*app.Image); err == nil {
+			if tagged, ok := named.(reference.Tagged); ok {
+				commitHash = tagged.Tag()
+			}
+		}
+		commitMessage = "Deploy image " + *app.Image
+	} else if app.AppType != models.AppTypeDatabase {
 		userId := int64(user.ID)
 		commit, err := github.GetLatestCommit(int64(req.AppId), userId)
 		if err != nil {
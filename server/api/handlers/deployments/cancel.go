@@ -0,0 +1,89 @@
+package deployments
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/queue"
+	"github.com/rs/zerolog/log"
+)
+
+func CancelDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		DeploymentID int64 `json:"deploymentId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return
+	}
+	if req.DeploymentID == 0 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Deployment ID is required", "Missing fields")
+		return
+	}
+
+	dep, err := models.GetDeploymentByID(req.DeploymentID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "Deployment not found", err.Error())
+		return
+	}
+
+	app, err := models.GetApplicationByID(dep.AppID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to get application", err.Error())
+		return
+	}
+
+	hasAccess, err := models.HasUserAccessToProject(user.ID, app.ProjectID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify project access", err.Error())
+		return
+	}
+	if !hasAccess {
+		handlers.SendResponse(w, http.StatusForbidden, false, nil, "Access denied", "You don't have access to this deployment")
+		return
+	}
+
+	switch dep.Status {
+	case models.DeploymentStatusPending, models.DeploymentStatusCloning, models.DeploymentStatusBuilding, models.DeploymentStatusDeploying:
+	default:
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Only queued or in-progress deployments can be cancelled", "")
+		return
+	}
+
+	wasQueued, err := queue.GetQueue().Cancel(dep.ID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusConflict, false, nil, "Deployment is not running", err.Error())
+		return
+	}
+
+	// running deployments are marked cancelled by the worker once the current step stops
+	if wasQueued {
+		errMsg := "Deployment was cancelled"
+		if err := models.UpdateDeploymentStatus(dep.ID, string(models.DeploymentStatusCancelled), "cancelled", 0, &errMsg); err != nil {
+			handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to cancel deployment", err.Error())
+			return
+		}
+	}
+
+	log.Info().Int64("deployment_id", dep.ID).Bool("was_queued", wasQueued).Msg("Deployment cancelled")
+
+	models.LogUserAudit(user.ID, "cancel", "deployment", &dep.ID, map[string]interface{}{
+		"app_id":      dep.AppID,
+		"commit_hash": dep.CommitHash,
+		"status":      dep.Status,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, map[string]interface{}{
+		"deploymentId": dep.ID,
+		"wasQueued":    wasQueued,
+	}, "Deployment cancellation requested", "")
+}
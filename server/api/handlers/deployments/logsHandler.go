@@ -20,7 +20,11 @@ var upgrader = websocket.Upgrader{
 }
 
 func LogsHandler(w http.ResponseWriter, r *http.Request) {
-	depIdstr := r.URL.Query().Get("id")
+	// deploymentId lets project scoped api tokens resolve the project, id is kept for older clients
+	depIdstr := r.URL.Query().Get("deploymentId")
+	if depIdstr == "" {
+		depIdstr = r.URL.Query().Get("id")
+	}
 	depId, err := strconv.ParseInt(depIdstr, 10, 64)
 	if err != nil {
 		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "invalid deployment id", err.Error())
@@ -31,6 +35,14 @@ func LogsHandler(w http.ResponseWriter, r *http.Request) {
 		handlers.SendResponse(w, http.StatusNotFound, false, nil, "deployment not found", err.Error())
 		return
 	}
+	app, err := models.GetApplicationByID(dep.AppID)
+	if err != nil || app == nil {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "application not found", "")
+		return
+	}
+	if !websockets.AuthorizeProject(w, r, app.ProjectID) {
+		return
+	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -48,6 +60,7 @@ func LogsHandler(w http.ResponseWriter, r *http.Request) {
 	logPath := docker.GetLogsPath(dep.CommitHash, depId)
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
+	go websockets.WatchAccess(ctx, r, conn, &app.ProjectID, cancel)
 
 	events := make(chan websockets.DeploymentEvent, 100)
 
@@ -126,7 +139,7 @@ func LogsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if statusData, ok := event.Data.(websockets.StatusUpdate); ok {
-			if statusData.Status == "success" || statusData.Status == "failed" {
+			if statusData.Status == "success" || statusData.Status == "failed" || statusData.Status == "cancelled" {
 				time.Sleep(1 * time.Second)
 				cancel()
 				break
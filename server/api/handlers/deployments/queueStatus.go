@@ -0,0 +1,50 @@
+package deployments
+
+import (
+	"net/http"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/queue"
+)
+
+func GetQueueStatus(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	status := queue.GetQueue().Status()
+
+	// owners and admins see the whole queue, other users only the projects they are part of
+	if user.Role == "user" {
+		access := make(map[int64]bool)
+		hasAccess := func(projectID int64) bool {
+			allowed, checked := access[projectID]
+			if !checked {
+				allowed, _ = models.HasUserAccessToProject(user.ID, projectID)
+				access[projectID] = allowed
+			}
+			return allowed
+		}
+
+		queued := []queue.Job{}
+		for _, job := range status.Queued {
+			if hasAccess(job.ProjectID) {
+				queued = append(queued, job)
+			}
+		}
+		running := []queue.RunningJob{}
+		for _, job := range status.Running {
+			if hasAccess(job.ProjectID) {
+				running = append(running, job)
+			}
+		}
+		status.Queued = queued
+		status.Running = running
+	}
+
+	handlers.SendResponse(w, http.StatusOK, true, status, "Queue status retrieved successfully", "")
+}
@@ -0,0 +1,46 @@
+package deployments
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/queue"
+)
+
+func ReorderQueueHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+	if user.Role != "owner" && user.Role != "admin" {
+		handlers.SendResponse(w, http.StatusForbidden, false, nil, "Only owners and admins can reorder the deployment queue", "Forbidden")
+		return
+	}
+
+	var req struct {
+		DeploymentIDs []int64 `json:"deploymentIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return
+	}
+	if len(req.DeploymentIDs) == 0 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Deployment IDs are required", "Missing fields")
+		return
+	}
+
+	if err := queue.GetQueue().Reorder(req.DeploymentIDs); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Failed to reorder queue", err.Error())
+		return
+	}
+
+	models.LogUserAudit(user.ID, "reorder", "deployment_queue", nil, map[string]interface{}{
+		"deployment_ids": req.DeploymentIDs,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, queue.GetQueue().Status(), "Queue reordered successfully", "")
+}
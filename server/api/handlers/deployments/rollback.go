@@ -0,0 +1,110 @@
+package deployments
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/docker"
+	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/queue"
+	"github.com/rs/zerolog/log"
+)
+
+func RollbackHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		DeploymentID int64 `json:"deploymentId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return
+	}
+	if req.DeploymentID == 0 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Deployment ID is required", "Missing fields")
+		return
+	}
+
+	target, err := models.GetDeploymentByID(req.DeploymentID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "Deployment not found", err.Error())
+		return
+	}
+
+	app, err := models.GetApplicationByID(target.AppID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to get application", err.Error())
+		return
+	}
+
+	hasAccess, err := models.HasUserAccessToProject(user.ID, app.ProjectID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify project access", err.Error())
+		return
+	}
+	if !hasAccess {
+		handlers.SendResponse(w, http.StatusForbidden, false, nil, "Access denied", "You don't have access to this deployment")
+		return
+	}
+
+	if target.Status != models.DeploymentStatusSuccess && target.Status != models.DeploymentStatusRolledBack {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Only successful deployments can be rolled back to", "")
+		return
+	}
+	if target.ImageTag == nil || *target.ImageTag == "" {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Deployment has no stored image to roll back to", "")
+		return
+	}
+	if target.IsActive {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Deployment is already active", "")
+		return
+	}
+	// image cleanup only keeps the latest rollback targets, prebuilt images can be pulled again
+	if !app.IsImageSource() && !docker.ImageExists(*target.ImageTag) {
+		handlers.SendResponse(w, http.StatusGone, false, nil, "The image of this deployment has been cleaned up, it can't be rolled back to", "Image not found")
+		return
+	}
+
+	userID := user.ID
+	commitMessage := fmt.Sprintf("Rollback to deployment #%d", target.ID)
+	if target.DeploymentNumber != nil {
+		commitMessage = fmt.Sprintf("Rollback to deployment #%d", *target.DeploymentNumber)
+	}
+
+	deployment := models.Deployment{
+		AppID:          target.AppID,
+		CommitHash:     target.CommitHash,
+		CommitMessage:  &commitMessage,
+		CommitAuthor:   target.CommitAuthor,
+		TriggeredBy:    &userID,
+		ImageTag:       target.ImageTag,
+		RolledBackFrom: &target.ID,
+	}
+	if err := deployment.CreateDeployment(); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to create rollback deployment", err.Error())
+		return
+	}
+
+	if err := queue.GetQueue().AddJob(deployment.ID); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to add job to queue", err.Error())
+		return
+	}
+
+	log.Info().Int64("deployment_id", deployment.ID).Int64("rolled_back_from", target.ID).Msg("Rollback added to queue")
+
+	models.LogUserAudit(user.ID, "rollback", "deployment", &deployment.ID, map[string]interface{}{
+		"app_id":           deployment.AppID,
+		"rolled_back_from": target.ID,
+		"commit_hash":      target.CommitHash,
+		"image_tag":        *target.ImageTag,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, deployment, "Rollback queued successfully", "")
+}
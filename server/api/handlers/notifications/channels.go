@@ -0,0 +1,410 @@
+package notifications
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/notify"
+	"gorm.io/gorm"
+)
+
+const channelDeliveriesLimit = 50
+
+type channelRequest struct {
+	Name string                         `json:"name"`
+	Type models.NotificationChannelType `json:"type"`
+	// left empty on update to keep the stored url or secret
+	URL     string                    `json:"url"`
+	Secret  string                    `json:"secret"`
+	Email   string                    `json:"email"`
+	Events  []models.NotificationType `json:"events"`
+	Enabled *bool                     `json:"enabled"`
+}
+
+// project channels when projectId is set, the user's personal channels otherwise
+func GetChannels(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var channels []models.NotificationChannel
+	var err error
+	if projectIDStr := r.URL.Query().Get("projectId"); projectIDStr != "" {
+		projectID, err := strconv.ParseInt(projectIDStr, 10, 64)
+		if err != nil {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid project ID", "projectId must be a number")
+			return
+		}
+		if !requireProjectManager(w, userInfo, projectID) {
+			return
+		}
+		channels, err = models.GetProjectNotificationChannels(projectID)
+		if err != nil {
+			handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to get notification channels", err.Error())
+			return
+		}
+	} else {
+		channels, err = models.GetUserNotificationChannels(userInfo.ID)
+		if err != nil {
+			handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to get notification channels", err.Error())
+			return
+		}
+	}
+
+	channelsJSON := make([]map[string]interface{}, 0, len(channels))
+	for i := range channels {
+		channelsJSON = append(channelsJSON, channels[i].ToJson())
+	}
+	handlers.SendResponse(w, http.StatusOK, true, channelsJSON, "Notification channels retrieved successfully", "")
+}
+
+func CreateChannel(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		ProjectID *int64 `json:"projectId"`
+		channelRequest
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return
+	}
+
+	channel := &models.NotificationChannel{
+		Type:      req.Type,
+		Enabled:   true,
+		CreatedBy: &userInfo.ID,
+	}
+	if req.ProjectID != nil {
+		if !requireProjectManager(w, userInfo, *req.ProjectID) {
+			return
+		}
+		channel.ProjectID = req.ProjectID
+	} else {
+		channel.UserID = &userInfo.ID
+		// personal email channels go to the user's own address unless told otherwise
+		if req.Type == models.ChannelTypeEmail && strings.TrimSpace(req.Email) == "" {
+			req.Email = userInfo.Email
+		}
+	}
+
+	// webhooks are always signed, a secret is generated when none is given
+	if req.Type == models.ChannelTypeWebhook && req.Secret == "" {
+		secret := make([]byte, 32)
+		rand.Read(secret)
+		req.Secret = hex.EncodeToString(secret)
+	}
+	if !applyChannelRequest(w, channel, &req.channelRequest, true) {
+		return
+	}
+	if err := channel.InsertInDB(); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to create notification channel", err.Error())
+		return
+	}
+
+	models.LogUserAudit(userInfo.ID, "create", "notification_channel", &channel.ID, map[string]interface{}{
+		"name":       channel.Name,
+		"type":       channel.Type,
+		"project_id": channel.ProjectID,
+	})
+
+	// the secret is only shown once, receivers need it to verify signatures
+	channelJSON := channel.ToJson()
+	if channel.Type == models.ChannelTypeWebhook {
+		channelJSON["secret"] = req.Secret
+	}
+	handlers.SendResponse(w, http.StatusOK, true, channelJSON, "Notification channel created successfully", "")
+}
+
+func UpdateChannel(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		ChannelID int64 `json:"channelId"`
+		channelRequest
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return
+	}
+
+	channel, ok := getAccessibleChannel(w, userInfo, req.ChannelID)
+	if !ok {
+		return
+	}
+	if req.Type != "" && req.Type != channel.Type {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "The channel type can't be changed", "Invalid value")
+		return
+	}
+	req.Type = channel.Type
+	if !applyChannelRequest(w, channel, &req.channelRequest, false) {
+		return
+	}
+	if err := channel.UpdateChannel(); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to update notification channel", err.Error())
+		return
+	}
+
+	models.LogUserAudit(userInfo.ID, "update", "notification_channel", &channel.ID, map[string]interface{}{
+		"name":    channel.Name,
+		"type":    channel.Type,
+		"enabled": channel.Enabled,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, channel.ToJson(), "Notification channel updated successfully", "")
+}
+
+func DeleteChannel(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		ChannelID int64 `json:"channelId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return
+	}
+
+	channel, ok := getAccessibleChannel(w, userInfo, req.ChannelID)
+	if !ok {
+		return
+	}
+	if err := models.DeleteNotificationChannel(channel.ID); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to delete notification channel", err.Error())
+		return
+	}
+
+	models.LogUserAudit(userInfo.ID, "delete", "notification_channel", &channel.ID, map[string]interface{}{
+		"name": channel.Name,
+		"type": channel.Type,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, nil, "Notification channel deleted successfully", "")
+}
+
+// sends a test notification right away, without retries
+func TestChannel(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		ChannelID int64 `json:"channelId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return
+	}
+
+	channel, ok := getAccessibleChannel(w, userInfo, req.ChannelID)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	err := notify.Deliver(ctx, channel, &notify.Payload{
+		Event:     models.NotificationCustom,
+		Title:     "Test notification",
+		Message:   "This is a test notification from Mist. Your channel \"" + channel.Name + "\" is working.",
+		Priority:  models.PriorityNormal,
+		ProjectID: channel.ProjectID,
+		Timestamp: time.Now().UTC(),
+	}, 0)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Test notification failed", err.Error())
+		return
+	}
+
+	handlers.SendResponse(w, http.StatusOK, true, nil, "Test notification sent", "")
+}
+
+func GetChannelDeliveries(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	channelID, err := strconv.ParseInt(r.URL.Query().Get("channelId"), 10, 64)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid channel ID", "channelId is required")
+		return
+	}
+	channel, ok := getAccessibleChannel(w, userInfo, channelID)
+	if !ok {
+		return
+	}
+
+	deliveries, err := models.GetChannelDeliveries(channel.ID, channelDeliveriesLimit)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to get deliveries", err.Error())
+		return
+	}
+
+	deliveriesJSON := make([]map[string]interface{}, 0, len(deliveries))
+	for i := range deliveries {
+		deliveriesJSON = append(deliveriesJSON, deliveries[i].ToJson())
+	}
+	handlers.SendResponse(w, http.StatusOK, true, deliveriesJSON, "Deliveries retrieved successfully", "")
+}
+
+// validates the request and copies it onto the channel
+func applyChannelRequest(w http.ResponseWriter, channel *models.NotificationChannel, req *channelRequest, create bool) bool {
+	req.Name = strings.TrimSpace(req.Name)
+	req.URL = strings.TrimSpace(req.URL)
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Name == "" {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Name is required", "Missing fields")
+		return false
+	}
+
+	switch req.Type {
+	case models.ChannelTypeSlack, models.ChannelTypeDiscord, models.ChannelTypeWebhook:
+		if req.URL == "" && (create || channel.URL == "") {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, "URL is required", "Missing fields")
+			return false
+		}
+		if req.URL != "" {
+			u, err := url.Parse(req.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				handlers.SendResponse(w, http.StatusBadRequest, false, nil, "URL must be an http or https URL", "Invalid value")
+				return false
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			err = notify.ValidateTargetURL(ctx, req.URL)
+			cancel()
+			if err != nil {
+				handlers.SendResponse(w, http.StatusBadRequest, false, nil, "URL must point to a public address", err.Error())
+				return false
+			}
+		}
+	case models.ChannelTypeEmail:
+		if _, err := mail.ParseAddress(req.Email); err != nil {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, "A valid email address is required", "Invalid value")
+			return false
+		}
+	default:
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Type must be slack, discord, webhook or email", "Invalid value")
+		return false
+	}
+
+	for _, event := range req.Events {
+		if !models.IsNotificationType(event) {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Unknown event type: "+string(event), "Invalid value")
+			return false
+		}
+	}
+
+	channelURL, err := channel.DecryptedURL()
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to read channel", err.Error())
+		return false
+	}
+	secret, err := channel.DecryptedSecret()
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to read channel", err.Error())
+		return false
+	}
+	if req.URL != "" {
+		channelURL = req.URL
+	}
+	if req.Secret != "" {
+		secret = req.Secret
+	}
+	if req.Type == models.ChannelTypeEmail {
+		channelURL, secret = "", ""
+	} else {
+		req.Email = ""
+	}
+	if err := channel.SetCredentials(channelURL, secret); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to encrypt channel credentials", err.Error())
+		return false
+	}
+
+	channel.Name = req.Name
+	channel.Email = req.Email
+	channel.Events = req.Events
+	if channel.Events == nil {
+		channel.Events = []models.NotificationType{}
+	}
+	if req.Enabled != nil {
+		channel.Enabled = *req.Enabled
+	}
+	return true
+}
+
+func getAccessibleChannel(w http.ResponseWriter, user *models.User, channelID int64) (*models.NotificationChannel, bool) {
+	if channelID == 0 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Channel ID is required", "Missing fields")
+		return nil, false
+	}
+
+	channel, err := models.GetNotificationChannelByID(channelID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to get notification channel", err.Error())
+		return nil, false
+	}
+	if channel == nil {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "Notification channel not found", "")
+		return nil, false
+	}
+
+	if channel.ProjectID != nil {
+		if !requireProjectManager(w, user, *channel.ProjectID) {
+			return nil, false
+		}
+	} else if channel.UserID == nil || *channel.UserID != user.ID {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "Notification channel not found", "")
+		return nil, false
+	}
+	return channel, true
+}
+
+// project channels are managed by owners, admins and the project's owner, like registries
+func requireProjectManager(w http.ResponseWriter, user *models.User, projectID int64) bool {
+	if user.Role == "owner" || user.Role == "admin" {
+		return true
+	}
+	project, err := models.GetProjectByID(projectID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "Project not found", "no such project")
+		return false
+	} else if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify project access", err.Error())
+		return false
+	}
+	if project.OwnerID != user.ID {
+		handlers.SendResponse(w, http.StatusForbidden, false, nil, "Not authorized", "Forbidden")
+		return false
+	}
+	return true
+}
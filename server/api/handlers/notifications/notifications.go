@@ -0,0 +1,114 @@
+package notifications
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/models"
+)
+
+func GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+	notifications, err := models.GetNotificationsByUserID(userInfo.ID, unreadOnly)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to get notifications", err.Error())
+		return
+	}
+
+	unread := 0
+	notificationsJSON := make([]map[string]interface{}, 0, len(notifications))
+	for _, n := range notifications {
+		if !n.IsRead {
+			unread++
+		}
+		notificationsJSON = append(notificationsJSON, n.ToJson())
+	}
+
+	handlers.SendResponse(w, http.StatusOK, true, map[string]interface{}{
+		"notifications": notificationsJSON,
+		"unread":        unread,
+	}, "Notifications retrieved successfully", "")
+}
+
+func MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	n, ok := getOwnNotification(w, r, userInfo.ID)
+	if !ok {
+		return
+	}
+	if err := n.MarkAsRead(); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to mark notification as read", err.Error())
+		return
+	}
+
+	handlers.SendResponse(w, http.StatusOK, true, nil, "Notification marked as read", "")
+}
+
+func MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	if err := models.MarkAllAsRead(userInfo.ID); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to mark notifications as read", err.Error())
+		return
+	}
+
+	handlers.SendResponse(w, http.StatusOK, true, nil, "All notifications marked as read", "")
+}
+
+func DeleteNotification(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	n, ok := getOwnNotification(w, r, userInfo.ID)
+	if !ok {
+		return
+	}
+	if err := models.DeleteNotification(n.ID); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to delete notification", err.Error())
+		return
+	}
+
+	handlers.SendResponse(w, http.StatusOK, true, nil, "Notification deleted successfully", "")
+}
+
+// users can only change their own notifications, not ones shared by everyone
+func getOwnNotification(w http.ResponseWriter, r *http.Request, userID int64) (*models.Notification, bool) {
+	var req struct {
+		NotificationID int64 `json:"notificationId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return nil, false
+	}
+	if req.NotificationID == 0 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Notification ID is required", "Missing fields")
+		return nil, false
+	}
+
+	n, err := models.GetNotificationByID(req.NotificationID)
+	if err != nil || n.UserID == nil || *n.UserID != userID {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "Notification not found", "")
+		return nil, false
+	}
+	return n, true
+}
@@ -0,0 +1,238 @@
+package projects
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/models"
+	"gorm.io/gorm"
+)
+
+// registries are managed by the project owner or an instance admin, members can only list them
+func canManageRegistries(user *models.User, projectID int64) (bool, error) {
+	if user.Role == "owner" || user.Role == "admin" {
+		return true, nil
+	}
+	project, err := models.GetProjectByID(projectID)
+	if err != nil {
+		return false, err
+	}
+	return project.OwnerID == user.ID, nil
+}
+
+func validRegistryURL(registryURL string) bool {
+	host := strings.TrimPrefix(strings.TrimPrefix(registryURL, "https://"), "http://")
+	return host != "" && !strings.HasPrefix(host, "/") && !strings.ContainsAny(host, " \t")
+}
+
+func GetRegistries(w http.ResponseWriter, r *http.Request) {
+	userData, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	projectID, err := strconv.ParseInt(r.URL.Query().Get("projectId"), 10, 64)
+	if err != nil || projectID == 0 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid project ID", "projectId is required")
+		return
+	}
+
+	hasAccess, err := models.HasUserAccessToProject(userData.ID, projectID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify project access", err.Error())
+		return
+	}
+	if !hasAccess {
+		handlers.SendResponse(w, http.StatusForbidden, false, nil, "You do not have access to this project", "Forbidden")
+		return
+	}
+
+	registries, err := models.GetRegistriesByProjectID(projectID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to fetch registries", err.Error())
+		return
+	}
+
+	result := make([]map[string]interface{}, 0, len(registries))
+	for i := range registries {
+		result = append(result, registries[i].ToJson())
+	}
+	handlers.SendResponse(w, http.StatusOK, true, result, "Registries fetched successfully", "")
+}
+
+func CreateRegistry(w http.ResponseWriter, r *http.Request) {
+	userData, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		ProjectID   int64  `json:"projectId"`
+		RegistryURL string `json:"registryUrl"`
+		Username    string `json:"username"`
+		Password    string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return
+	}
+
+	req.RegistryURL = strings.TrimSuffix(strings.TrimSpace(req.RegistryURL), "/")
+	if req.ProjectID == 0 || req.RegistryURL == "" {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Project ID and registry URL are required", "Missing fields")
+		return
+	}
+	if !validRegistryURL(req.RegistryURL) {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid registry URL", "Invalid registry URL")
+		return
+	}
+
+	allowed, err := canManageRegistries(userData, req.ProjectID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "Project not found", "no such project")
+		return
+	} else if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify project access", err.Error())
+		return
+	}
+	if !allowed {
+		handlers.SendResponse(w, http.StatusForbidden, false, nil, "Not authorized", "Forbidden")
+		return
+	}
+
+	registry, err := models.CreateRegistry(req.ProjectID, req.RegistryURL, strings.TrimSpace(req.Username), req.Password)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to create registry", err.Error())
+		return
+	}
+
+	models.LogUserAudit(userData.ID, "create", "registry", &registry.ID, map[string]interface{}{
+		"project_id":   registry.ProjectID,
+		"registry_url": registry.RegistryURL,
+		"username":     registry.Username,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, registry.ToJson(), "Registry created successfully", "")
+}
+
+func UpdateRegistry(w http.ResponseWriter, r *http.Request) {
+	userData, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		ID          int64   `json:"id"`
+		RegistryURL string  `json:"registryUrl"`
+		Username    string  `json:"username"`
+		Password    *string `json:"password"` // omitted keeps the stored password
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return
+	}
+
+	req.RegistryURL = strings.TrimSuffix(strings.TrimSpace(req.RegistryURL), "/")
+	if req.ID == 0 || req.RegistryURL == "" {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Registry ID and registry URL are required", "Missing fields")
+		return
+	}
+	if !validRegistryURL(req.RegistryURL) {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid registry URL", "Invalid registry URL")
+		return
+	}
+
+	registry, err := models.GetRegistryByID(req.ID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to fetch registry", err.Error())
+		return
+	}
+	if registry == nil {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "Registry not found", "no such registry")
+		return
+	}
+
+	allowed, err := canManageRegistries(userData, registry.ProjectID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify project access", err.Error())
+		return
+	}
+	if !allowed {
+		handlers.SendResponse(w, http.StatusForbidden, false, nil, "Not authorized", "Forbidden")
+		return
+	}
+
+	if err := models.UpdateRegistry(req.ID, req.RegistryURL, strings.TrimSpace(req.Username), req.Password); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to update registry", err.Error())
+		return
+	}
+
+	updated, err := models.GetRegistryByID(req.ID)
+	if err != nil || updated == nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to fetch updated registry", "")
+		return
+	}
+
+	models.LogUserAudit(userData.ID, "update", "registry", &updated.ID, map[string]interface{}{
+		"project_id":       updated.ProjectID,
+		"registry_url":     updated.RegistryURL,
+		"username":         updated.Username,
+		"password_changed": req.Password != nil,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, updated.ToJson(), "Registry updated successfully", "")
+}
+
+func DeleteRegistry(w http.ResponseWriter, r *http.Request) {
+	userData, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	registryID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || registryID == 0 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid registry ID", "id is required")
+		return
+	}
+
+	registry, err := models.GetRegistryByID(registryID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to fetch registry", err.Error())
+		return
+	}
+	if registry == nil {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "Registry not found", "no such registry")
+		return
+	}
+
+	allowed, err := canManageRegistries(userData, registry.ProjectID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify project access", err.Error())
+		return
+	}
+	if !allowed {
+		handlers.SendResponse(w, http.StatusForbidden, false, nil, "Not authorized", "Forbidden")
+		return
+	}
+
+	if err := models.DeleteRegistry(registryID); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to delete registry", err.Error())
+		return
+	}
+
+	models.LogUserAudit(userData.ID, "delete", "registry", &registryID, map[string]interface{}{
+		"project_id":   registry.ProjectID,
+		"registry_url": registry.RegistryURL,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, nil, "Registry deleted successfully", "")
+}
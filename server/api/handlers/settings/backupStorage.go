@@ -0,0 +1,151 @@
+package settings
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/backup"
+	"github.com/corecollectives/mist/models"
+)
+
+type backupStorageRequest struct {
+	Type        models.StorageType `json:"type"`
+	S3Endpoint  string             `json:"s3Endpoint"`
+	S3Bucket    string             `json:"s3Bucket"`
+	S3Region    string             `json:"s3Region"`
+	S3Prefix    string             `json:"s3Prefix"`
+	S3PathStyle bool               `json:"s3PathStyle"`
+	S3AccessKey string             `json:"s3AccessKey"`
+	// left empty to keep the stored secret key
+	S3SecretKey string `json:"s3SecretKey"`
+}
+
+func GetBackupStorage(w http.ResponseWriter, r *http.Request) {
+	if !requireOwner(w, r) {
+		return
+	}
+
+	storage, err := models.GetBackupStorageSettings()
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to retrieve backup storage settings", err.Error())
+		return
+	}
+
+	handlers.SendResponse(w, http.StatusOK, true, storage.ToJson(), "Backup storage settings retrieved successfully", "")
+}
+
+// saves the storage new backups are written to, s3 settings are tested before they are saved
+func UpdateBackupStorage(w http.ResponseWriter, r *http.Request) {
+	if !requireOwner(w, r) {
+		return
+	}
+	userInfo, _ := middleware.GetUser(r)
+
+	storage, ok := decodeBackupStorage(w, r)
+	if !ok {
+		return
+	}
+
+	if storage.Type == models.StorageTypeS3 {
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+		if err := backup.TestStorage(ctx, storage); err != nil {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Failed to connect to S3 storage", err.Error())
+			return
+		}
+	}
+
+	if err := models.UpdateBackupStorageSettings(storage); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to update backup storage settings", err.Error())
+		return
+	}
+
+	dummyID := int64(1)
+	models.LogUserAudit(userInfo.ID, "update", "backup_storage", &dummyID, map[string]interface{}{
+		"type":     storage.Type,
+		"endpoint": storage.S3Endpoint,
+		"bucket":   storage.S3Bucket,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, storage.ToJson(), "Backup storage settings updated successfully", "")
+}
+
+func TestBackupStorage(w http.ResponseWriter, r *http.Request) {
+	if !requireOwner(w, r) {
+		return
+	}
+
+	storage, ok := decodeBackupStorage(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	if err := backup.TestStorage(ctx, storage); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Storage test failed", err.Error())
+		return
+	}
+
+	handlers.SendResponse(w, http.StatusOK, true, nil, "Storage is working", "")
+}
+
+func decodeBackupStorage(w http.ResponseWriter, r *http.Request) (*models.BackupStorageSettings, bool) {
+	var req backupStorageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return nil, false
+	}
+	if req.Type == "" {
+		req.Type = models.StorageTypeLocal
+	}
+	if req.Type != models.StorageTypeLocal && req.Type != models.StorageTypeS3 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Storage type must be local or s3", "Invalid value")
+		return nil, false
+	}
+
+	secretKey := req.S3SecretKey
+	if secretKey == "" {
+		current, err := models.GetBackupStorageSettings()
+		if err != nil {
+			handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to retrieve backup storage settings", err.Error())
+			return nil, false
+		}
+		secretKey = current.S3SecretKey
+	}
+
+	return &models.BackupStorageSettings{
+		Type:        req.Type,
+		S3Endpoint:  strings.TrimSpace(req.S3Endpoint),
+		S3Bucket:    strings.TrimSpace(req.S3Bucket),
+		S3Region:    strings.TrimSpace(req.S3Region),
+		S3Prefix:    strings.Trim(strings.TrimSpace(req.S3Prefix), "/"),
+		S3PathStyle: req.S3PathStyle,
+		S3AccessKey: strings.TrimSpace(req.S3AccessKey),
+		S3SecretKey: secretKey,
+	}, true
+}
+
+func requireOwner(w http.ResponseWriter, r *http.Request) bool {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return false
+	}
+
+	role, err := models.GetUserRole(userInfo.ID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify user role", err.Error())
+		return false
+	}
+	if role != "owner" {
+		handlers.SendResponse(w, http.StatusForbidden, false, nil, "Only owners can manage these settings", "Forbidden")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package settings
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/docker"
+	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type dnsProviderRequest struct {
+	Provider models.DNSProvider `json:"provider"`
+	// secrets left empty keep the stored value
+	Credentials map[string]string `json:"credentials"`
+}
+
+func GetDNSProvider(w http.ResponseWriter, r *http.Request) {
+	if !requireOwner(w, r) {
+		return
+	}
+
+	settings, err := models.GetDNSProviderSettings()
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to retrieve DNS provider settings", err.Error())
+		return
+	}
+
+	data := settings.ToJson()
+	data["providers"] = models.DNSProviderCredentials
+	handlers.SendResponse(w, http.StatusOK, true, data, "DNS provider settings retrieved successfully", "")
+}
+
+// configures the dns-01 resolver traefik uses for wildcard certificates. traefik is recreated to
+// load the credentials
+func UpdateDNSProvider(w http.ResponseWriter, r *http.Request) {
+	if !requireOwner(w, r) {
+		return
+	}
+	userInfo, _ := middleware.GetUser(r)
+
+	var req dnsProviderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return
+	}
+
+	fields, ok := models.DNSProviderCredentials[req.Provider]
+	if !ok {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Provider must be cloudflare, route53, digitalocean or rfc2136", "Invalid value")
+		return
+	}
+
+	current, err := models.GetDNSProviderSettings()
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to retrieve DNS provider settings", err.Error())
+		return
+	}
+
+	settings := &models.DNSProviderSettings{Provider: req.Provider, Credentials: map[string]string{}}
+	for _, field := range fields {
+		value := strings.TrimSpace(req.Credentials[field.Field])
+		if value == "" && field.Secret && current.Provider == req.Provider {
+			value = current.Credentials[field.Field]
+		}
+		if value == "" {
+			if field.Required {
+				handlers.SendResponse(w, http.StatusBadRequest, false, nil, field.Field+" is required", "Missing fields")
+				return
+			}
+			continue
+		}
+		// values end up single quoted in traefik's env file
+		if strings.ContainsAny(value, "'\r\n") {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, field.Field+" can't contain quotes or line breaks", "Invalid value")
+			return
+		}
+		settings.Credentials[field.Field] = value
+	}
+
+	if !applyDNSProvider(w, settings) {
+		return
+	}
+
+	dummyID := int64(1)
+	models.LogUserAudit(userInfo.ID, "update", "dns_provider", &dummyID, map[string]interface{}{
+		"provider": settings.Provider,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, settings.ToJson(), "DNS provider settings updated successfully", "")
+}
+
+// removes the dns provider, wildcard subdomains go back to one http-01 certificate each
+func DeleteDNSProvider(w http.ResponseWriter, r *http.Request) {
+	if !requireOwner(w, r) {
+		return
+	}
+	userInfo, _ := middleware.GetUser(r)
+
+	settings := &models.DNSProviderSettings{Credentials: map[string]string{}}
+	if !applyDNSProvider(w, settings) {
+		return
+	}
+
+	dummyID := int64(1)
+	models.LogUserAudit(userInfo.ID, "delete", "dns_provider", &dummyID, nil)
+
+	handlers.SendResponse(w, http.StatusOK, true, settings.ToJson(), "DNS provider removed successfully", "")
+}
+
+func applyDNSProvider(w http.ResponseWriter, settings *models.DNSProviderSettings) bool {
+	if err := utils.ConfigureDNSChallenge(string(settings.Provider), settings.Env()); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to update Traefik configuration", err.Error())
+		return false
+	}
+	if err := models.UpdateDNSProviderSettings(settings); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to update DNS provider settings", err.Error())
+		return false
+	}
+	if err := docker.SyncTraefikConfig(); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to generate Traefik configuration", err.Error())
+		return false
+	}
+	if err := utils.RecreateTraefik(); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Settings saved but Traefik could not be restarted", err.Error())
+		return false
+	}
+
+	// routers of existing apps switch resolver once their containers get the new labels
+	go func() {
+		if err := docker.RecreateWildcardApps(); err != nil {
+			log.Error().Err(err).Msg("Failed to recreate apps on the wildcard domain")
+		}
+	}()
+	return true
+}
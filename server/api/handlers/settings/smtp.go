@@ -0,0 +1,134 @@
+package settings
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/notify"
+)
+
+type smtpRequest struct {
+	Host     string              `json:"host"`
+	Port     int                 `json:"port"`
+	Security models.SMTPSecurity `json:"security"`
+	Username string              `json:"username"`
+	// left empty to keep the stored password
+	Password string `json:"password"`
+	From     string `json:"from"`
+}
+
+func GetSMTPSettings(w http.ResponseWriter, r *http.Request) {
+	if !requireOwner(w, r) {
+		return
+	}
+
+	settings, err := models.GetSMTPSettings()
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to retrieve SMTP settings", err.Error())
+		return
+	}
+
+	handlers.SendResponse(w, http.StatusOK, true, settings.ToJson(), "SMTP settings retrieved successfully", "")
+}
+
+func UpdateSMTPSettings(w http.ResponseWriter, r *http.Request) {
+	if !requireOwner(w, r) {
+		return
+	}
+	userInfo, _ := middleware.GetUser(r)
+
+	settings, ok := decodeSMTPSettings(w, r)
+	if !ok {
+		return
+	}
+	if err := models.UpdateSMTPSettings(settings); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to update SMTP settings", err.Error())
+		return
+	}
+
+	dummyID := int64(1)
+	models.LogUserAudit(userInfo.ID, "update", "smtp_settings", &dummyID, map[string]interface{}{
+		"host": settings.Host,
+		"port": settings.Port,
+		"from": settings.From,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, settings.ToJson(), "SMTP settings updated successfully", "")
+}
+
+// sends a test email to the current user with the given settings, without saving them
+func TestSMTPSettings(w http.ResponseWriter, r *http.Request) {
+	if !requireOwner(w, r) {
+		return
+	}
+	userInfo, _ := middleware.GetUser(r)
+
+	settings, ok := decodeSMTPSettings(w, r)
+	if !ok {
+		return
+	}
+	if err := notify.SendMail(settings, userInfo.Email, "Mist test email",
+		"This is a test email from Mist. Your SMTP settings are working."); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Failed to send test email", err.Error())
+		return
+	}
+
+	handlers.SendResponse(w, http.StatusOK, true, nil, "Test email sent to "+userInfo.Email, "")
+}
+
+func decodeSMTPSettings(w http.ResponseWriter, r *http.Request) (*models.SMTPSettings, bool) {
+	var req smtpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return nil, false
+	}
+
+	req.Host = strings.TrimSpace(req.Host)
+	req.From = strings.TrimSpace(req.From)
+	if req.Host == "" {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Host is required", "Missing fields")
+		return nil, false
+	}
+	if _, err := mail.ParseAddress(req.From); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "From must be a valid email address", "Invalid value")
+		return nil, false
+	}
+	if req.Port == 0 {
+		req.Port = 587
+	}
+	if req.Port < 1 || req.Port > 65535 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Port must be between 1 and 65535", "Invalid value")
+		return nil, false
+	}
+	if req.Security == "" {
+		req.Security = models.SMTPSecurityStartTLS
+	}
+	if req.Security != models.SMTPSecurityStartTLS && req.Security != models.SMTPSecurityTLS && req.Security != models.SMTPSecurityNone {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Security must be starttls, tls or none", "Invalid value")
+		return nil, false
+	}
+
+	password := req.Password
+	if password == "" {
+		current, err := models.GetSMTPSettings()
+		if err != nil {
+			handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to retrieve SMTP settings", err.Error())
+			return nil, false
+		}
+		password = current.Password
+	}
+
+	return &models.SMTPSettings{
+		Host:     req.Host,
+		Port:     req.Port,
+		Security: req.Security,
+		Username: strings.TrimSpace(req.Username),
+		Password: password,
+		From:     req.From,
+	}, true
+}
@@ -2,14 +2,19 @@ package settings
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/corecollectives/mist/api/handlers"
 	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/docker"
 	"github.com/corecollectives/mist/models"
-	"github.com/corecollectives/mist/utils"
+	"github.com/corecollectives/mist/queue"
 )
 
+// let's encrypt certificates last 90 days
+const maxSslExpiryWarningDays = 90
+
 func GetSystemSettings(w http.ResponseWriter, r *http.Request) {
 	userInfo, ok := middleware.GetUser(r)
 	if !ok {
@@ -61,6 +66,8 @@ func UpdateSystemSettings(w http.ResponseWriter, r *http.Request) {
 		SecureCookies         *bool   `json:"secureCookies"`
 		AutoCleanupContainers *bool   `json:"autoCleanupContainers"`
 		AutoCleanupImages     *bool   `json:"autoCleanupImages"`
+		DeploymentWorkers     *int    `json:"deploymentWorkers"`
+		SslExpiryWarningDays  *int    `json:"sslExpiryWarningDays"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -163,7 +170,44 @@ func UpdateSystemSettings(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err := utils.GenerateDynamicConfig(settings.WildcardDomain, settings.MistAppName); err != nil {
+	if req.DeploymentWorkers != nil {
+		if *req.DeploymentWorkers < 1 || *req.DeploymentWorkers > queue.MaxWorkers {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, fmt.Sprintf("Deployment workers must be between 1 and %d", queue.MaxWorkers), "Invalid value")
+			return
+		}
+
+		if err := models.UpdateDeploymentWorkers(*req.DeploymentWorkers); err != nil {
+			handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to update deployment workers", err.Error())
+			return
+		}
+		queue.GetQueue().SetWorkers(*req.DeploymentWorkers)
+
+		settings, err = models.GetSystemSettings()
+		if err != nil {
+			handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to retrieve updated settings", err.Error())
+			return
+		}
+	}
+
+	if req.SslExpiryWarningDays != nil {
+		if *req.SslExpiryWarningDays < 0 || *req.SslExpiryWarningDays > maxSslExpiryWarningDays {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, fmt.Sprintf("SSL expiry warning days must be between 0 and %d", maxSslExpiryWarningDays), "Invalid value")
+			return
+		}
+
+		if err := models.UpdateSslExpiryWarningDays(*req.SslExpiryWarningDays); err != nil {
+			handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to update SSL expiry warning days", err.Error())
+			return
+		}
+
+		settings, err = models.GetSystemSettings()
+		if err != nil {
+			handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to retrieve updated settings", err.Error())
+			return
+		}
+	}
+
+	if err := docker.SyncTraefikConfig(); err != nil {
 		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to generate Traefik configuration", err.Error())
 		return
 	}
@@ -191,6 +235,12 @@ func UpdateSystemSettings(w http.ResponseWriter, r *http.Request) {
 	if req.AutoCleanupImages != nil {
 		auditData["autoCleanupImages"] = *req.AutoCleanupImages
 	}
+	if req.DeploymentWorkers != nil {
+		auditData["deploymentWorkers"] = *req.DeploymentWorkers
+	}
+	if req.SslExpiryWarningDays != nil {
+		auditData["sslExpiryWarningDays"] = *req.SslExpiryWarningDays
+	}
 	models.LogUserAudit(userInfo.ID, "update", "system_settings", &dummyID, auditData)
 
 	handlers.SendResponse(w, http.StatusOK, true, settings, "System settings updated successfully", "")
@@ -3,6 +3,7 @@ package updates
 import (
 	"bufio"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"os"
 	"os/exec"
@@ -13,6 +14,7 @@ import (
 	"github.com/corecollectives/mist/api/handlers"
 	"github.com/corecollectives/mist/api/middleware"
 	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/notify"
 	"github.com/rs/zerolog/log"
 )
 
@@ -173,6 +175,9 @@ func TriggerUpdate(w http.ResponseWriter, r *http.Request) {
 			Str("from_version", currentVersion).
 			Str("to_version", targetVersion).
 			Msg("Update failed")
+		notify.Send(notify.SystemEvent(models.NotificationSystemUpdate, models.PriorityHigh,
+			"Mist update failed",
+			fmt.Sprintf("Updating Mist from %s to %s failed: %s", currentVersion, targetVersion, err.Error()), "/updates"))
 
 		dummyID := int64(1)
 		models.LogUserAudit(userInfo.ID, "update", "system", &dummyID, map[string]any{
@@ -213,6 +218,9 @@ func TriggerUpdate(w http.ResponseWriter, r *http.Request) {
 		Str("to_version", newVersion).
 		Int64("user_id", userInfo.ID).
 		Msg("Update completed successfully")
+	notify.Send(notify.SystemEvent(models.NotificationSystemUpdate, models.PriorityNormal,
+		"Mist updated",
+		fmt.Sprintf("Mist was updated from %s to %s.", currentVersion, newVersion), "/updates"))
 
 	dummyID := int64(1)
 	models.LogUserAudit(userInfo.ID, "update", "system", &dummyID, map[string]any{
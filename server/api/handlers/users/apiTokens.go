@@ -0,0 +1,166 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/models"
+	"gorm.io/gorm"
+)
+
+func GetApiTokens(w http.ResponseWriter, r *http.Request) {
+	userData, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	tokens, err := models.GetApiTokensByUserID(userData.ID)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to fetch API tokens", err.Error())
+		return
+	}
+
+	result := make([]map[string]interface{}, 0, len(tokens))
+	for i := range tokens {
+		result = append(result, tokens[i].ToJson())
+	}
+	handlers.SendResponse(w, http.StatusOK, true, result, "API tokens fetched successfully", "")
+}
+
+func CreateApiToken(w http.ResponseWriter, r *http.Request) {
+	userData, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	var req struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ProjectID     *int64   `json:"projectId"`
+		ExpiresInDays *int     `json:"expiresInDays"` // omitted or 0 never expires
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid request body", "Could not parse JSON")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Token name is required", "Missing fields")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "At least one scope is required", "Missing fields")
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !models.IsValidApiTokenScope(scope) {
+			handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid scope: "+scope, "Must be one of "+strings.Join(models.ApiTokenScopes, ", "))
+			return
+		}
+	}
+
+	if req.ProjectID != nil {
+		hasAccess, err := models.HasUserAccessToProject(userData.ID, *req.ProjectID)
+		if err != nil {
+			handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to verify project access", err.Error())
+			return
+		}
+		if !hasAccess {
+			handlers.SendResponse(w, http.StatusForbidden, false, nil, "You do not have access to this project", "Forbidden")
+			return
+		}
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays != nil && *req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	plaintext, hash, err := models.GenerateApiToken()
+	if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to generate token", err.Error())
+		return
+	}
+
+	scopes := strings.Join(req.Scopes, ",")
+	token := &models.ApiToken{
+		UserID:      userData.ID,
+		Name:        req.Name,
+		TokenHash:   hash,
+		TokenPrefix: plaintext[:len(models.ApiTokenPrefix)+8],
+		Scopes:      &scopes,
+		ProjectID:   req.ProjectID,
+		ExpiresAt:   expiresAt,
+	}
+	if err := token.InsertInDB(); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to create API token", err.Error())
+		return
+	}
+
+	models.LogUserAudit(userData.ID, "create", "api_token", &token.ID, map[string]interface{}{
+		"name":       token.Name,
+		"scopes":     req.Scopes,
+		"project_id": token.ProjectID,
+		"expires_at": token.ExpiresAt,
+	})
+
+	// the plaintext is not stored, this response is the only time it can be seen
+	data := token.ToJson()
+	data["token"] = plaintext
+	handlers.SendResponse(w, http.StatusOK, true, data, "API token created successfully", "")
+}
+
+func RevokeApiToken(w http.ResponseWriter, r *http.Request) {
+	userData, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+
+	tokenID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid token ID", "id is required")
+		return
+	}
+
+	token, err := models.GetApiTokenByID(tokenID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "API token not found", "no such token")
+		return
+	} else if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to fetch API token", err.Error())
+		return
+	}
+
+	// owners and admins can revoke any token, e.g. one that leaked from a departed user's CI
+	if token.UserID != userData.ID && userData.Role != "owner" && userData.Role != "admin" {
+		handlers.SendResponse(w, http.StatusForbidden, false, nil, "Not authorized", "Forbidden")
+		return
+	}
+	if token.RevokedAt != nil {
+		handlers.SendResponse(w, http.StatusOK, true, nil, "API token already revoked", "")
+		return
+	}
+
+	if err := token.Revoke(); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to revoke API token", err.Error())
+		return
+	}
+
+	models.LogUserAudit(userData.ID, "revoke", "api_token", &token.ID, map[string]interface{}{
+		"name":     token.Name,
+		"owner_id": token.UserID,
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, nil, "API token revoked successfully", "")
+}
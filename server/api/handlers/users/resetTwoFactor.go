@@ -0,0 +1,53 @@
+package users
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/models"
+	"gorm.io/gorm"
+)
+
+// lets an admin help a user who lost their authenticator and backup codes
+func ResetUserTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userData, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+	if userData.Role != "owner" && userData.Role != "admin" {
+		handlers.SendResponse(w, http.StatusForbidden, false, nil, "Not authorized", "Forbidden")
+		return
+	}
+
+	userID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid user ID", "User ID must be an integer")
+		return
+	}
+
+	targetRole, err := models.GetUserRole(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && targetRole == "") {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "User not found", "No such user")
+		return
+	} else if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to retrieve user role", err.Error())
+		return
+	}
+	if userData.Role == "admin" && userData.ID != userID && (targetRole == "owner" || targetRole == "admin") {
+		handlers.SendResponse(w, http.StatusForbidden, false, nil, "Not authorized to reset this user", "Forbidden")
+		return
+	}
+
+	if err := models.DisableTwoFactor(userID); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to reset two factor authentication", err.Error())
+		return
+	}
+
+	models.LogUserAudit(userData.ID, "reset_2fa", "user", &userID, nil)
+
+	handlers.SendResponse(w, http.StatusOK, true, nil, "Two factor authentication reset successfully", "")
+}
@@ -0,0 +1,53 @@
+package users
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/models"
+	"gorm.io/gorm"
+)
+
+// clears a lockout caused by failed logins before it runs out
+func UnlockUser(w http.ResponseWriter, r *http.Request) {
+	userData, ok := middleware.GetUser(r)
+	if !ok {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Not logged in", "Unauthorized")
+		return
+	}
+	if userData.Role != "owner" && userData.Role != "admin" {
+		handlers.SendResponse(w, http.StatusForbidden, false, nil, "Not authorized", "Forbidden")
+		return
+	}
+
+	userID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		handlers.SendResponse(w, http.StatusBadRequest, false, nil, "Invalid user ID", "User ID must be an integer")
+		return
+	}
+
+	user, err := models.GetUserByID(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		handlers.SendResponse(w, http.StatusNotFound, false, nil, "User not found", "No such user")
+		return
+	} else if err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to retrieve user", err.Error())
+		return
+	}
+
+	if err := models.UnlockUser(userID); err != nil {
+		handlers.SendResponse(w, http.StatusInternalServerError, false, nil, "Failed to unlock user", err.Error())
+		return
+	}
+
+	models.LogUserAudit(userData.ID, "unlock", "user", &userID, map[string]interface{}{
+		"username":        user.Username,
+		"failed_attempts": user.FailedLoginAttempts,
+		"was_locked":      user.IsLocked(),
+	})
+
+	handlers.SendResponse(w, http.StatusOK, true, nil, "User unlocked successfully", "")
+}
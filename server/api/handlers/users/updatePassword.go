@@ -5,7 +5,9 @@ import (
 	"net/http"
 
 	"github.com/corecollectives/mist/api/handlers"
+	"github.com/corecollectives/mist/api/middleware"
 	"github.com/corecollectives/mist/models"
+	"github.com/rs/zerolog/log"
 )
 
 type UpdatePasswordRequest struct {
@@ -52,6 +54,15 @@ func UpdatePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// anyone who knew the old password is logged out, only the session changing it stays valid
+	keepSessionID := ""
+	if session := middleware.GetSession(r); session != nil && session.UserID == req.UserID {
+		keepSessionID = session.ID
+	}
+	if err := models.RevokeOtherUserSessions(req.UserID, keepSessionID, "password_change"); err != nil {
+		log.Error().Err(err).Int64("user_id", req.UserID).Msg("Failed to revoke sessions after password change")
+	}
+
 	models.LogUserAudit(req.UserID, "update", "user", &req.UserID, map[string]interface{}{
 		"action": "password_change",
 	})
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -11,7 +13,8 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func InitApiServer() {
+// serves until ctx is cancelled, then waits a few seconds for open requests to finish
+func InitApiServer(ctx context.Context) {
 	mux := http.NewServeMux()
 	RegisterRoutes(mux)
 
@@ -33,8 +36,19 @@ func InitApiServer() {
 		Handler:           handler,
 		ReadHeaderTimeout: 5 * time.Second,
 	}
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Warn().Err(err).Msg("Server did not shut down cleanly")
+		}
+	}()
 	log.Info().Msg("Server is running on port 8080")
-	if err := server.ListenAndServe(); err != nil {
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal().Err(err).Msg("Server failed to start")
 	}
+	<-shutdownDone
 }
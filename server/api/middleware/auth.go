@@ -1,9 +1,19 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"io"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/corecollectives/mist/api/handlers"
@@ -24,7 +34,7 @@ func InitJWTSecret() error {
 	return nil
 }
 
-func GenerateJWT(userID int64, email, role string) (string, error) {
+func GenerateJWT(userID int64, email, role, sessionID string) (string, error) {
 	if len(jwtSecret) == 0 {
 		if err := InitJWTSecret(); err != nil {
 			return "", err
@@ -35,6 +45,7 @@ func GenerateJWT(userID int64, email, role string) (string, error) {
 		"user_id": userID,
 		"email":   email,
 		"role":    role,
+		"sid":     sessionID,
 		"exp":     time.Now().Add(31 * 24 * time.Hour).Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -42,9 +53,10 @@ func GenerateJWT(userID int64, email, role string) (string, error) {
 }
 
 type JWTClaims struct {
-	UserID int64
-	Email  string
-	Role   string
+	UserID    int64
+	Email     string
+	Role      string
+	SessionID string
 }
 
 func VerifyJWT(tokenStr string) (*JWTClaims, error) {
@@ -70,6 +82,10 @@ func VerifyJWT(tokenStr string) (*JWTClaims, error) {
 				return nil, errors.New("token expired")
 			}
 		}
+		// two factor challenges are signed with the same secret but must never work as a login
+		if _, ok := claims["purpose"]; ok {
+			return nil, errors.New("invalid token")
+		}
 
 		userIDFloat, ok := claims["user_id"].(float64)
 		if !ok {
@@ -86,23 +102,137 @@ func VerifyJWT(tokenStr string) (*JWTClaims, error) {
 			return nil, errors.New("invalid role in token")
 		}
 
+		// tokens issued before sessions were tracked have no session id and are rejected by VerifySession
+		sessionID, _ := claims["sid"].(string)
+
 		return &JWTClaims{
-			UserID: int64(userIDFloat),
-			Email:  email,
-			Role:   role,
+			UserID:    int64(userIDFloat),
+			Email:     email,
+			Role:      role,
+			SessionID: sessionID,
 		}, nil
 	}
 
 	return nil, errors.New("invalid token")
 }
 
+// the password step of a login with two factor enabled returns this instead of a session
+const twoFactorChallengeTTL = 5 * time.Minute
+
+func GenerateTwoFactorChallenge(userID int64) (string, error) {
+	if len(jwtSecret) == 0 {
+		if err := InitJWTSecret(); err != nil {
+			return "", err
+		}
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"purpose": "2fa",
+		"jti":     hex.EncodeToString(id),
+		"exp":     time.Now().Add(twoFactorChallengeTTL).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecret)
+}
+
+type TwoFactorChallenge struct {
+	UserID    int64
+	ID        string
+	ExpiresAt time.Time
+}
+
+// ids of challenges that completed a login, kept until they expire
+var usedChallenges = struct {
+	sync.Mutex
+	ids map[string]time.Time
+}{ids: map[string]time.Time{}}
+
+// marks the challenge as used, false if it was already presented once
+func (c *TwoFactorChallenge) Use() bool {
+	usedChallenges.Lock()
+	defer usedChallenges.Unlock()
+
+	now := time.Now()
+	for id, expiresAt := range usedChallenges.ids {
+		if now.After(expiresAt) {
+			delete(usedChallenges.ids, id)
+		}
+	}
+	if _, used := usedChallenges.ids[c.ID]; used {
+		return false
+	}
+	usedChallenges.ids[c.ID] = c.ExpiresAt
+	return true
+}
+
+// returns the challenge of the user who passed the password step
+func VerifyTwoFactorChallenge(tokenStr string) (*TwoFactorChallenge, error) {
+	if len(jwtSecret) == 0 {
+		if err := InitJWTSecret(); err != nil {
+			return nil, err
+		}
+	}
+
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return jwtSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid || claims["purpose"] != "2fa" {
+		return nil, errors.New("invalid challenge")
+	}
+	userIDFloat, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, errors.New("invalid user_id in challenge")
+	}
+	id, ok := claims["jti"].(string)
+	if !ok || id == "" {
+		return nil, errors.New("invalid jti in challenge")
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("invalid exp in challenge")
+	}
+	return &TwoFactorChallenge{
+		UserID:    int64(userIDFloat),
+		ID:        id,
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}, nil
+}
+
 type contextKey string
 
 const userContextKey = contextKey("user-data")
+const apiTokenContextKey = contextKey("api-token")
+const sessionContextKey = contextKey("session")
+
+// requests are authenticated with the mist_token cookie or an api token sent as "Authorization: Bearer mist_...".
+// api tokens are only accepted on routes that declare the scopes allowing them, any one of which is enough
+func AuthMiddleware(scopes ...string) func(http.Handler) http.Handler {
+	return AuthMiddlewareWithID(nil, scopes...)
+}
 
-func AuthMiddleware() func(http.Handler) http.Handler {
+// for routes taking the "id" of a row that belongs to an app, resolveID maps it to that app so project
+// limited api tokens can be checked against it. without one, project limited tokens can't send an id
+func AuthMiddlewareWithID(resolveID func(id int64) (int64, error), scopes ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && strings.HasPrefix(bearer, models.ApiTokenPrefix) {
+				authenticateApiToken(w, r, next, bearer, scopes, resolveID)
+				return
+			}
+
 			cookie, err := r.Cookie("mist_token")
 			if err != nil {
 				handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Authentication required", "No token provided")
@@ -110,7 +240,7 @@ func AuthMiddleware() func(http.Handler) http.Handler {
 			}
 
 			tokenString := cookie.Value
-			claims, err := VerifyJWT(tokenString)
+			claims, session, err := VerifySession(tokenString)
 
 			if err != nil {
 				handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Invalid or expired token", err.Error())
@@ -129,11 +259,173 @@ func AuthMiddleware() func(http.Handler) http.Handler {
 			}
 
 			ctx := context.WithValue(r.Context(), userContextKey, user)
+			ctx = context.WithValue(ctx, sessionContextKey, session)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+func authenticateApiToken(w http.ResponseWriter, r *http.Request, next http.Handler, plaintext string, scopes []string, resolveID func(int64) (int64, error)) {
+	token, err := models.GetApiTokenByHash(models.HashApiToken(plaintext))
+	if err != nil || token.IsExpired() {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "Invalid or expired token", "Invalid API token")
+		return
+	}
+
+	allowed := false
+	for _, scope := range scopes {
+		if token.HasScope(scope) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		handlers.SendResponse(w, http.StatusForbidden, false, nil, "API token is missing the required scope", "Forbidden")
+		return
+	}
+
+	user, err := models.GetUserByID(token.UserID)
+	if err != nil || user == nil {
+		handlers.SendResponse(w, http.StatusUnauthorized, false, nil, "User not found", "Invalid token user")
+		return
+	}
+
+	if token.ProjectID != nil {
+		projectIDs, found := requestProjectIDs(r, resolveID)
+		if !found || slices.ContainsFunc(projectIDs, func(id int64) bool { return id != *token.ProjectID }) {
+			handlers.SendResponse(w, http.StatusForbidden, false, nil, "API token is limited to another project", "Forbidden")
+			return
+		}
+	}
+
+	if err := token.UpdateUsage(ClientIP(r)); err != nil {
+		log.Warn().Err(err).Int64("token_id", token.ID).Msg("Failed to update api token usage")
+	}
+
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	ctx = context.WithValue(ctx, apiTokenContextKey, token)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// finds the projects a request targets from the projectId, appId, deploymentId and other ids in the query
+// or json body. every id is resolved, so one that belongs to another project can't ride along with a
+// matching projectId. the body is restored so handlers can still decode it
+func requestProjectIDs(r *http.Request, resolveID func(int64) (int64, error)) ([]int64, bool) {
+	ids := map[string]int64{}
+	query := r.URL.Query()
+	for _, key := range []string{"id", "projectId", "appId", "deploymentId", "backupId", "cronId", "cronRunId", "middlewareId"} {
+		if id, err := strconv.ParseInt(query.Get(key), 10, 64); err == nil {
+			ids[key] = id
+		}
+	}
+
+	if r.Body != nil && r.Body != http.NoBody && !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		var fields struct {
+			ID           *int64 `json:"id"`
+			ProjectID    *int64 `json:"projectId"`
+			AppID        *int64 `json:"appId"`
+			DeploymentID *int64 `json:"deploymentId"`
+			BackupID     *int64 `json:"backupId"`
+			CronID       *int64 `json:"cronId"`
+			CronRunID    *int64 `json:"cronRunId"`
+			MiddlewareID *int64 `json:"middlewareId"`
+		}
+		if err == nil && json.Unmarshal(body, &fields) == nil {
+			if fields.ID != nil {
+				ids["id"] = *fields.ID
+			}
+			if fields.ProjectID != nil {
+				ids["projectId"] = *fields.ProjectID
+			}
+			if fields.AppID != nil {
+				ids["appId"] = *fields.AppID
+			}
+			if fields.DeploymentID != nil {
+				ids["deploymentId"] = *fields.DeploymentID
+			}
+			if fields.BackupID != nil {
+				ids["backupId"] = *fields.BackupID
+			}
+			if fields.CronID != nil {
+				ids["cronId"] = *fields.CronID
+			}
+			if fields.CronRunID != nil {
+				ids["cronRunId"] = *fields.CronRunID
+			}
+			if fields.MiddlewareID != nil {
+				ids["middlewareId"] = *fields.MiddlewareID
+			}
+		}
+	}
+
+	appIDLookups := map[string]func(int64) (int64, error){
+		"deploymentId": models.GetAppIDByDeploymentID,
+		"backupId":     models.GetAppIDByBackupID,
+		"cronId":       models.GetAppIDByCronID,
+		"cronRunId":    models.GetAppIDByCronRunID,
+		"middlewareId": models.GetAppIDByMiddlewareID,
+	}
+	if _, found := ids["id"]; found {
+		// what an id refers to depends on the route
+		if resolveID == nil {
+			return nil, false
+		}
+		appIDLookups["id"] = resolveID
+	}
+
+	var projectIDs []int64
+	for key, id := range ids {
+		if key == "projectId" {
+			projectIDs = append(projectIDs, id)
+			continue
+		}
+		appID := id
+		if lookup, found := appIDLookups[key]; found {
+			resolved, err := lookup(id)
+			if err != nil {
+				return nil, false
+			}
+			appID = resolved
+		}
+		app, err := models.GetApplicationByID(appID)
+		if err != nil || app == nil {
+			return nil, false
+		}
+		projectIDs = append(projectIDs, app.ProjectID)
+	}
+	return projectIDs, len(projectIDs) > 0
+}
+
+// api token of the request, nil when it was authenticated with the session cookie
+func GetApiToken(r *http.Request) *models.ApiToken {
+	token, _ := r.Context().Value(apiTokenContextKey).(*models.ApiToken)
+	return token
+}
+
+// first address in X-Forwarded-For as set by the proxy in front of mist, the peer address otherwise.
+// the header is only trusted from private addresses, where traefik connects from, so direct
+// clients can't pick their own ip to get around the rate limits
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer := net.ParseIP(host)
+	if peer == nil || !(peer.IsLoopback() || peer.IsPrivate()) {
+		return host
+	}
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		if ip := strings.TrimSpace(first); ip != "" {
+			return ip
+		}
+	}
+	return host
+}
+
 func GetUser(r *http.Request) (*models.User, bool) {
 	user, ok := r.Context().Value(userContextKey).(*models.User)
 	return user, ok
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/corecollectives/mist/models"
+	"github.com/rs/zerolog/log"
+)
+
+// same lifetime as the mist_token cookie
+const SessionDuration = 30 * 24 * time.Hour
+
+// activity is written at most this often so every request doesn't hit the database
+const sessionActivityInterval = time.Minute
+
+// creates the session row for a login and returns the JWT carrying its id
+func CreateSession(r *http.Request, user *models.User) (*models.Session, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+
+	ip := ClientIP(r)
+	userAgent := r.UserAgent()
+	deviceType, browser, os := parseUserAgent(userAgent)
+
+	session := &models.Session{
+		ID:         hex.EncodeToString(b),
+		UserID:     user.ID,
+		IPAddress:  &ip,
+		UserAgent:  &userAgent,
+		DeviceType: &deviceType,
+		Browser:    &browser,
+		OS:         &os,
+		IsActive:   true,
+		ExpiresAt:  time.Now().Add(SessionDuration),
+	}
+	if err := session.InsertInDB(); err != nil {
+		return nil, "", err
+	}
+
+	token, err := GenerateJWT(user.ID, user.Email, user.Role, session.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	return session, token, nil
+}
+
+// verifies the JWT and that its session is still active
+func VerifySession(tokenStr string) (*JWTClaims, *models.Session, error) {
+	claims, err := VerifyJWT(tokenStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if claims.SessionID == "" {
+		return nil, nil, errors.New("session expired, please log in again")
+	}
+
+	session, err := models.GetSessionByID(claims.SessionID)
+	if err != nil {
+		return nil, nil, errors.New("session revoked or not found")
+	}
+	if session.UserID != claims.UserID {
+		return nil, nil, errors.New("invalid session")
+	}
+	if session.ExpiresAt.Before(time.Now()) {
+		return nil, nil, errors.New("session expired")
+	}
+
+	if time.Since(session.LastActivityAt) > sessionActivityInterval {
+		if err := session.UpdateActivity(); err != nil {
+			log.Warn().Err(err).Str("session_id", session.ID).Msg("Failed to update session activity")
+		}
+	}
+	return claims, session, nil
+}
+
+// session of the request, nil when it was authenticated with an api token
+func GetSession(r *http.Request) *models.Session {
+	session, _ := r.Context().Value(sessionContextKey).(*models.Session)
+	return session
+}
+
+// reports whether the session or api token a request was authenticated with is still valid.
+// long lived connections like websockets call this to notice logouts and revoked tokens
+func StillAuthenticated(r *http.Request) bool {
+	var userID int64
+	if token := GetApiToken(r); token != nil {
+		current, err := models.GetApiTokenByHash(token.TokenHash)
+		if err != nil || current.IsExpired() {
+			return false
+		}
+		userID = current.UserID
+	} else if session := GetSession(r); session != nil {
+		current, err := models.GetSessionByID(session.ID)
+		if err != nil || current.ExpiresAt.Before(time.Now()) {
+			return false
+		}
+		userID = current.UserID
+	} else {
+		return false
+	}
+
+	user, err := models.GetUserByID(userID)
+	return err == nil && user != nil
+}
+
+// rough device, browser and os detection, only used to label sessions for the user
+func parseUserAgent(ua string) (string, string, string) {
+	lower := strings.ToLower(ua)
+
+	deviceType := string(models.DeviceDesktop)
+	switch {
+	case ua == "":
+		deviceType = string(models.DeviceUnknown)
+	case strings.Contains(lower, "ipad") || strings.Contains(lower, "tablet"):
+		deviceType = string(models.DeviceTablet)
+	case strings.Contains(lower, "mobile") || strings.Contains(lower, "iphone") || strings.Contains(lower, "android"):
+		deviceType = string(models.DeviceMobile)
+	}
+
+	browser := "Unknown"
+	switch {
+	case strings.Contains(lower, "edg/"):
+		browser = "Edge"
+	case strings.Contains(lower, "opr/") || strings.Contains(lower, "opera"):
+		browser = "Opera"
+	case strings.Contains(lower, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(lower, "chrome/") || strings.Contains(lower, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(lower, "safari/"):
+		browser = "Safari"
+	case strings.Contains(lower, "curl/"):
+		browser = "curl"
+	}
+
+	os := "Unknown"
+	switch {
+	case strings.Contains(lower, "windows"):
+		os = "Windows"
+	case strings.Contains(lower, "iphone") || strings.Contains(lower, "ipad"):
+		os = "iOS"
+	case strings.Contains(lower, "android"):
+		os = "Android"
+	case strings.Contains(lower, "mac os") || strings.Contains(lower, "macintosh"):
+		os = "macOS"
+	case strings.Contains(lower, "linux"):
+		os = "Linux"
+	}
+
+	return deviceType, browser, os
+}
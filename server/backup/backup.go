@@ -0,0 +1,402 @@
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/corecollectives/mist/constants"
+	"github.com/corecollectives/mist/docker"
+	"github.com/corecollectives/mist/fs"
+	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/notify"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	backupTimeout  = 2 * time.Hour
+	progressPeriod = 2 * time.Second
+)
+
+var (
+	ErrNotSupported   = errors.New("backups are only supported for postgres, mysql, mariadb, mongodb and redis databases")
+	ErrNotRunning     = errors.New("database container is not running")
+	ErrBusy           = errors.New("a backup or restore is already running for this database")
+	ErrNotRestorable  = errors.New("backup can't be restored")
+	ErrChecksumFailed = errors.New("backup file doesn't match its checksum")
+)
+
+// one backup or restore at a time per app, a restore reading a half written dump would be bad
+var running sync.Map
+
+func lock(appID int64) bool {
+	_, busy := running.LoadOrStore(appID, struct{}{})
+	return !busy
+}
+
+func unlock(appID int64) {
+	running.Delete(appID)
+}
+
+func IsRunning(appID int64) bool {
+	_, busy := running.Load(appID)
+	return busy
+}
+
+type Options struct {
+	Type          models.BackupType
+	CreatedBy     *int64
+	RetentionDays *int
+	Notes         *string
+}
+
+// creates the backup record and dumps the database in the background, the returned
+// backup is pending and its status and progress are updated as the dump runs
+func Start(app *models.App, opts Options) (*models.Backup, error) {
+	eng, dbType, ok := engineForApp(app)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	containerName := docker.GetContainerName(app.Name, app.ID)
+	if status, err := docker.GetContainerStatus(containerName); err != nil {
+		return nil, err
+	} else if status.State != "running" {
+		return nil, ErrNotRunning
+	}
+	storage, err := currentStorage()
+	if err != nil {
+		return nil, err
+	}
+	if !lock(app.ID) {
+		return nil, ErrBusy
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s%s.gz", app.Name, now.UTC().Format("20060102-150405"), eng.extension)
+	b := &models.Backup{
+		AppID:         app.ID,
+		BackupType:    opts.Type,
+		BackupName:    name,
+		DatabaseType:  &dbType,
+		Status:        models.BackupStatusPending,
+		RetentionDays: opts.RetentionDays,
+		CreatedBy:     opts.CreatedBy,
+		Notes:         opts.Notes,
+	}
+	if template, err := models.GetServiceTemplateByName(dbType); err == nil && template != nil {
+		b.DatabaseVersion = template.DockerImageVersion
+		if b.DatabaseVersion == nil {
+			b.DatabaseVersion = &template.DockerImage
+		}
+	}
+	if opts.RetentionDays != nil && *opts.RetentionDays > 0 {
+		deleteAt := now.AddDate(0, 0, *opts.RetentionDays)
+		b.AutoDeleteAt = &deleteAt
+	}
+	if err := storage.Prepare(b); err != nil {
+		unlock(app.ID)
+		return nil, err
+	}
+	if err := b.InsertInDB(); err != nil {
+		unlock(app.ID)
+		return nil, err
+	}
+
+	go func() {
+		defer unlock(app.ID)
+		run(b, eng, storage, containerName)
+	}()
+	return b, nil
+}
+
+func run(b *models.Backup, eng engine, storage Storage, containerName string) {
+	started := time.Now()
+	if err := b.MarkAsStarted(); err != nil {
+		log.Error().Err(err).Int64("backup_id", b.ID).Msg("Failed to mark backup as started")
+	}
+
+	size, checksum, err := dump(b, eng, storage, containerName)
+	if err != nil {
+		log.Error().Err(err).Int64("backup_id", b.ID).Int64("app_id", b.AppID).Msg("Backup failed")
+		errMsg := err.Error()
+		b.UpdateStatus(models.BackupStatusFailed, &errMsg)
+		notifyResult(b, err)
+		return
+	}
+
+	duration := int(time.Since(started).Seconds())
+	if err := b.MarkAsCompleted(size, checksum, duration); err != nil {
+		log.Error().Err(err).Int64("backup_id", b.ID).Msg("Failed to mark backup as completed")
+		return
+	}
+	log.Info().Int64("backup_id", b.ID).Int64("app_id", b.AppID).Int64("size", size).Msg("Backup completed")
+	notifyResult(b, nil)
+}
+
+func notifyResult(b *models.Backup, backupErr error) {
+	app, err := models.GetApplicationByID(b.AppID)
+	if err != nil || app == nil {
+		return
+	}
+	if backupErr != nil {
+		notify.Send(notify.AppEvent(app, models.NotificationBackupFailed, models.PriorityHigh,
+			fmt.Sprintf("Backup of %s failed", app.Name),
+			fmt.Sprintf("Backup %s failed: %s", b.BackupName, backupErr.Error())))
+		return
+	}
+	notify.Send(notify.AppEvent(app, models.NotificationBackupSuccess, models.PriorityLow,
+		fmt.Sprintf("Backup of %s completed", app.Name),
+		fmt.Sprintf("Backup %s completed.", b.BackupName)))
+}
+
+// streams the dump through gzip into the storage while it runs, nothing is buffered on disk
+func dump(b *models.Backup, eng engine, storage Storage, containerName string) (int64, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), backupTimeout)
+	defer cancel()
+
+	hash := sha256.New()
+	progress := newProgressWriter(b, models.GetLastBackupSize(b.AppID))
+	reader, writer := io.Pipe()
+	gz := gzip.NewWriter(io.MultiWriter(writer, hash, progress))
+
+	dumpErr := make(chan error, 1)
+	go func() {
+		err := docker.ExecInContainer(ctx, containerName, []string{"sh", "-c", eng.dump}, nil, gz)
+		if err != nil {
+			err = fmt.Errorf("dump failed: %w", err)
+		} else if err = gz.Close(); err != nil {
+			err = fmt.Errorf("failed to compress backup: %w", err)
+		}
+		// a failed dump fails the upload as well, so no incomplete backup is stored
+		writer.CloseWithError(err)
+		dumpErr <- err
+	}()
+
+	uploadErr := storage.Upload(ctx, b, reader)
+	// stops the dump when the upload gave up before reading everything
+	reader.CloseWithError(io.ErrClosedPipe)
+	if err := <-dumpErr; err != nil {
+		return 0, "", err
+	}
+	if uploadErr != nil {
+		return 0, "", uploadErr
+	}
+	return progress.written, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// restores the backup into the app's running database container in the background
+func Restore(b *models.Backup, app *models.App, userID *int64) error {
+	eng, _, ok := engineForApp(app)
+	if !ok {
+		return ErrNotSupported
+	}
+	if b.Status != models.BackupStatusCompleted || !b.CanRestore {
+		return ErrNotRestorable
+	}
+	containerName := docker.GetContainerName(app.Name, app.ID)
+	if status, err := docker.GetContainerStatus(containerName); err != nil {
+		return err
+	} else if status.State != "running" {
+		return ErrNotRunning
+	}
+	if !lock(app.ID) {
+		return ErrBusy
+	}
+	if err := b.UpdateRestoreStatus(models.BackupStatusInProgress, nil); err != nil {
+		unlock(app.ID)
+		return err
+	}
+
+	go func() {
+		defer unlock(app.ID)
+		if err := restore(b, eng, containerName); err != nil {
+			log.Error().Err(err).Int64("backup_id", b.ID).Int64("app_id", app.ID).Msg("Restore failed")
+			errMsg := err.Error()
+			b.UpdateRestoreStatus(models.BackupStatusFailed, &errMsg)
+			models.LogAudit(userID, "restore_failed", "backup", &b.ID, map[string]interface{}{
+				"app_id": app.ID,
+				"error":  errMsg,
+			})
+			return
+		}
+		if err := b.MarkAsRestored(); err != nil {
+			log.Error().Err(err).Int64("backup_id", b.ID).Msg("Failed to mark backup as restored")
+		}
+		log.Info().Int64("backup_id", b.ID).Int64("app_id", app.ID).Msg("Restore completed")
+	}()
+	return nil
+}
+
+func restore(b *models.Backup, eng engine, containerName string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), backupTimeout)
+	defer cancel()
+
+	path, cleanup, err := fetch(ctx, b)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to read backup file: %w", err)
+	}
+	defer gz.Close()
+
+	if err := docker.ExecInContainer(ctx, containerName, []string{"sh", "-c", eng.restore}, gz, nil); err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+
+	if eng.afterRestore != "" {
+		// the command may stop the container, so its error isn't meaningful
+		docker.ExecInContainer(ctx, containerName, []string{"sh", "-c", eng.afterRestore}, nil, nil)
+	}
+	if eng.restart {
+		if err := docker.RestartContainer(containerName); err != nil {
+			return fmt.Errorf("failed to restart database after restore: %w", err)
+		}
+	}
+	return nil
+}
+
+// local path of a verified copy of the backup. remote backups are downloaded to a temporary
+// file first, so a corrupted download is caught before anything is written to the database
+func fetch(ctx context.Context, b *models.Backup) (string, func(), error) {
+	if b.Checksum == nil {
+		return "", nil, ErrNotRestorable
+	}
+	storage, err := storageFor(b)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if storage.Type() == models.StorageTypeLocal {
+		if err := verifyFile(b, b.FilePath); err != nil {
+			return "", nil, err
+		}
+		return b.FilePath, func() {}, nil
+	}
+
+	src, err := storage.Open(ctx, b)
+	if err != nil {
+		return "", nil, err
+	}
+	defer src.Close()
+
+	tmpDir := filepath.Join(constants.Constants["BackupPath"].(string), "tmp")
+	if err := fs.CreateDirIfNotExists(tmpDir, 0700); err != nil {
+		return "", nil, fmt.Errorf("failed to create download directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(tmpDir, "restore-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create download file: %w", err)
+	}
+	cleanup := func() { os.Remove(tmp.Name()) }
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to download backup: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to download backup: %w", err)
+	}
+	if err := verifyFile(b, tmp.Name()); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return tmp.Name(), cleanup, nil
+}
+
+// checks a copy of the backup against the checksum taken when it was written
+func verifyFile(b *models.Backup, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("failed to read backup file: %w", err)
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != *b.Checksum {
+		return ErrChecksumFailed
+	}
+	return b.MarkAsVerified()
+}
+
+// removes the backup file and marks the backup as deleted
+func Delete(b *models.Backup) error {
+	if err := removeFile(b); err != nil {
+		return err
+	}
+	return b.MarkAsDeleted()
+}
+
+func removeFile(b *models.Backup) error {
+	storage, err := storageFor(b)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return storage.Delete(ctx, b)
+}
+
+// removes the files of all backups of an app that is being deleted
+func DeleteAppBackups(appID int64) {
+	backups, err := models.GetBackupsByAppID(appID)
+	if err != nil {
+		log.Warn().Err(err).Int64("app_id", appID).Msg("Failed to get backups of deleted app")
+		return
+	}
+	for i := range backups {
+		if err := removeFile(&backups[i]); err != nil {
+			log.Warn().Err(err).Int64("backup_id", backups[i].ID).Msg("Failed to remove backup during app deletion")
+		}
+	}
+	os.RemoveAll(filepath.Join(constants.Constants["BackupPath"].(string), fmt.Sprint(appID)))
+}
+
+// counts the compressed bytes and turns them into a progress estimate based on the previous
+// backup's size, dumps don't report their total size up front
+type progressWriter struct {
+	backup   *models.Backup
+	expected int64
+	written  int64
+	last     time.Time
+}
+
+func newProgressWriter(b *models.Backup, expected int64) *progressWriter {
+	return &progressWriter{backup: b, expected: expected, last: time.Now()}
+}
+
+func (p *progressWriter) Write(data []byte) (int, error) {
+	p.written += int64(len(data))
+	if time.Since(p.last) < progressPeriod || p.expected <= 0 {
+		return len(data), nil
+	}
+	p.last = time.Now()
+	// capped below 100 since the estimate can be off, completion sets it to 100
+	progress := min(int(p.written*95/p.expected), 95)
+	if progress > p.backup.Progress {
+		p.backup.Progress = progress
+		p.backup.UpdateProgress(progress)
+	}
+	return len(data), nil
+}
@@ -0,0 +1,76 @@
+package backup
+
+import (
+	"strings"
+
+	"github.com/corecollectives/mist/models"
+)
+
+// how to dump and restore one kind of database. the commands run through `sh -c` inside the
+// database container, so they can use the credentials the template put in its environment
+type engine struct {
+	// extension of the uncompressed dump, .gz is appended
+	extension string
+	dump      string
+	restore   string
+	// run after restore, for databases that only load the restored data on startup
+	afterRestore string
+	restart      bool
+}
+
+const redisAuth = `if [ -n "$REDIS_PASSWORD" ]; then export REDISCLI_AUTH="$REDIS_PASSWORD"; fi; `
+
+// keyed by service template name
+var engines = map[string]engine{
+	"postgres": {
+		extension: ".sql",
+		dump: `export PGPASSWORD="$POSTGRES_PASSWORD"; ` +
+			`exec pg_dump -U "${POSTGRES_USER:-postgres}" --clean --if-exists --no-owner "${POSTGRES_DB:-${POSTGRES_USER:-postgres}}"`,
+		restore: `export PGPASSWORD="$POSTGRES_PASSWORD"; ` +
+			`exec psql -U "${POSTGRES_USER:-postgres}" -d "${POSTGRES_DB:-${POSTGRES_USER:-postgres}}" -v ON_ERROR_STOP=1 --quiet`,
+	},
+	"mysql": {
+		extension: ".sql",
+		dump:      `export MYSQL_PWD="$MYSQL_ROOT_PASSWORD"; exec mysqldump -uroot --all-databases --single-transaction --routines --events --triggers`,
+		restore:   `export MYSQL_PWD="$MYSQL_ROOT_PASSWORD"; exec mysql -uroot`,
+	},
+	"mariadb": {
+		extension: ".sql",
+		dump:      `export MYSQL_PWD="${MARIADB_ROOT_PASSWORD:-$MYSQL_ROOT_PASSWORD}"; exec mariadb-dump -uroot --all-databases --single-transaction --routines --events --triggers`,
+		restore:   `export MYSQL_PWD="${MARIADB_ROOT_PASSWORD:-$MYSQL_ROOT_PASSWORD}"; exec mariadb -uroot`,
+	},
+	"mongodb": {
+		extension: ".archive",
+		dump: `if [ -n "$MONGO_INITDB_ROOT_USERNAME" ]; then set -- --username "$MONGO_INITDB_ROOT_USERNAME" --password "$MONGO_INITDB_ROOT_PASSWORD" --authenticationDatabase admin; fi; ` +
+			`exec mongodump --archive --quiet "$@"`,
+		restore: `if [ -n "$MONGO_INITDB_ROOT_USERNAME" ]; then set -- --username "$MONGO_INITDB_ROOT_USERNAME" --password "$MONGO_INITDB_ROOT_PASSWORD" --authenticationDatabase admin; fi; ` +
+			`exec mongorestore --archive --drop --quiet "$@"`,
+	},
+	// redis can't load a snapshot while running, the restore replaces the rdb file and restarts the
+	// container without saving. snapshots are turned off first so a background save can't overwrite it
+	"redis": {
+		extension: ".rdb",
+		dump:      redisAuth + `exec redis-cli --rdb -`,
+		restore: redisAuth +
+			`if [ "$(redis-cli config get appendonly | tail -n 1)" = "yes" ]; then echo "restoring redis with appendonly enabled is not supported" >&2; exit 1; fi; ` +
+			`dir=$(redis-cli config get dir | tail -n 1); file=$(redis-cli config get dbfilename | tail -n 1); ` +
+			`redis-cli config set save "" > /dev/null && cat > "$dir/$file.restore" && mv "$dir/$file.restore" "$dir/$file"`,
+		afterRestore: redisAuth + `redis-cli shutdown nosave`,
+		restart:      true,
+	},
+}
+
+func engineForApp(app *models.App) (engine, string, bool) {
+	if app.AppType != models.AppTypeDatabase || app.TemplateName == nil {
+		return engine{}, "", false
+	}
+	name := strings.ToLower(*app.TemplateName)
+	e, ok := engines[name]
+	return e, name, ok
+}
+
+// whether backups can be taken for the app, only database apps from a known template qualify
+func Supported(app *models.App) bool {
+	_, _, ok := engineForApp(app)
+	return ok
+}
@@ -0,0 +1,70 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/corecollectives/mist/constants"
+	"github.com/corecollectives/mist/fs"
+	"github.com/corecollectives/mist/models"
+)
+
+// backups on the mist server's disk in /var/lib/mist/backups/{appId}
+type localStorage struct{}
+
+func (localStorage) Type() models.StorageType {
+	return models.StorageTypeLocal
+}
+
+func (localStorage) Prepare(b *models.Backup) error {
+	b.StorageType = models.StorageTypeLocal
+	b.FilePath = filepath.Join(constants.Constants["BackupPath"].(string), fmt.Sprint(b.AppID), b.BackupName)
+	b.StoragePath = nil
+	return nil
+}
+
+// writes to a temporary file, which is only renamed into place once complete
+func (localStorage) Upload(ctx context.Context, b *models.Backup, r io.Reader) error {
+	if err := fs.CreateDirIfNotExists(filepath.Dir(b.FilePath), 0700); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	partial := b.FilePath + ".partial"
+	file, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(partial)
+	defer file.Close()
+
+	if _, err := io.Copy(file, r); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+	if err := os.Rename(partial, b.FilePath); err != nil {
+		return fmt.Errorf("failed to move backup file into place: %w", err)
+	}
+	return nil
+}
+
+func (localStorage) Open(ctx context.Context, b *models.Backup) (io.ReadCloser, error) {
+	file, err := os.Open(b.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup file: %w", err)
+	}
+	return file, nil
+}
+
+func (localStorage) Delete(ctx context.Context, b *models.Backup) error {
+	if err := os.Remove(b.FilePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete backup file: %w", err)
+	}
+	return nil
+}
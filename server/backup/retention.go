@@ -0,0 +1,46 @@
+package backup
+
+import (
+	"time"
+
+	"github.com/corecollectives/mist/models"
+	"github.com/rs/zerolog/log"
+)
+
+const retentionInterval = time.Hour
+
+// deletes backups past their retention period once an hour
+func StartRetentionWorker() {
+	go func() {
+		DeleteExpired()
+		ticker := time.NewTicker(retentionInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			DeleteExpired()
+		}
+	}()
+}
+
+// backups whose file can't be removed stay as they are and are retried on the next run
+func DeleteExpired() {
+	backups, err := models.GetExpiredBackups()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get expired backups")
+		return
+	}
+	deleted := 0
+	for i := range backups {
+		if err := removeFile(&backups[i]); err != nil {
+			log.Warn().Err(err).Int64("backup_id", backups[i].ID).Msg("Failed to delete expired backup file")
+			continue
+		}
+		if err := backups[i].MarkAsDeleted(); err != nil {
+			log.Error().Err(err).Int64("backup_id", backups[i].ID).Msg("Failed to mark expired backup as deleted")
+			continue
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		log.Info().Int("count", deleted).Msg("Deleted expired backups")
+	}
+}
@@ -0,0 +1,167 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/corecollectives/mist/models"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// parts are buffered in memory while uploading, 16MiB keeps that small and
+// still allows dumps of up to ~160GB within the 10000 part limit
+const s3PartSize = 16 << 20
+
+// any S3 compatible object storage, e.g. AWS S3, Cloudflare R2, Backblaze B2 or MinIO
+type s3Storage struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func newS3Storage(settings *models.BackupStorageSettings) (*s3Storage, error) {
+	if settings.S3Endpoint == "" || settings.S3Bucket == "" {
+		return nil, errors.New("s3 endpoint and bucket are required")
+	}
+	if settings.S3AccessKey == "" || settings.S3SecretKey == "" {
+		return nil, errors.New("s3 access key and secret key are required")
+	}
+	host, secure, err := parseS3Endpoint(settings.S3Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := minio.BucketLookupAuto
+	if settings.S3PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(host, &minio.Options{
+		Creds:        credentials.NewStaticV4(settings.S3AccessKey, settings.S3SecretKey, ""),
+		Secure:       secure,
+		Region:       settings.S3Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+	return &s3Storage{
+		client: client,
+		bucket: settings.S3Bucket,
+		prefix: strings.Trim(settings.S3Prefix, "/"),
+	}, nil
+}
+
+// accepts "https://s3.example.com", "http://minio:9000" or a bare host, which defaults to https
+func parseS3Endpoint(endpoint string) (string, bool, error) {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return "", false, fmt.Errorf("invalid s3 endpoint: %s", endpoint)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false, fmt.Errorf("s3 endpoint must use http or https")
+	}
+	if strings.Trim(u.Path, "/") != "" {
+		return "", false, fmt.Errorf("s3 endpoint can't contain a path, set the bucket and prefix instead")
+	}
+	return u.Host, u.Scheme == "https", nil
+}
+
+func (s *s3Storage) Type() models.StorageType {
+	return models.StorageTypeS3
+}
+
+func (s *s3Storage) Prepare(b *models.Backup) error {
+	key := path.Join(s.prefix, fmt.Sprint(b.AppID), b.BackupName)
+	location := "s3://" + s.bucket + "/" + key
+	b.StorageType = models.StorageTypeS3
+	b.FilePath = key
+	b.StoragePath = &location
+	return nil
+}
+
+// the size is unknown while the dump runs, so minio-go uploads it as a multipart upload
+// part by part. a failed upload is aborted, no partial object is left behind
+func (s *s3Storage) Upload(ctx context.Context, b *models.Backup, r io.Reader) error {
+	bucket, key, err := s.location(b)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.PutObject(ctx, bucket, key, r, -1, minio.PutObjectOptions{
+		ContentType: "application/gzip",
+		PartSize:    s3PartSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload backup to s3: %w", err)
+	}
+	return nil
+}
+
+func (s *s3Storage) Open(ctx context.Context, b *models.Backup) (io.ReadCloser, error) {
+	bucket, key, err := s.location(b)
+	if err != nil {
+		return nil, err
+	}
+	object, err := s.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download backup from s3: %w", err)
+	}
+	// GetObject is lazy, stat surfaces a missing object or bad credentials right away
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, fmt.Errorf("failed to download backup from s3: %w", err)
+	}
+	return object, nil
+}
+
+func (s *s3Storage) Delete(ctx context.Context, b *models.Backup) error {
+	bucket, key, err := s.location(b)
+	if err != nil {
+		return err
+	}
+	if err := s.client.RemoveObject(ctx, bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete backup from s3: %w", err)
+	}
+	return nil
+}
+
+// uploads and removes a small object to check the settings before they are saved
+func (s *s3Storage) Test(ctx context.Context) error {
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return fmt.Errorf("failed to reach bucket: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("bucket %s does not exist", s.bucket)
+	}
+	key := path.Join(s.prefix, ".mist-storage-test")
+	if _, err := s.client.PutObject(ctx, s.bucket, key, strings.NewReader("mist"), 4, minio.PutObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to write to bucket: %w", err)
+	}
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete from bucket: %w", err)
+	}
+	return nil
+}
+
+// bucket and key of a backup, taken from its StoragePath so older backups keep
+// working after the bucket setting changed
+func (s *s3Storage) location(b *models.Backup) (string, string, error) {
+	if b.StoragePath == nil {
+		return s.bucket, b.FilePath, nil
+	}
+	rest, ok := strings.CutPrefix(*b.StoragePath, "s3://")
+	bucket, key, found := strings.Cut(rest, "/")
+	if !ok || !found || bucket == "" || key == "" {
+		return "", "", fmt.Errorf("invalid s3 storage path: %s", *b.StoragePath)
+	}
+	return bucket, key, nil
+}
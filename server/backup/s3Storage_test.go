@@ -0,0 +1,206 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/corecollectives/mist/constants"
+	"github.com/corecollectives/mist/db"
+	"github.com/corecollectives/mist/models"
+	"github.com/minio/minio-go/v7"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// runs against a real S3 compatible server, e.g.
+//
+//	docker run -p 9000:9000 minio/minio server /data
+//	MIST_TEST_S3_ENDPOINT=http://localhost:9000 go test ./backup
+func testS3Storage(t *testing.T) *s3Storage {
+	t.Helper()
+	settings := testS3Settings(t)
+	storage, err := newS3Storage(settings)
+	if err != nil {
+		t.Fatalf("newS3Storage: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	exists, err := storage.client.BucketExists(ctx, storage.bucket)
+	if err != nil {
+		t.Fatalf("BucketExists: %v", err)
+	}
+	if !exists {
+		if err := storage.client.MakeBucket(ctx, storage.bucket, minio.MakeBucketOptions{Region: settings.S3Region}); err != nil {
+			t.Fatalf("MakeBucket: %v", err)
+		}
+	}
+	return storage
+}
+
+func testS3Settings(t *testing.T) *models.BackupStorageSettings {
+	t.Helper()
+	endpoint := os.Getenv("MIST_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("MIST_TEST_S3_ENDPOINT is not set")
+	}
+	return &models.BackupStorageSettings{
+		Type:        models.StorageTypeS3,
+		S3Endpoint:  endpoint,
+		S3Bucket:    envOr("MIST_TEST_S3_BUCKET", "mist-test"),
+		S3Region:    envOr("MIST_TEST_S3_REGION", "us-east-1"),
+		S3Prefix:    "backups",
+		S3PathStyle: true,
+		S3AccessKey: envOr("MIST_TEST_S3_ACCESS_KEY", "minioadmin"),
+		S3SecretKey: envOr("MIST_TEST_S3_SECRET_KEY", "minioadmin"),
+	}
+}
+
+// fetch loads the storage settings from the database and downloads into the backup directory,
+// so both point into a temporary directory for the test
+func useTestSettings(t *testing.T, settings *models.BackupStorageSettings) {
+	t.Helper()
+	root := t.TempDir()
+	for key, value := range map[string]string{"RootPath": root, "BackupPath": filepath.Join(root, "backups")} {
+		previous := constants.Constants[key]
+		constants.Constants[key] = value
+		t.Cleanup(func() { constants.Constants[key] = previous })
+	}
+
+	database, err := gorm.Open(sqlite.Open(filepath.Join(root, "mist.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	if err := db.MigrateDb(database); err != nil {
+		t.Fatalf("MigrateDb: %v", err)
+	}
+	models.SetDB(database)
+	if err := models.UpdateBackupStorageSettings(settings); err != nil {
+		t.Fatalf("UpdateBackupStorageSettings: %v", err)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func TestS3StorageRoundTrip(t *testing.T) {
+	storage := testS3Storage(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := storage.Test(ctx); err != nil {
+		t.Fatalf("Test: %v", err)
+	}
+
+	// larger than a part, so the upload goes through the multipart path
+	data := make([]byte, s3PartSize+1024)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	b := &models.Backup{AppID: 42, BackupName: "roundtrip-" + time.Now().Format("20060102150405") + ".sql.gz", Checksum: &checksum}
+	if err := storage.Prepare(b); err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	if want := "s3://" + storage.bucket + "/backups/42/" + b.BackupName; b.StoragePath == nil || *b.StoragePath != want {
+		t.Fatalf("StoragePath = %v, want %s", b.StoragePath, want)
+	}
+
+	// a reader without a known size, like the dump pipe
+	if err := storage.Upload(ctx, b, io.MultiReader(bytes.NewReader(data))); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	t.Cleanup(func() { storage.Delete(context.Background(), b) })
+
+	src, err := storage.Open(ctx, b)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, err := io.ReadAll(src)
+	src.Close()
+	if err != nil {
+		t.Fatalf("reading object: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("downloaded %d bytes that differ from the %d uploaded", len(got), len(data))
+	}
+
+	if err := storage.Delete(ctx, b); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := storage.Open(ctx, b); err == nil {
+		t.Fatal("Open succeeded after Delete")
+	}
+}
+
+func TestS3StorageDownloadChecksumMismatch(t *testing.T) {
+	storage := testS3Storage(t)
+	useTestSettings(t, testS3Settings(t))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	wrong := hex.EncodeToString(make([]byte, sha256.Size))
+	b := &models.Backup{AppID: 42, BackupName: "mismatch-" + time.Now().Format("20060102150405") + ".sql.gz", Checksum: &wrong}
+	if err := storage.Prepare(b); err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	if b.StorageType != models.StorageTypeS3 {
+		t.Fatalf("StorageType = %s, want %s", b.StorageType, models.StorageTypeS3)
+	}
+	if err := storage.Upload(ctx, b, bytes.NewReader([]byte("not what was checksummed"))); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	t.Cleanup(func() { storage.Delete(context.Background(), b) })
+
+	if _, _, err := fetch(ctx, b); !errors.Is(err, ErrChecksumFailed) {
+		t.Fatalf("fetch = %v, want %v", err, ErrChecksumFailed)
+	}
+	// the download is removed again when it doesn't match
+	tmp, err := os.ReadDir(filepath.Join(constants.Constants["BackupPath"].(string), "tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmp) != 0 {
+		t.Fatalf("%d files left in the download directory", len(tmp))
+	}
+}
+
+func TestFetchChecksumMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.sql.gz")
+	if err := os.WriteFile(path, []byte("corrupted dump"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("original dump"))
+	checksum := hex.EncodeToString(sum[:])
+	b := &models.Backup{StorageType: models.StorageTypeLocal, FilePath: path, Checksum: &checksum}
+
+	if _, _, err := fetch(context.Background(), b); !errors.Is(err, ErrChecksumFailed) {
+		t.Fatalf("fetch = %v, want %v", err, ErrChecksumFailed)
+	}
+	if err := verifyFile(b, path); !errors.Is(err, ErrChecksumFailed) {
+		t.Fatalf("verifyFile = %v, want %v", err, ErrChecksumFailed)
+	}
+}
+
+func TestFetchWithoutChecksum(t *testing.T) {
+	b := &models.Backup{StorageType: models.StorageTypeLocal, FilePath: filepath.Join(t.TempDir(), "backup.sql.gz")}
+	if _, _, err := fetch(context.Background(), b); !errors.Is(err, ErrNotRestorable) {
+		t.Fatalf("fetch = %v, want %v", err, ErrNotRestorable)
+	}
+}
@@ -0,0 +1,77 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/corecollectives/mist/models"
+)
+
+// where backup files live. dumps are streamed into Upload as they are produced, so
+// backends must not need the size up front
+type Storage interface {
+	Type() models.StorageType
+	// sets FilePath and StoragePath of a new backup, called before it is saved
+	Prepare(b *models.Backup) error
+	Upload(ctx context.Context, b *models.Backup, r io.Reader) error
+	Open(ctx context.Context, b *models.Backup) (io.ReadCloser, error)
+	Delete(ctx context.Context, b *models.Backup) error
+}
+
+// storage that new backups are written to, as configured in the system settings
+func currentStorage() (Storage, error) {
+	settings, err := models.GetBackupStorageSettings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load backup storage settings: %w", err)
+	}
+	return NewStorage(settings)
+}
+
+func NewStorage(settings *models.BackupStorageSettings) (Storage, error) {
+	switch settings.Type {
+	case models.StorageTypeLocal, "":
+		return localStorage{}, nil
+	case models.StorageTypeS3:
+		return newS3Storage(settings)
+	default:
+		return nil, fmt.Errorf("unsupported backup storage type: %s", settings.Type)
+	}
+}
+
+// storage an existing backup was written to. s3 backups are read with the current
+// credentials, their bucket is taken from the backup's StoragePath
+func storageFor(b *models.Backup) (Storage, error) {
+	if b.StorageType == models.StorageTypeLocal || b.StorageType == "" {
+		return localStorage{}, nil
+	}
+	settings, err := models.GetBackupStorageSettings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load backup storage settings: %w", err)
+	}
+	if b.StorageType != models.StorageTypeS3 {
+		return nil, fmt.Errorf("unsupported backup storage type: %s", b.StorageType)
+	}
+	return newS3Storage(settings)
+}
+
+// opens the stored backup file for reading, e.g. for downloads
+func Open(ctx context.Context, b *models.Backup) (io.ReadCloser, error) {
+	storage, err := storageFor(b)
+	if err != nil {
+		return nil, err
+	}
+	return storage.Open(ctx, b)
+}
+
+// checks that the settings can be used to store backups
+func TestStorage(ctx context.Context, settings *models.BackupStorageSettings) error {
+	storage, err := NewStorage(settings)
+	if err != nil {
+		return err
+	}
+	if s3, ok := storage.(*s3Storage); ok {
+		return s3.Test(ctx)
+	}
+	return nil
+}
@@ -4,5 +4,6 @@ var Constants = map[string]any{
 	"RootPath":      "/var/lib/mist",
 	"LogPath":       "/var/lib/mist/logs",
 	"AvatarDirPath": "/var/lib/mist/uploads/avatar",
+	"BackupPath":    "/var/lib/mist/backups",
 	"MaxAvatarSize": 5 << 20,
 }
@@ -108,6 +108,10 @@ func MigrateDb(dbInstance *gorm.DB) error {
 		&models.Domain{},
 		&models.Volume{},
 		&models.Cron{},
+		&models.CronRun{},
+		&models.AppMiddleware{},
+		&models.NotificationChannel{},
+		&models.NotificationDelivery{},
 		&models.Registry{},
 		&models.SystemSettingEntry{},
 		&models.Logs{},
@@ -130,6 +134,18 @@ func MigrateDb(dbInstance *gorm.DB) error {
 		}
 	}
 
+	// a host can be split between apps by path, so it's only unique together with the path
+	if migrator.HasIndex(&models.Domain{}, "idx_domains_domain_name") {
+		if err := migrator.DropIndex(&models.Domain{}, "idx_domains_domain_name"); err != nil {
+			fmt.Printf("migration.go: warning dropping domain index: %v\n", err)
+		}
+	}
+	if !migrator.HasIndex(&models.Domain{}, "idx_domains_host_path") {
+		if err := migrator.CreateIndex(&models.Domain{}, "idx_domains_host_path"); err != nil {
+			fmt.Printf("migration.go: warning creating domain index: %v\n", err)
+		}
+	}
+
 	var wildCardDomain = models.SystemSettingEntry{
 		Key:   "wildcard_domain",
 		Value: " ",
@@ -143,6 +159,16 @@ func MigrateDb(dbInstance *gorm.DB) error {
 		Value: "1.0.3",
 	}
 
+	// domains created before https could be turned off per domain were always redirected,
+	// the flag makes sure this only runs once so later choices are kept
+	var domainsForceHttps = models.SystemSettingEntry{
+		Key:   "domains_force_https_migrated",
+		Value: "true",
+	}
+	if result := dbInstance.Clauses(clause.Insert{Modifier: "OR IGNORE"}).Create(&domainsForceHttps); result.Error == nil && result.RowsAffected > 0 {
+		dbInstance.Model(&models.Domain{}).Where("1 = 1").Update("force_https", true)
+	}
+
 	dbInstance.Clauses(clause.Insert{Modifier: "OR IGNORE"}).Create(&wildCardDomain)
 	dbInstance.Clauses(clause.Insert{Modifier: "OR IGNORE"}).Create(&MistAppName)
 	dbInstance.Clauses(clause.Insert{Modifier: "OR REPLACE"}).Create(&Version)
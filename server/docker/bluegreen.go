@@ -0,0 +1,244 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/utils"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+	"github.com/rs/zerolog/log"
+)
+
+// blue-green swaps are only possible for web apps routed through traefik,
+// apps bound to a host port can't run two containers side by side
+func CanSwapContainer(app *models.App, domains []string, containerName string) bool {
+	if app.AppType != models.AppTypeWeb || len(domains) == 0 {
+		return false
+	}
+	restoreSwappedContainer(containerName)
+	return ContainerExists(containerName)
+}
+
+// a swap interrupted between its renames leaves the serving container under its -old name
+func restoreSwappedContainer(containerName string) {
+	oldName := containerName + "-old"
+	if ContainerExists(containerName) || !ContainerExists(oldName) {
+		return
+	}
+	if err := RenameContainer(oldName, containerName); err != nil {
+		log.Error().Err(err).Str("container", oldName).Msg("Failed to restore container of an interrupted swap")
+	}
+}
+
+// starts the new container next to the old one, waits for it to become healthy and only then
+// removes the old container. if the new container never becomes healthy the old one keeps serving
+func SwapContainer(ctx context.Context, app *models.App, imageTag, containerName string, domains []string, port int, envVars map[string]string, logfile *os.File, logger *utils.DeploymentLogger) error {
+	tempName := containerName + "-next"
+	oldName := containerName + "-old"
+
+	// leftovers from an interrupted swap, the serving container is always containerName here
+	for _, name := range []string{tempName, oldName} {
+		if err := StopRemoveContainer(name, logfile); err != nil {
+			return fmt.Errorf("failed to remove leftover container %s: %w", name, err)
+		}
+	}
+
+	// traefik leaves containers out until docker reports them healthy, without a docker
+	// healthcheck it would route to the new container right away. the image is verified in
+	// a container traefik ignores first, and the routed container gets a check of the app's
+	// port so traefik holds traffic back until the app listens
+	var readiness *container.HealthConfig
+	if BuildHealthcheck(app, port) == nil {
+		fmt.Fprintf(logfile, "[DEPLOY]: Verifying new image in %s before routing traffic to it\n", tempName)
+		if err := runContainer(ctx, app, imageTag, tempName, domains, port, envVars, logfile, runOptions{unrouted: true}); err != nil {
+			StopRemoveContainer(tempName, logfile)
+			return fmt.Errorf("failed to run new container: %w", err)
+		}
+		if err := WaitForHealthy(ctx, tempName, app, port, logfile); err != nil {
+			fmt.Fprintf(logfile, "[DEPLOY]: New container did not become healthy, keeping the old one: %v\n", err)
+			writeContainerLogs(tempName, logfile)
+			StopRemoveContainer(tempName, logfile)
+			return fmt.Errorf("new container is unhealthy: %w", err)
+		}
+		readiness = readinessHealthcheck(ctx, tempName, port, logfile)
+		if err := StopRemoveContainer(tempName, logfile); err != nil {
+			return fmt.Errorf("failed to remove verified container: %w", err)
+		}
+	}
+
+	logger.InfoWithFields("Starting new container next to the old one", map[string]interface{}{
+		"container": tempName,
+		"image":     imageTag,
+	})
+	fmt.Fprintf(logfile, "[DEPLOY]: Starting new container %s\n", tempName)
+
+	if err := runContainer(ctx, app, imageTag, tempName, domains, port, envVars, logfile, runOptions{healthcheck: readiness}); err != nil {
+		StopRemoveContainer(tempName, logfile)
+		return fmt.Errorf("failed to run new container: %w", err)
+	}
+
+	if err := WaitForHealthy(ctx, tempName, app, port, logfile); err != nil {
+		fmt.Fprintf(logfile, "[DEPLOY]: New container did not become healthy, keeping the old one: %v\n", err)
+		writeContainerLogs(tempName, logfile)
+		if rmErr := StopRemoveContainer(tempName, logfile); rmErr != nil {
+			logger.Error(rmErr, "Failed to remove unhealthy container")
+		}
+		return fmt.Errorf("new container is unhealthy: %w", err)
+	}
+
+	// both containers keep serving while they're renamed, the old one is only removed once the
+	// new one holds the app's name
+	fmt.Fprintf(logfile, "[DEPLOY]: New container is healthy, replacing old container %s\n", containerName)
+	if err := RenameContainer(containerName, oldName); err != nil {
+		StopRemoveContainer(tempName, logfile)
+		return fmt.Errorf("failed to rename old container: %w", err)
+	}
+	if err := RenameContainer(tempName, containerName); err != nil {
+		if restoreErr := RenameContainer(oldName, containerName); restoreErr != nil {
+			logger.Error(restoreErr, "Failed to restore old container name")
+		}
+		StopRemoveContainer(tempName, logfile)
+		return fmt.Errorf("failed to rename new container: %w", err)
+	}
+	if err := StopRemoveContainer(oldName, logfile); err != nil {
+		// removed on the next swap
+		logger.Error(err, "Failed to remove old container")
+	}
+
+	logger.Info("Container swap completed")
+	return nil
+}
+
+// waits for a freshly started container to prove it is healthy, this is the grace window of a deploy.
+// docker's own health state is used when the container has a healthcheck, otherwise the healthcheck
+// path is probed directly and without any healthcheck a container that keeps running is healthy
+func WaitForHealthy(ctx context.Context, containerName string, app *models.App, port int, logfile *os.File) error {
+	interval := time.Duration(app.HealthcheckInterval) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	timeout := time.Duration(app.HealthcheckTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	retries := app.HealthcheckRetries
+	if retries <= 0 {
+		retries = 3
+	}
+
+	cli, err := client.New(client.FromEnv)
+	if err != nil {
+		return fmt.Errorf("error creating moby client: %s", err.Error())
+	}
+
+	hasPath := app.HealthcheckPath != nil && *app.HealthcheckPath != ""
+	start := time.Now()
+	deadline := start.Add(healthcheckStartPeriod + time.Duration(retries+1)*(interval+timeout))
+	nextProbe := start
+	failures := 0
+	lastHealth := ""
+
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+
+		inspectCtx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+		inspectResult, err := cli.ContainerInspect(inspectCtx, containerName, client.ContainerInspectOptions{})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to inspect container: %w", err)
+		}
+		state := inspectResult.Container.State
+		if state == nil || !state.Running {
+			exitCode := -1
+			if state != nil {
+				exitCode = state.ExitCode
+			}
+			return fmt.Errorf("container exited with code %d", exitCode)
+		}
+
+		// docker healthcheck takes precedence when the container has one
+		if state.Health != nil && state.Health.Status != container.NoHealthcheck {
+			switch state.Health.Status {
+			case container.Healthy:
+				fmt.Fprintf(logfile, "[HEALTHCHECK]: %s is healthy\n", containerName)
+				return nil
+			case container.Unhealthy:
+				if n := len(state.Health.Log); n > 0 {
+					fmt.Fprintf(logfile, "[HEALTHCHECK]: Last check exited with %d: %s\n", state.Health.Log[n-1].ExitCode, strings.TrimSpace(state.Health.Log[n-1].Output))
+				}
+				return fmt.Errorf("docker healthcheck reported unhealthy")
+			default:
+				if string(state.Health.Status) != lastHealth {
+					lastHealth = string(state.Health.Status)
+					fmt.Fprintf(logfile, "[HEALTHCHECK]: %s is %s\n", containerName, state.Health.Status)
+				}
+				continue
+			}
+		}
+
+		if !hasPath {
+			// no healthcheck configured, a container that stays up is considered healthy
+			if time.Since(start) < 10*time.Second {
+				continue
+			}
+			fmt.Fprintf(logfile, "[HEALTHCHECK]: No healthcheck configured, %s is running\n", containerName)
+			return nil
+		}
+
+		if time.Now().Before(nextProbe) {
+			continue
+		}
+		nextProbe = time.Now().Add(interval)
+
+		err = probeContainer(inspectResult.Container, port, *app.HealthcheckPath, timeout)
+		if err == nil {
+			fmt.Fprintf(logfile, "[HEALTHCHECK]: %s passed %s\n", containerName, *app.HealthcheckPath)
+			return nil
+		}
+
+		failures++
+		fmt.Fprintf(logfile, "[HEALTHCHECK]: Attempt %d/%d failed: %v\n", failures, retries, err)
+		if failures >= retries {
+			return fmt.Errorf("healthcheck failed %d times: %w", failures, err)
+		}
+	}
+
+	return fmt.Errorf("timed out waiting for container to become healthy")
+}
+
+func probeContainer(inspectData container.InspectResponse, port int, path string, timeout time.Duration) error {
+	if inspectData.NetworkSettings == nil {
+		return fmt.Errorf("container has no network settings")
+	}
+	endpoint, ok := inspectData.NetworkSettings.Networks["traefik-net"]
+	if !ok || endpoint == nil || !endpoint.IPAddress.IsValid() {
+		return fmt.Errorf("container has no address on traefik-net")
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	url := fmt.Sprintf("http://%s:%d%s", endpoint.IPAddress.String(), port, path)
+
+	httpClient := &http.Client{Timeout: timeout}
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("%s returned status %d", path, resp.StatusCode)
+	}
+	return nil
+}
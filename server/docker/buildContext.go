@@ -0,0 +1,113 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/corecollectives/mist/models"
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+)
+
+// apps created before the dockerfile path was configurable carry this default
+const legacyDockerfilePath = "DOCKERFILE"
+
+type BuildPaths struct {
+	// absolute path of the build context, the repo joined with the app's root directory
+	ContextDir string
+	// dockerfile path relative to ContextDir, slash separated as the docker api expects it
+	Dockerfile string
+	// false when the app uses the default dockerfile, which may be generated if missing
+	Explicit bool
+}
+
+// resolves the build context and dockerfile of an app inside the cloned repo.
+// both have to stay inside the repo, the dockerfile is relative to the root directory
+func ResolveBuildPaths(app *models.App, repoPath string) (*BuildPaths, error) {
+	root := strings.TrimSpace(app.RootDirectory)
+	if root == "" {
+		root = "."
+	}
+	contextDir, err := joinInside(repoPath, root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root directory %q: %w", root, err)
+	}
+	info, err := os.Stat(contextDir)
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("root directory %q does not exist in the repository", root)
+	}
+
+	dockerfile := ""
+	if app.DockerfilePath != nil {
+		dockerfile = strings.TrimSpace(*app.DockerfilePath)
+	}
+	explicit := dockerfile != "" && dockerfile != legacyDockerfilePath
+	if !explicit {
+		dockerfile = "Dockerfile"
+	}
+
+	dockerfileAbs, err := joinInside(contextDir, dockerfile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dockerfile path %q: %w", dockerfile, err)
+	}
+	if explicit && !fileExists(dockerfileAbs) {
+		return nil, fmt.Errorf("dockerfile %q does not exist in root directory %q", dockerfile, root)
+	}
+
+	rel, err := filepath.Rel(contextDir, dockerfileAbs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dockerfile path %q: %w", dockerfile, err)
+	}
+
+	return &BuildPaths{
+		ContextDir: contextDir,
+		Dockerfile: filepath.ToSlash(rel),
+		Explicit:   explicit,
+	}, nil
+}
+
+// reads .dockerignore from the build context. like the docker cli, the dockerfile
+// and the ignore file itself are always sent so the daemon can read them
+func ReadDockerignore(contextDir, dockerfile string) ([]string, error) {
+	f, err := os.Open(filepath.Join(contextDir, ".dockerignore"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to open .dockerignore: %w", err)
+	}
+	defer f.Close()
+
+	excludes, err := ignorefile.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .dockerignore: %w", err)
+	}
+
+	for _, keep := range []string{".dockerignore", dockerfile} {
+		if excluded, _ := patternmatcher.MatchesOrParentMatches(keep, excludes); excluded {
+			excludes = append(excludes, "!"+keep)
+		}
+	}
+	return excludes, nil
+}
+
+// checks that a configured root directory or dockerfile path stays inside the repository
+func ValidateRepoPath(p string) error {
+	if filepath.IsAbs(p) {
+		return fmt.Errorf("path must be relative to the repository")
+	}
+	cleaned := filepath.Clean(p)
+	if cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("path points outside of the repository")
+	}
+	return nil
+}
+
+func joinInside(base, rel string) (string, error) {
+	if err := ValidateRepoPath(rel); err != nil {
+		return "", err
+	}
+	return filepath.Join(base, rel), nil
+}
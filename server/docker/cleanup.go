@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/corecollectives/mist/models"
 	"github.com/moby/moby/client"
 	"github.com/rs/zerolog/log"
 )
@@ -48,8 +50,15 @@ func CleanupOldImages(appID int64, keepCount int) error {
 		return nil
 	}
 
+	// images of the active deployment and of the deployments that can still be rolled back to must survive cleanup
+	protectedTags, err := getRollbackImageTags(appID, keepCount)
+	if err != nil {
+		return fmt.Errorf("failed to get rollback image tags: %w", err)
+	}
+
 	type imageInfo struct {
 		id      string
+		tags    []string
 		created int64
 	}
 
@@ -57,6 +66,7 @@ func CleanupOldImages(appID int64, keepCount int) error {
 	for _, img := range imageList {
 		images = append(images, imageInfo{
 			id:      img.ID,
+			tags:    img.RepoTags,
 			created: img.Created,
 		})
 	}
@@ -69,6 +79,10 @@ func CleanupOldImages(appID int64, keepCount int) error {
 		imagesToRemove := images[keepCount:]
 
 		for _, img := range imagesToRemove {
+			if isProtectedImage(img.tags, protectedTags) {
+				log.Debug().Str("image_id", img.id).Msg("Skipping image still needed for rollback")
+				continue
+			}
 			rmiCtx, rmiCancel := context.WithTimeout(context.Background(), 1*time.Minute)
 			_, err := cli.ImageRemove(rmiCtx, img.id, client.ImageRemoveOptions{
 				Force: true,
@@ -151,6 +165,34 @@ func CleanupOldImages(appID int64, keepCount int) error {
 	// return nil
 }
 
+func getRollbackImageTags(appID int64, keepCount int) (map[string]bool, error) {
+	tags := make(map[string]bool)
+
+	candidates, err := models.GetRollbackCandidates(appID, keepCount)
+	if err != nil {
+		return nil, err
+	}
+	for _, dep := range candidates {
+		tags[*dep.ImageTag] = true
+	}
+
+	active, err := models.GetActiveDeploymentByAppID(appID)
+	if err == nil && active.ImageTag != nil {
+		tags[*active.ImageTag] = true
+	}
+
+	return tags, nil
+}
+
+func isProtectedImage(repoTags []string, protectedTags map[string]bool) bool {
+	for _, tag := range repoTags {
+		if protectedTags[tag] || protectedTags[strings.TrimSuffix(tag, ":latest")] {
+			return true
+		}
+	}
+	return false
+}
+
 func CleanupDanglingImages() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
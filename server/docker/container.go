@@ -253,9 +253,48 @@ func ContainerExists(name string) bool {
 	// return true
 }
 
-func RunContainer(app *models.App, imageTag, containerName string, domains []string, Port int, envVars map[string]string, logfile *os.File) error {
+func RenameContainer(containerName, newName string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	cli, err := client.New(client.FromEnv)
+	if err != nil {
+		return fmt.Errorf("error creating moby client: %s", err.Error())
+	}
+	_, err = cli.ContainerRename(ctx, containerName, client.ContainerRenameOptions{NewName: newName})
+	if err != nil {
+		return fmt.Errorf("failed to rename container %s to %s: %w", containerName, newName, err)
+	}
+	return nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+func GetContainerID(containerName string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	cli, err := client.New(client.FromEnv)
+	if err != nil {
+		return "", fmt.Errorf("error creating moby client: %s", err.Error())
+	}
+	inspectResult, err := cli.ContainerInspect(ctx, containerName, client.ContainerInspectOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container: %w", err)
+	}
+	return inspectResult.Container.ID, nil
+}
+
+func RunContainer(ctx context.Context, app *models.App, imageTag, containerName string, domains []string, Port int, envVars map[string]string, logfile *os.File) error {
+	return runContainer(ctx, app, imageTag, containerName, domains, Port, envVars, logfile, runOptions{})
+}
+
+type runOptions struct {
+	// keeps a web app on traefik-net but out of traefik, so it gets no traffic
+	unrouted bool
+	// replaces the healthcheck built from the app's settings
+	healthcheck *container.HealthConfig
+}
+
+func runContainer(ctx context.Context, app *models.App, imageTag, containerName string, domains []string, Port int, envVars map[string]string, logfile *os.File, opts runOptions) error {
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 
 	cli, err := client.New(client.FromEnv)
@@ -304,24 +343,14 @@ func RunContainer(app *models.App, imageTag, containerName string, domains []str
 	case models.AppTypeWeb:
 		if len(domains) > 0 {
 			networkMode = "traefik-net"
-			labels["traefik.enable"] = "true"
-
-			var hostRules []string
-			for _, domain := range domains {
-				hostRules = append(hostRules, fmt.Sprintf("Host(`%s`)", domain))
+			if opts.unrouted {
+				labels["traefik.enable"] = "false"
+			} else {
+				labels, err = traefikLabels(app, domains, Port)
+				if err != nil {
+					return err
+				}
 			}
-			hostRule := strings.Join(hostRules, " || ")
-
-			labels[fmt.Sprintf("traefik.http.routers.%s.rule", containerName)] = hostRule
-			labels[fmt.Sprintf("traefik.http.routers.%s.entrypoints", containerName)] = "websecure"
-			labels[fmt.Sprintf("traefik.http.routers.%s.tls", containerName)] = "true"
-			labels[fmt.Sprintf("traefik.http.routers.%s.tls.certresolver", containerName)] = "le"
-			labels[fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port", containerName)] = fmt.Sprintf("%d", Port)
-
-			labels[fmt.Sprintf("traefik.http.routers.%s-http.rule", containerName)] = hostRule
-			labels[fmt.Sprintf("traefik.http.routers.%s-http.entrypoints", containerName)] = "web"
-			labels[fmt.Sprintf("traefik.http.routers.%s-http.middlewares", containerName)] = fmt.Sprintf("%s-https-redirect", containerName)
-			labels[fmt.Sprintf("traefik.http.middlewares.%s-https-redirect.redirectscheme.scheme", containerName)] = "https"
 		} else {
 			port, err := network.ParsePort(fmt.Sprintf("%d/tcp", Port))
 			if err != nil {
@@ -379,6 +408,10 @@ func RunContainer(app *models.App, imageTag, containerName string, domains []str
 		Env:          envList,
 		Labels:       labels,
 		ExposedPorts: exposedPorts,
+		Healthcheck:  BuildHealthcheck(app, Port),
+	}
+	if opts.healthcheck != nil {
+		config.Healthcheck = opts.healthcheck
 	}
 
 	if app.CPULimit != nil && *app.CPULimit > 0 {
@@ -519,6 +552,8 @@ type ContainerStatus struct {
 	State   string `json:"state"`
 	Uptime  string `json:"uptime"`
 	Healthy bool   `json:"healthy"`
+	// starting, healthy, unhealthy or none when the container has no healthcheck
+	Health string `json:"health"`
 }
 
 func GetContainerStatus(containerName string) (*ContainerStatus, error) {
@@ -529,6 +564,7 @@ func GetContainerStatus(containerName string) (*ContainerStatus, error) {
 			State:   "stopped",
 			Uptime:  "N/A",
 			Healthy: false,
+			Health:  string(container.NoHealthcheck),
 		}, nil
 	}
 
@@ -565,8 +601,12 @@ func GetContainerStatus(containerName string) (*ContainerStatus, error) {
 	}
 
 	healthy := true
-	if inspectData.State != nil && inspectData.State.Health != nil {
-		healthy = inspectData.State.Health.Status == "healthy"
+	health := string(container.NoHealthcheck)
+	if inspectData.State != nil && inspectData.State.Health != nil && inspectData.State.Health.Status != "" {
+		health = string(inspectData.State.Health.Status)
+		if inspectData.State.Health.Status != container.NoHealthcheck {
+			healthy = inspectData.State.Health.Status == container.Healthy
+		}
 	}
 
 	return &ContainerStatus{
@@ -575,6 +615,7 @@ func GetContainerStatus(containerName string) (*ContainerStatus, error) {
 		State:   state,
 		Uptime:  uptime,
 		Healthy: healthy,
+		Health:  health,
 	}, nil
 
 	// legacy exec method
@@ -661,7 +702,7 @@ func RecreateContainer(app *models.App) error {
 		return fmt.Errorf("failed to stop/remove container: %w", err)
 	}
 
-	if err := RunContainer(app, imageTag, containerName, domains, port, envVars, nil); err != nil {
+	if err := RunContainer(context.Background(), app, imageTag, containerName, domains, port, envVars, nil); err != nil {
 		return fmt.Errorf("failed to run container: %w", err)
 	}
 
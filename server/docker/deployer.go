@@ -1,11 +1,13 @@
 package docker
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/corecollectives/mist/constants"
@@ -14,7 +16,7 @@ import (
 	"gorm.io/gorm"
 )
 
-func DeployApp(dep *models.Deployment, app *models.App, appContextPath, imageTag, containerName string, db *gorm.DB, logfile *os.File, logger *utils.DeploymentLogger) error {
+func DeployApp(ctx context.Context, dep *models.Deployment, app *models.App, appContextPath, imageTag, containerName string, db *gorm.DB, logfile *os.File, logger *utils.DeploymentLogger) error {
 
 	logger.Info("Starting deployment process")
 
@@ -39,6 +41,8 @@ func DeployApp(dep *models.Deployment, app *models.App, appContextPath, imageTag
 		"appType": app.AppType,
 	})
 
+	imageUnchanged := false
+
 	if app.AppType == models.AppTypeDatabase {
 		logger.Info("Database app detected - pulling Docker image instead of building")
 
@@ -94,7 +98,50 @@ func DeployApp(dep *models.Deployment, app *models.App, appContextPath, imageTag
 			"image": imageName,
 		})
 
-		if err := PullDockerImage(imageName, logfile); err != nil {
+		if err := PullDockerImage(ctx, app.ProjectID, imageName, logfile); err != nil {
+			logger.Error(err, "Docker image pull failed")
+			dep.Status = "failed"
+			dep.Stage = "failed"
+			dep.Progress = 0
+			errMsg := fmt.Sprintf("Pull failed: %v", err)
+			dep.ErrorMessage = &errMsg
+			UpdateDeployment(dep, db)
+			models.UpdateDeploymentStatus(dep.ID, "failed", "failed", 0, &errMsg)
+			UpdateAppStatus(app.ID, "error", db)
+			return fmt.Errorf("pull image failed: %w", err)
+		}
+
+		logger.Info("Docker image pulled successfully")
+		imageTag = imageName
+
+	} else if app.IsImageSource() {
+		if app.Image == nil || strings.TrimSpace(*app.Image) == "" {
+			logger.Error(nil, "Image app missing image reference")
+			dep.Status = "failed"
+			dep.Stage = "failed"
+			dep.Progress = 0
+			errMsg := "Image source apps require an image"
+			dep.ErrorMessage = &errMsg
+			UpdateDeployment(dep, db)
+			models.UpdateDeploymentStatus(dep.ID, "failed", "failed", 0, &errMsg)
+			return fmt.Errorf("image app missing image")
+		}
+		imageName := strings.TrimSpace(*app.Image)
+		// captured before the pull, which moves the tag to the new image
+		previousImageID := runningContainerImageID(containerName)
+
+		dep.Status = "building"
+		dep.Stage = "pulling"
+		dep.Progress = 50
+		UpdateDeployment(dep, db)
+		models.UpdateDeploymentStatus(dep.ID, "building", "pulling", 50, nil)
+
+		logger.InfoWithFields("Pulling prebuilt image", map[string]interface{}{
+			"image": imageName,
+		})
+		fmt.Fprintf(logfile, "[PULL]: Pulling %s\n", imageName)
+
+		if err := PullDockerImage(ctx, app.ProjectID, imageName, logfile); err != nil {
 			logger.Error(err, "Docker image pull failed")
 			dep.Status = "failed"
 			dep.Stage = "failed"
@@ -110,6 +157,21 @@ func DeployApp(dep *models.Deployment, app *models.App, appContextPath, imageTag
 		logger.Info("Docker image pulled successfully")
 		imageTag = imageName
 
+		imageID, digestRef, err := InspectImageDigest(imageName)
+		if err != nil {
+			logger.Error(err, "Failed to inspect pulled image (non-fatal)")
+		}
+		if digestRef != "" {
+			// the tag can move later on, the digest keeps this deployment rollbackable to exactly this image
+			imageTag = digestRef
+			fmt.Fprintf(logfile, "[PULL]: Resolved %s to %s\n", imageName, digestRef)
+		}
+		if imageID != "" && imageID == previousImageID {
+			imageUnchanged = true
+			logger.Info("Image digest unchanged, keeping the running container")
+			fmt.Fprintf(logfile, "[DEPLOY]: Image digest unchanged, keeping the running container\n")
+		}
+
 	} else {
 		dep.Status = "building"
 		dep.Stage = "building"
@@ -117,8 +179,35 @@ func DeployApp(dep *models.Deployment, app *models.App, appContextPath, imageTag
 		UpdateDeployment(dep, db)
 		models.UpdateDeploymentStatus(dep.ID, "building", "building", 50, nil)
 
+		buildPaths, err := ResolveBuildPaths(app, appContextPath)
+		if err != nil {
+			logger.Error(err, "Invalid build configuration")
+			dep.Status = "failed"
+			dep.Stage = "failed"
+			dep.Progress = 0
+			errMsg := fmt.Sprintf("Build failed: %v", err)
+			dep.ErrorMessage = &errMsg
+			UpdateDeployment(dep, db)
+			models.UpdateDeploymentStatus(dep.ID, "failed", "failed", 0, &errMsg)
+			UpdateAppStatus(app.ID, "error", db)
+			return fmt.Errorf("resolve build paths failed: %w", err)
+		}
+
+		if err := EnsureDockerfile(app, buildPaths, port, logfile); err != nil {
+			logger.Error(err, "Dockerfile generation failed")
+			dep.Status = "failed"
+			dep.Stage = "failed"
+			dep.Progress = 0
+			errMsg := fmt.Sprintf("Build failed: %v", err)
+			dep.ErrorMessage = &errMsg
+			UpdateDeployment(dep, db)
+			models.UpdateDeploymentStatus(dep.ID, "failed", "failed", 0, &errMsg)
+			UpdateAppStatus(app.ID, "error", db)
+			return fmt.Errorf("generate dockerfile failed: %w", err)
+		}
+
 		logger.Info("Building Docker image with environment variables")
-		if err := BuildImage(imageTag, appContextPath, envVars, logfile); err != nil {
+		if err := BuildImage(ctx, imageTag, buildPaths.ContextDir, buildPaths.Dockerfile, envVars, logfile); err != nil {
 			logger.Error(err, "Docker image build failed")
 			dep.Status = "failed"
 			dep.Stage = "failed"
@@ -132,17 +221,83 @@ func DeployApp(dep *models.Deployment, app *models.App, appContextPath, imageTag
 		}
 
 		logger.Info("Docker image built successfully")
+
+		if app.PushRegistryID != nil {
+			pushBuiltImage(ctx, app, dep, imageTag, logfile, logger)
+		}
+	}
+
+	// a redeploy of an image app whose digest didn't change leaves the running container alone
+	if !imageUnchanged {
+		dep.Status = "deploying"
+		dep.Stage = "deploying"
+		dep.Progress = 80
+		UpdateDeployment(dep, db)
+		models.UpdateDeploymentStatus(dep.ID, "deploying", "deploying", 80, nil)
+
+		if CanSwapContainer(app, domains, containerName) {
+			logger.Info("Existing container found, performing blue-green swap")
+			if err := SwapContainer(ctx, app, imageTag, containerName, domains, port, envVars, logfile, logger); err != nil {
+				// the old container is still serving, so the app status is left untouched
+				logger.Error(err, "Blue-green swap failed")
+				dep.Status = "failed"
+				dep.Stage = "failed"
+				dep.Progress = 0
+				errMsg := fmt.Sprintf("Failed to swap container: %v", err)
+				dep.ErrorMessage = &errMsg
+				UpdateDeployment(dep, db)
+				models.UpdateDeploymentStatus(dep.ID, "failed", "failed", 0, &errMsg)
+				return fmt.Errorf("swap container failed: %w", err)
+			}
+		} else if err := replaceContainer(ctx, dep, app, imageTag, containerName, domains, port, envVars, db, logfile, logger); err != nil {
+			return err
+		}
 	}
 
-	dep.Status = "deploying"
-	dep.Stage = "deploying"
-	dep.Progress = 80
+	dep.Status = "success"
+	dep.Stage = "success"
+	dep.Progress = 100
+	now := time.Now()
+	dep.FinishedAt = &now
 	UpdateDeployment(dep, db)
-	models.UpdateDeploymentStatus(dep.ID, "deploying", "deploying", 80, nil)
+	models.UpdateDeploymentStatus(dep.ID, "success", "success", 100, nil)
 
-	logger.Info("Stopping existing container if exists")
-	err = StopRemoveContainer(containerName, logfile)
+	// image tag has to be stored so that this deployment can be rolled back to later
+	containerID, err := GetContainerID(containerName)
 	if err != nil {
+		logger.Error(err, "Failed to get container ID (non-fatal)")
+	}
+	if err := models.UpdateContainerInfo(dep.ID, containerID, containerName, imageTag); err != nil {
+		logger.Error(err, "Failed to update deployment container info (non-fatal)")
+	}
+	if err := models.MarkDeploymentActive(dep.ID, app.ID); err != nil {
+		logger.Error(err, "Failed to mark deployment as active (non-fatal)")
+	}
+
+	logger.Info("Updating app status to running")
+	err = UpdateAppStatus(app.ID, "running", db)
+	if err != nil {
+		logger.Error(err, "Failed to update app status (non-fatal)")
+	}
+
+	logger.Info("Cleaning up old Docker images")
+	if err := CleanupOldImages(app.ID, 5); err != nil {
+		logger.Error(err, "Failed to cleanup old images (non-fatal)")
+	}
+
+	logger.InfoWithFields("Deployment succeeded", map[string]interface{}{
+		"deployment_id": dep.ID,
+		"container":     containerName,
+		"app_status":    "running",
+	})
+
+	return nil
+}
+
+// stops the old container before starting the new one, used when a blue-green swap isn't possible
+func replaceContainer(ctx context.Context, dep *models.Deployment, app *models.App, imageTag, containerName string, domains []string, port int, envVars map[string]string, db *gorm.DB, logfile *os.File, logger *utils.DeploymentLogger) error {
+	logger.Info("Stopping existing container if exists")
+	if err := StopRemoveContainer(containerName, logfile); err != nil {
 		logger.Error(err, "Failed to stop/remove existing container")
 		dep.Status = "failed"
 		dep.Stage = "failed"
@@ -162,7 +317,7 @@ func DeployApp(dep *models.Deployment, app *models.App, appContextPath, imageTag
 		"appType": app.AppType,
 	})
 
-	if err := RunContainer(app, imageTag, containerName, domains, port, envVars, logfile); err != nil {
+	if err := RunContainer(ctx, app, imageTag, containerName, domains, port, envVars, logfile); err != nil {
 		logger.Error(err, "Failed to run container")
 		dep.Status = "failed"
 		dep.Stage = "failed"
@@ -175,32 +330,47 @@ func DeployApp(dep *models.Deployment, app *models.App, appContextPath, imageTag
 		return fmt.Errorf("run container failed: %w", err)
 	}
 
-	dep.Status = "success"
-	dep.Stage = "success"
-	dep.Progress = 100
-	now := time.Now()
-	dep.FinishedAt = &now
-	UpdateDeployment(dep, db)
-	models.UpdateDeploymentStatus(dep.ID, "success", "success", 100, nil)
-
-	logger.Info("Updating app status to running")
-	err = UpdateAppStatus(app.ID, "running", db)
-	if err != nil {
-		logger.Error(err, "Failed to update app status (non-fatal)")
+	if err := WaitForHealthy(ctx, containerName, app, port, logfile); err != nil {
+		logger.Error(err, "Container did not become healthy")
+		fmt.Fprintf(logfile, "[DEPLOY]: Container did not become healthy: %v\n", err)
+		writeContainerLogs(containerName, logfile)
+		dep.Status = "failed"
+		dep.Stage = "failed"
+		dep.Progress = 0
+		errMsg := fmt.Sprintf("Container is unhealthy: %v", err)
+		dep.ErrorMessage = &errMsg
+		UpdateDeployment(dep, db)
+		models.UpdateDeploymentStatus(dep.ID, "failed", "failed", 0, &errMsg)
+		UpdateAppStatus(app.ID, "error", db)
+		return fmt.Errorf("container unhealthy: %w", err)
 	}
 
-	logger.Info("Cleaning up old Docker images")
-	if err := CleanupOldImages(app.ID, 5); err != nil {
-		logger.Error(err, "Failed to cleanup old images (non-fatal)")
+	return nil
+}
+
+// pushing is best effort, the image was built and the deploy goes on with the local copy
+func pushBuiltImage(ctx context.Context, app *models.App, dep *models.Deployment, imageTag string, logfile *os.File, logger *utils.DeploymentLogger) {
+	reg, err := models.GetRegistryByID(*app.PushRegistryID)
+	if err != nil || reg == nil || reg.ProjectID != app.ProjectID {
+		logger.Warn("Push registry not found, skipping image push")
+		fmt.Fprintf(logfile, "[PUSH]: Registry %d not found in this project, skipping push\n", *app.PushRegistryID)
+		return
 	}
 
-	logger.InfoWithFields("Deployment succeeded", map[string]interface{}{
-		"deployment_id": dep.ID,
-		"container":     containerName,
-		"app_status":    "running",
+	tag := dep.CommitHash
+	if len(tag) > 12 {
+		tag = tag[:12]
+	}
+	remoteRef, err := PushImage(ctx, reg, imageTag, fmt.Sprintf("mist-app-%d", app.ID), tag, logfile)
+	if err != nil {
+		logger.Error(err, "Failed to push image (non-fatal)")
+		fmt.Fprintf(logfile, "[PUSH]: Failed to push image: %v\n", err)
+		return
+	}
+	logger.InfoWithFields("Image pushed", map[string]interface{}{
+		"image": remoteRef,
 	})
-
-	return nil
+	fmt.Fprintf(logfile, "[PUSH]: Pushed %s\n", remoteRef)
 }
 
 func UpdateDeployment(dep *models.Deployment, db *gorm.DB) error {
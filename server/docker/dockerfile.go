@@ -0,0 +1,418 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/corecollectives/mist/models"
+)
+
+type Ecosystem string
+
+const (
+	EcosystemNode   Ecosystem = "node"
+	EcosystemGo     Ecosystem = "go"
+	EcosystemPython Ecosystem = "python"
+	EcosystemRust   Ecosystem = "rust"
+	EcosystemPHP    Ecosystem = "php"
+	EcosystemStatic Ecosystem = "static"
+)
+
+// writes a generated Dockerfile into the build context when the repo doesn't ship one.
+// the generated file is copied into the build logs so it can be committed to the repo.
+// a dockerfile path configured on the app is never generated, ResolveBuildPaths already checked it exists
+func EnsureDockerfile(app *models.App, paths *BuildPaths, port int, logfile *os.File) error {
+	dockerfile := filepath.Join(paths.ContextDir, filepath.FromSlash(paths.Dockerfile))
+	if paths.Explicit || fileExists(dockerfile) {
+		return nil
+	}
+
+	ecosystem, err := DetectEcosystem(paths.ContextDir, app)
+	if err != nil {
+		return err
+	}
+
+	content, err := GenerateDockerfile(ecosystem, app, paths.ContextDir, port)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(dockerfile, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write generated Dockerfile: %w", err)
+	}
+
+	fmt.Fprintf(logfile, "[BUILD]: No Dockerfile found, detected %s project\n", ecosystem)
+	fmt.Fprintf(logfile, "[BUILD]: Generated Dockerfile:\n")
+	for _, line := range strings.Split(strings.TrimRight(content, "\n"), "\n") {
+		fmt.Fprintf(logfile, "[BUILD]:   %s\n", line)
+	}
+	return nil
+}
+
+// order matters, a node project often also has an index.html and a php project a package.json
+func DetectEcosystem(dir string, app *models.App) (Ecosystem, error) {
+	switch {
+	case fileExists(filepath.Join(dir, "package.json")):
+		if isStaticNodeProject(dir, app) {
+			return EcosystemStatic, nil
+		}
+		return EcosystemNode, nil
+	case fileExists(filepath.Join(dir, "go.mod")):
+		return EcosystemGo, nil
+	case fileExists(filepath.Join(dir, "Cargo.toml")):
+		return EcosystemRust, nil
+	case fileExists(filepath.Join(dir, "requirements.txt")), fileExists(filepath.Join(dir, "pyproject.toml")):
+		return EcosystemPython, nil
+	case fileExists(filepath.Join(dir, "composer.json")), fileExists(filepath.Join(dir, "index.php")):
+		return EcosystemPHP, nil
+	case fileExists(filepath.Join(dir, "index.html")):
+		return EcosystemStatic, nil
+	}
+	return "", fmt.Errorf("no Dockerfile found and the project type could not be detected, add a Dockerfile to the repository")
+}
+
+// vite, create-react-app, astro and similar projects only build files to serve. they are recognized
+// by a build script without a start script, or by the app having an output directory
+func isStaticNodeProject(dir string, app *models.App) bool {
+	if app.OutputDirectory != nil && strings.TrimSpace(*app.OutputDirectory) != "" {
+		return true
+	}
+	if app.StartCommand != nil && strings.TrimSpace(*app.StartCommand) != "" {
+		return false
+	}
+	project, err := readNodeProject(dir)
+	if err != nil {
+		// nodeDockerfile reports the broken package.json
+		return false
+	}
+	return project.scripts["build"] != "" && project.scripts["start"] == ""
+}
+
+func GenerateDockerfile(ecosystem Ecosystem, app *models.App, dir string, port int) (string, error) {
+	buildCmd := ""
+	if app.BuildCommand != nil {
+		buildCmd = strings.TrimSpace(*app.BuildCommand)
+	}
+	startCmd := ""
+	if app.StartCommand != nil {
+		startCmd = strings.TrimSpace(*app.StartCommand)
+	}
+
+	switch ecosystem {
+	case EcosystemNode:
+		return nodeDockerfile(dir, buildCmd, startCmd, port)
+	case EcosystemGo:
+		return goDockerfile(dir, buildCmd, startCmd, port), nil
+	case EcosystemRust:
+		return rustDockerfile(dir, buildCmd, startCmd, port)
+	case EcosystemPython:
+		return pythonDockerfile(dir, buildCmd, startCmd, port)
+	case EcosystemPHP:
+		return phpDockerfile(dir, buildCmd, startCmd, port), nil
+	case EcosystemStatic:
+		outputDir := ""
+		if app.OutputDirectory != nil {
+			outputDir = strings.TrimSpace(*app.OutputDirectory)
+		}
+		return staticDockerfile(dir, buildCmd, outputDir, port)
+	}
+	return "", fmt.Errorf("unsupported ecosystem %q", ecosystem)
+}
+
+type nodeProject struct {
+	scripts     map[string]string
+	nodeVersion string
+	// package manager, its install command and what the image needs to run it
+	pm      string
+	install string
+	setup   string
+}
+
+func readNodeProject(dir string) (*nodeProject, error) {
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+		Engines struct {
+			Node string `json:"node"`
+		} `json:"engines"`
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse package.json: %w", err)
+	}
+
+	project := &nodeProject{scripts: pkg.Scripts, nodeVersion: "20", pm: "npm", install: "npm install"}
+	if v := regexp.MustCompile(`\d+`).FindString(pkg.Engines.Node); v != "" {
+		project.nodeVersion = v
+	}
+	switch {
+	case fileExists(filepath.Join(dir, "pnpm-lock.yaml")):
+		project.pm, project.install = "pnpm", "pnpm install --frozen-lockfile"
+		project.setup = "RUN corepack enable\n"
+	case fileExists(filepath.Join(dir, "yarn.lock")):
+		project.pm, project.install = "yarn", "yarn install --frozen-lockfile"
+		project.setup = "RUN corepack enable\n"
+	case fileExists(filepath.Join(dir, "package-lock.json")):
+		project.install = "npm ci"
+	}
+	return project, nil
+}
+
+func nodeDockerfile(dir, buildCmd, startCmd string, port int) (string, error) {
+	project, err := readNodeProject(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if buildCmd == "" && project.scripts["build"] != "" {
+		buildCmd = project.pm + " run build"
+	}
+	if startCmd == "" {
+		switch {
+		case project.scripts["start"] != "":
+			startCmd = project.pm + " start"
+		case fileExists(filepath.Join(dir, "server.js")):
+			startCmd = "node server.js"
+		case fileExists(filepath.Join(dir, "index.js")):
+			startCmd = "node index.js"
+		default:
+			return "", fmt.Errorf("no start script in package.json, set a start command for the app")
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM node:%s-alpine AS build\n", project.nodeVersion)
+	b.WriteString("WORKDIR /app\n")
+	b.WriteString(project.setup)
+	b.WriteString("COPY . .\n")
+	fmt.Fprintf(&b, "RUN %s\n", project.install)
+	if buildCmd != "" {
+		fmt.Fprintf(&b, "RUN %s\n", buildCmd)
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "FROM node:%s-alpine\n", project.nodeVersion)
+	b.WriteString("WORKDIR /app\n")
+	b.WriteString(project.setup)
+	b.WriteString("ENV NODE_ENV=production\n")
+	b.WriteString("COPY --from=build /app /app\n")
+	writeRuntime(&b, port, startCmd)
+	return b.String(), nil
+}
+
+func goDockerfile(dir, buildCmd, startCmd string, port int) string {
+	goVersion := "1"
+	if data, err := os.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+		if m := regexp.MustCompile(`(?m)^go (\d+\.\d+)`).FindSubmatch(data); m != nil {
+			goVersion = string(m[1])
+		}
+	}
+
+	// custom build commands are expected to put their binaries in /out as well
+	if buildCmd == "" {
+		buildCmd = "go build -o /out/app ."
+	}
+	if startCmd == "" {
+		startCmd = "/app/app"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM golang:%s-alpine AS build\n", goVersion)
+	b.WriteString("WORKDIR /src\n")
+	b.WriteString("COPY go.mod go.sum* ./\n")
+	b.WriteString("RUN go mod download\n")
+	b.WriteString("COPY . .\n")
+	b.WriteString("ENV CGO_ENABLED=0\n")
+	b.WriteString("RUN mkdir -p /out\n")
+	fmt.Fprintf(&b, "RUN %s\n", buildCmd)
+	b.WriteString("\n")
+	b.WriteString("FROM alpine:3.20\n")
+	b.WriteString("RUN apk add --no-cache ca-certificates tzdata\n")
+	b.WriteString("WORKDIR /app\n")
+	b.WriteString("COPY --from=build /out/ /app/\n")
+	writeRuntime(&b, port, startCmd)
+	return b.String()
+}
+
+func rustDockerfile(dir, buildCmd, startCmd string, port int) (string, error) {
+	if startCmd == "" {
+		data, err := os.ReadFile(filepath.Join(dir, "Cargo.toml"))
+		if err != nil {
+			return "", fmt.Errorf("failed to read Cargo.toml: %w", err)
+		}
+		m := regexp.MustCompile(`(?m)^name\s*=\s*"([^"]+)"`).FindSubmatch(data)
+		if m == nil {
+			return "", fmt.Errorf("no package name in Cargo.toml, set a start command for the app")
+		}
+		startCmd = "/app/" + string(m[1])
+	}
+	if buildCmd == "" {
+		buildCmd = "cargo build --release"
+	}
+
+	var b strings.Builder
+	b.WriteString("FROM rust:1-slim AS build\n")
+	b.WriteString("WORKDIR /src\n")
+	b.WriteString("COPY . .\n")
+	fmt.Fprintf(&b, "RUN %s\n", buildCmd)
+	b.WriteString("RUN mkdir -p /out && find target/release -maxdepth 1 -type f -perm -u+x -exec cp {} /out/ \\;\n")
+	b.WriteString("\n")
+	b.WriteString("FROM debian:bookworm-slim\n")
+	b.WriteString("RUN apt-get update && apt-get install -y --no-install-recommends ca-certificates && rm -rf /var/lib/apt/lists/*\n")
+	b.WriteString("WORKDIR /app\n")
+	b.WriteString("COPY --from=build /out/ /app/\n")
+	writeRuntime(&b, port, startCmd)
+	return b.String(), nil
+}
+
+func pythonDockerfile(dir, buildCmd, startCmd string, port int) (string, error) {
+	install := "pip install --no-cache-dir ."
+	if fileExists(filepath.Join(dir, "requirements.txt")) {
+		install = "pip install --no-cache-dir -r requirements.txt"
+	}
+
+	if startCmd == "" {
+		switch {
+		case fileExists(filepath.Join(dir, "manage.py")):
+			startCmd = fmt.Sprintf("python manage.py runserver 0.0.0.0:%d", port)
+		case fileExists(filepath.Join(dir, "main.py")):
+			startCmd = "python main.py"
+		case fileExists(filepath.Join(dir, "app.py")):
+			startCmd = "python app.py"
+		default:
+			return "", fmt.Errorf("could not find an entrypoint, set a start command for the app")
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("FROM python:3.12-slim AS build\n")
+	b.WriteString("WORKDIR /app\n")
+	b.WriteString("RUN python -m venv /opt/venv\n")
+	b.WriteString("ENV PATH=\"/opt/venv/bin:$PATH\"\n")
+	b.WriteString("COPY . .\n")
+	fmt.Fprintf(&b, "RUN %s\n", install)
+	if buildCmd != "" {
+		fmt.Fprintf(&b, "RUN %s\n", buildCmd)
+	}
+	b.WriteString("\n")
+	b.WriteString("FROM python:3.12-slim\n")
+	b.WriteString("WORKDIR /app\n")
+	b.WriteString("ENV PATH=\"/opt/venv/bin:$PATH\" PYTHONUNBUFFERED=1\n")
+	b.WriteString("COPY --from=build /opt/venv /opt/venv\n")
+	b.WriteString("COPY --from=build /app /app\n")
+	writeRuntime(&b, port, startCmd)
+	return b.String(), nil
+}
+
+func phpDockerfile(dir, buildCmd, startCmd string, port int) string {
+	var b strings.Builder
+	if fileExists(filepath.Join(dir, "composer.json")) {
+		b.WriteString("FROM composer:2 AS build\n")
+		b.WriteString("WORKDIR /app\n")
+		b.WriteString("COPY . .\n")
+		b.WriteString("RUN composer install --no-dev --optimize-autoloader --no-interaction\n")
+	} else {
+		b.WriteString("FROM alpine:3.20 AS build\n")
+		b.WriteString("WORKDIR /app\n")
+		b.WriteString("COPY . .\n")
+	}
+	if buildCmd != "" {
+		fmt.Fprintf(&b, "RUN %s\n", buildCmd)
+	}
+	b.WriteString("\n")
+	b.WriteString("FROM php:8.3-apache\n")
+	fmt.Fprintf(&b, "RUN sed -i 's/Listen 80/Listen %d/' /etc/apache2/ports.conf && sed -i 's/:80>/:%d>/' /etc/apache2/sites-available/000-default.conf\n", port, port)
+	b.WriteString("COPY --from=build /app /var/www/html\n")
+	fmt.Fprintf(&b, "EXPOSE %d\n", port)
+	if startCmd != "" {
+		fmt.Fprintf(&b, "CMD %s\n", shellCmd(startCmd))
+	}
+	return b.String()
+}
+
+// build output directories of common static site generators, tried in order when the app doesn't set one
+var staticOutputDirs = []string{"dist", "build", "out", "public"}
+
+func staticDockerfile(dir, buildCmd, outputDir string, port int) (string, error) {
+	var b strings.Builder
+	switch {
+	case fileExists(filepath.Join(dir, "package.json")):
+		project, err := readNodeProject(dir)
+		if err != nil {
+			return "", err
+		}
+		if buildCmd == "" && project.scripts["build"] != "" {
+			buildCmd = project.pm + " run build"
+		}
+		fmt.Fprintf(&b, "FROM node:%s-alpine AS build\n", project.nodeVersion)
+		b.WriteString("WORKDIR /site\n")
+		b.WriteString(project.setup)
+		b.WriteString("COPY . .\n")
+		fmt.Fprintf(&b, "RUN %s\n", project.install)
+	case buildCmd != "":
+		// build commands of static sites are usually npm scripts
+		b.WriteString("FROM node:20-alpine AS build\n")
+		b.WriteString("WORKDIR /site\n")
+		b.WriteString("COPY . .\n")
+	default:
+		b.WriteString("FROM alpine:3.20 AS build\n")
+		b.WriteString("WORKDIR /site\n")
+		b.WriteString("COPY . .\n")
+	}
+	if buildCmd != "" {
+		fmt.Fprintf(&b, "RUN %s\n", buildCmd)
+	}
+	// the served files end up in /out
+	switch {
+	case outputDir != "":
+		fmt.Fprintf(&b, "RUN %s\n", execForm("cp", "-a", outputDir, "/out"))
+	case buildCmd != "":
+		fmt.Fprintf(&b, "RUN for d in %s; do if [ -d \"$d\" ]; then cp -a \"$d\" /out; break; fi; done && "+
+			"test -d /out || (echo 'build output not found, set the output directory of the app' >&2 && exit 1)\n",
+			strings.Join(staticOutputDirs, " "))
+	default:
+		b.WriteString("RUN cp -a . /out && rm -rf /out/.git\n")
+	}
+	b.WriteString("\n")
+	b.WriteString("FROM nginx:alpine\n")
+	// replaces the stock config, it listens on port 80 for ipv4 and ipv6
+	fmt.Fprintf(&b, "RUN printf 'server {\\n    listen %d;\\n    listen [::]:%d;\\n    root /usr/share/nginx/html;\\n    index index.html;\\n"+
+		"    location / {\\n        try_files $uri $uri/ =404;\\n    }\\n}\\n' > /etc/nginx/conf.d/default.conf\n", port, port)
+	b.WriteString("COPY --from=build /out /usr/share/nginx/html\n")
+	fmt.Fprintf(&b, "EXPOSE %d\n", port)
+	return b.String(), nil
+}
+
+func writeRuntime(b *strings.Builder, port int, startCmd string) {
+	fmt.Fprintf(b, "ENV PORT=%d\n", port)
+	fmt.Fprintf(b, "EXPOSE %d\n", port)
+	fmt.Fprintf(b, "CMD %s\n", shellCmd(startCmd))
+}
+
+// exec form without a shell, arguments are passed as they are
+func execForm(args ...string) string {
+	var quoted strings.Builder
+	enc := json.NewEncoder(&quoted)
+	enc.SetEscapeHTML(false)
+	enc.Encode(args)
+	return strings.TrimSpace(quoted.String())
+}
+
+// exec form through sh so that start commands can use env vars and chained commands
+func shellCmd(cmd string) string {
+	var quoted strings.Builder
+	enc := json.NewEncoder(&quoted)
+	enc.SetEscapeHTML(false)
+	enc.Encode(cmd)
+	return fmt.Sprintf(`["sh", "-c", %s]`, strings.TrimSpace(quoted.String()))
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,129 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/moby/moby/api/pkg/stdcopy"
+	"github.com/moby/moby/client"
+)
+
+// runs cmd inside a running container like `docker exec -i`. stdin is streamed into the command when set
+// and its stdout is copied to stdout, stderr is only kept for the error message
+func ExecInContainer(ctx context.Context, containerName string, cmd []string, stdin io.Reader, stdout io.Writer) error {
+	stderr := NewTailBuffer(4096)
+	exitCode, err := Exec(ctx, containerName, cmd, stdin, stdout, stderr)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return fmt.Errorf("command exited with code %d", exitCode)
+		}
+		return fmt.Errorf("command exited with code %d: %s", exitCode, msg)
+	}
+	return nil
+}
+
+// same as ExecInContainer but hands both output streams to the caller and returns the exit code
+// instead of turning it into an error. the error is only set when the command couldn't be run
+func Exec(ctx context.Context, containerName string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	cli, err := client.New(client.FromEnv)
+	if err != nil {
+		return -1, fmt.Errorf("error creating moby client: %s", err.Error())
+	}
+
+	created, err := cli.ExecCreate(ctx, containerName, client.ExecCreateOptions{
+		AttachStdin:  stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return -1, fmt.Errorf("failed to create exec in container %s: %w", containerName, err)
+	}
+
+	attach, err := cli.ExecAttach(ctx, created.ID, client.ExecAttachOptions{})
+	if err != nil {
+		return -1, fmt.Errorf("failed to attach to exec in container %s: %w", containerName, err)
+	}
+	defer attach.Close()
+
+	// the hijacked connection doesn't watch the context, closing it unblocks the copies below
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			attach.Close()
+		case <-done:
+		}
+	}()
+
+	stdinErr := make(chan error, 1)
+	if stdin != nil {
+		go func() {
+			_, err := io.Copy(attach.Conn, stdin)
+			// closing the write side sends EOF, without it the command waits for more input
+			attach.CloseWrite()
+			stdinErr <- err
+		}()
+	} else {
+		stdinErr <- nil
+	}
+
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	if _, err := stdcopy.StdCopy(stdout, stderr, attach.Reader); err != nil {
+		if ctx.Err() != nil {
+			return -1, ctx.Err()
+		}
+		return -1, fmt.Errorf("failed to read exec output: %w", err)
+	}
+	if err := <-stdinErr; err != nil {
+		return -1, fmt.Errorf("failed to write exec input: %w", err)
+	}
+
+	inspect, err := cli.ExecInspect(ctx, created.ID, client.ExecInspectOptions{})
+	if err != nil {
+		return -1, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	return inspect.ExitCode, nil
+}
+
+// keeps the last max bytes written, enough for the error a failing command prints last
+type TailBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func NewTailBuffer(max int) *TailBuffer {
+	return &TailBuffer{max: max}
+}
+
+func (t *TailBuffer) Write(p []byte) (int, error) {
+	t.buf.Write(p)
+	if extra := t.buf.Len() - t.max; extra > 0 {
+		t.buf.Next(extra)
+		t.truncated = true
+	}
+	return len(p), nil
+}
+
+func (t *TailBuffer) String() string {
+	return t.buf.String()
+}
+
+// whether earlier output was dropped
+func (t *TailBuffer) Truncated() bool {
+	return t.truncated
+}
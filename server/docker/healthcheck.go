@@ -0,0 +1,124 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/corecollectives/mist/models"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+)
+
+// time docker gives a container to boot before failed checks count against it
+const healthcheckStartPeriod = 30 * time.Second
+
+// translates the app's healthcheck settings into a docker healthcheck. web apps and services
+// with a healthcheck path get an http probe, databases use the command of their template.
+// returns nil when nothing is configured so a HEALTHCHECK from the image still applies
+func BuildHealthcheck(app *models.App, port int) *container.HealthConfig {
+	interval := app.HealthcheckInterval
+	if interval <= 0 {
+		interval = 30
+	}
+	timeout := app.HealthcheckTimeout
+	if timeout <= 0 {
+		timeout = 10
+	}
+	retries := app.HealthcheckRetries
+	if retries <= 0 {
+		retries = 3
+	}
+
+	var test []string
+	switch {
+	case app.AppType == models.AppTypeDatabase:
+		if app.TemplateName == nil || *app.TemplateName == "" {
+			return nil
+		}
+		template, err := models.GetServiceTemplateByName(*app.TemplateName)
+		if err != nil || template == nil || template.HealthcheckCommand == nil || strings.TrimSpace(*template.HealthcheckCommand) == "" {
+			return nil
+		}
+		if template.HealthcheckInterval > 0 {
+			interval = template.HealthcheckInterval
+		}
+		test = []string{"CMD-SHELL", *template.HealthcheckCommand}
+
+	case app.HealthcheckPath != nil && strings.TrimSpace(*app.HealthcheckPath) != "":
+		path := strings.TrimSpace(*app.HealthcheckPath)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		url := fmt.Sprintf("http://127.0.0.1:%d%s", port, path)
+		// images ship either curl or wget, if neither exists the check fails and the app is reported unhealthy
+		test = []string{"CMD-SHELL", fmt.Sprintf(
+			"if command -v curl >/dev/null 2>&1; then curl -fsS -o /dev/null --max-time %d '%s'; else wget -q -O /dev/null -T %d '%s'; fi || exit 1",
+			timeout, url, timeout, url,
+		)}
+
+	default:
+		return nil
+	}
+
+	return &container.HealthConfig{
+		Test:        test,
+		Interval:    time.Duration(interval) * time.Second,
+		Timeout:     time.Duration(timeout) * time.Second,
+		Retries:     retries,
+		StartPeriod: healthcheckStartPeriod,
+	}
+}
+
+// succeeds once something listens on the port. nc, curl, wget and bash cover most images,
+// any http response counts, the app only has to accept connections
+func portProbeCommand(port int) string {
+	url := fmt.Sprintf("http://127.0.0.1:%d/", port)
+	return fmt.Sprintf(
+		"nc -z 127.0.0.1 %d 2>/dev/null || curl -s -o /dev/null --max-time 5 %s 2>/dev/null || "+
+			"wget -q -S -O /dev/null -T 5 %s 2>&1 | grep -q 'HTTP/' || bash -c 'exec 3<>/dev/tcp/127.0.0.1/%d' 2>/dev/null",
+		port, url, url, port,
+	)
+}
+
+// healthcheck that keeps a swapped in container out of traefik until its port accepts connections.
+// the probe is tried in the verified container first, nil when the image can't run it or brings
+// its own HEALTHCHECK, which traefik waits for already
+func readinessHealthcheck(ctx context.Context, containerName string, port int, logfile *os.File) *container.HealthConfig {
+	cli, err := client.New(client.FromEnv)
+	if err != nil {
+		return nil
+	}
+	inspectResult, err := cli.ContainerInspect(ctx, containerName, client.ContainerInspectOptions{})
+	if err != nil {
+		return nil
+	}
+	if cfg := inspectResult.Container.Config; cfg != nil && cfg.Healthcheck != nil && len(cfg.Healthcheck.Test) > 0 && cfg.Healthcheck.Test[0] != "NONE" {
+		return nil
+	}
+
+	probe := portProbeCommand(port)
+	probeCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := ExecInContainer(probeCtx, containerName, []string{"sh", "-c", probe}, nil, io.Discard); err != nil {
+		fmt.Fprintf(logfile, "[DEPLOY]: Port %d can't be checked inside the image, traffic may reach the new container before it listens. Set a healthcheck path to avoid this\n", port)
+		return nil
+	}
+	return &container.HealthConfig{
+		Test:          []string{"CMD-SHELL", probe},
+		Interval:      30 * time.Second,
+		Timeout:       10 * time.Second,
+		Retries:       3,
+		StartPeriod:   healthcheckStartPeriod,
+		StartInterval: 2 * time.Second,
+	}
+}
+
+func writeContainerLogs(containerName string, logfile *os.File) {
+	if logs, err := GetContainerLogs(containerName, 50); err == nil && logs != "" {
+		fmt.Fprintf(logfile, "[DEPLOY]: Last logs of %s:\n%s\n", containerName, logs)
+	}
+}
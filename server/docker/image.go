@@ -7,20 +7,25 @@ import (
 	"os"
 	"time"
 
+	"github.com/distribution/reference"
 	"github.com/moby/go-archive"
 	"github.com/moby/moby/client"
 	"github.com/rs/zerolog/log"
 )
 
-func BuildImage(imageTag, contextPath string, envVars map[string]string, logfile *os.File) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+func BuildImage(ctx context.Context, imageTag, contextPath, dockerfile string, envVars map[string]string, logfile *os.File) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Minute)
 	defer cancel()
 	cli, err := client.New(client.FromEnv)
 	if err != nil {
 		return fmt.Errorf("error opening moby client: %s", err.Error())
 	}
+	excludes, err := ReadDockerignore(contextPath, dockerfile)
+	if err != nil {
+		return err
+	}
 	buildCtx, err := archive.TarWithOptions(contextPath, &archive.TarOptions{
-		ExcludePatterns: []string{},
+		ExcludePatterns: excludes,
 	})
 
 	if err != nil {
@@ -34,9 +39,10 @@ func BuildImage(imageTag, contextPath string, envVars map[string]string, logfile
 		env[k] = &val
 	}
 	buildOptions := client.ImageBuildOptions{
-		Tags:      tags,
-		Remove:    true,
-		BuildArgs: env,
+		Tags:       tags,
+		Remove:     true,
+		BuildArgs:  env,
+		Dockerfile: dockerfile,
 	}
 
 	log.Info().Str("image_tag", imageTag).Msg("Building Docker image")
@@ -88,8 +94,9 @@ func BuildImage(imageTag, contextPath string, envVars map[string]string, logfile
 	// return nil
 }
 
-func PullDockerImage(imageName string, logfile *os.File) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+// pulls with the credentials of the project registry matching the image host, anonymously otherwise
+func PullDockerImage(ctx context.Context, projectID int64, imageName string, logfile *os.File) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Minute)
 	defer cancel()
 
 	cli, err := client.New(client.FromEnv)
@@ -97,8 +104,13 @@ func PullDockerImage(imageName string, logfile *os.File) error {
 		return fmt.Errorf("error opening moby client: %s", err.Error())
 	}
 
-	log.Debug().Str("image_name", imageName).Msg("pulling image")
-	resp, err := cli.ImagePull(ctx, imageName, client.ImagePullOptions{})
+	auth, err := RegistryAuthForImage(projectID, imageName)
+	if err != nil {
+		return err
+	}
+
+	log.Debug().Str("image_name", imageName).Bool("authenticated", auth != "").Msg("pulling image")
+	resp, err := cli.ImagePull(ctx, imageName, client.ImagePullOptions{RegistryAuth: auth})
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			return fmt.Errorf("image pull timed out after 15 minutes")
@@ -131,3 +143,57 @@ func PullDockerImage(imageName string, logfile *os.File) error {
 	// }
 	// return nil
 }
+
+// local image id of a pulled image and its registry digest reference (name@sha256:...),
+// the digest reference is empty for images that never came from a registry
+func InspectImageDigest(imageName string) (string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	cli, err := client.New(client.FromEnv)
+	if err != nil {
+		return "", "", fmt.Errorf("error creating moby client: %s", err.Error())
+	}
+	inspectResult, err := cli.ImageInspect(ctx, imageName)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to inspect image: %w", err)
+	}
+
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return inspectResult.ID, "", nil
+	}
+	for _, repoDigest := range inspectResult.RepoDigests {
+		digested, err := reference.ParseNormalizedNamed(repoDigest)
+		if err == nil && digested.Name() == named.Name() {
+			return inspectResult.ID, reference.FamiliarString(digested), nil
+		}
+	}
+	return inspectResult.ID, "", nil
+}
+
+// image id the container is running, empty when the container doesn't exist or is stopped
+func runningContainerImageID(containerName string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	cli, err := client.New(client.FromEnv)
+	if err != nil {
+		return ""
+	}
+	inspectResult, err := cli.ContainerInspect(ctx, containerName, client.ContainerInspectOptions{})
+	if err != nil || inspectResult.Container.State == nil || !inspectResult.Container.State.Running {
+		return ""
+	}
+	return inspectResult.Container.Image
+}
+
+func ImageExists(imageTag string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	cli, err := client.New(client.FromEnv)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create docker client")
+		return false
+	}
+	_, err = cli.ImageInspect(ctx, imageTag)
+	return err == nil
+}
@@ -0,0 +1,122 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/moby/moby/api/pkg/stdcopy"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+)
+
+// runs command with `sh -c` in a new container that uses the image, env, volumes and network of
+// the app's container, like `docker run --rm`. output is copied to stdout and stderr, the container
+// is removed afterwards, also when ctx is cancelled
+func RunOneOff(ctx context.Context, containerName, command string, labels map[string]string, stdout, stderr io.Writer) (int, error) {
+	cli, err := client.New(client.FromEnv)
+	if err != nil {
+		return -1, fmt.Errorf("error creating moby client: %s", err.Error())
+	}
+	defer cli.Close()
+
+	inspectResult, err := cli.ContainerInspect(ctx, containerName, client.ContainerInspectOptions{})
+	if err != nil {
+		return -1, fmt.Errorf("failed to inspect container %s: %w", containerName, err)
+	}
+	app := inspectResult.Container
+	if app.Config == nil || app.HostConfig == nil {
+		return -1, fmt.Errorf("container %s has no config", containerName)
+	}
+
+	// the image id rather than the tag, so the command runs against exactly what the app runs
+	image := app.Image
+	if image == "" {
+		image = app.Config.Image
+	}
+
+	config := container.Config{
+		Image:      image,
+		Env:        app.Config.Env,
+		WorkingDir: app.Config.WorkingDir,
+		User:       app.Config.User,
+		Entrypoint: []string{"sh", "-c"},
+		Cmd:        []string{command},
+		Labels:     labels,
+	}
+	hostConfig := container.HostConfig{
+		Binds:       app.HostConfig.Binds,
+		NetworkMode: app.HostConfig.NetworkMode,
+		Resources: container.Resources{
+			NanoCPUs: app.HostConfig.NanoCPUs,
+			Memory:   app.HostConfig.Memory,
+		},
+	}
+
+	created, err := cli.ContainerCreate(ctx, client.ContainerCreateOptions{
+		Config:     &config,
+		HostConfig: &hostConfig,
+	})
+	if err != nil {
+		return -1, fmt.Errorf("failed to create container: %w", err)
+	}
+	defer func() {
+		// ctx may already be cancelled here, removing with force also stops a command that timed out
+		removeCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		cli.ContainerRemove(removeCtx, created.ID, client.ContainerRemoveOptions{Force: true})
+	}()
+
+	// attaching before the start makes sure no output is missed
+	attach, err := cli.ContainerAttach(ctx, created.ID, client.ContainerAttachOptions{
+		Stream: true,
+		Stdout: true,
+		Stderr: true,
+	})
+	if err != nil {
+		return -1, fmt.Errorf("failed to attach to container: %w", err)
+	}
+	defer attach.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			attach.Close()
+		case <-done:
+		}
+	}()
+
+	if _, err := cli.ContainerStart(ctx, created.ID, client.ContainerStartOptions{}); err != nil {
+		return -1, fmt.Errorf("failed to start container: %w", err)
+	}
+
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	if _, err := stdcopy.StdCopy(stdout, stderr, attach.Reader); err != nil {
+		if ctx.Err() != nil {
+			return -1, ctx.Err()
+		}
+		return -1, fmt.Errorf("failed to read container output: %w", err)
+	}
+
+	wait := cli.ContainerWait(ctx, created.ID, client.ContainerWaitOptions{Condition: container.WaitConditionNotRunning})
+	select {
+	case result := <-wait.Result:
+		if result.Error != nil && result.Error.Message != "" {
+			return -1, fmt.Errorf("failed to wait for container: %s", result.Error.Message)
+		}
+		return int(result.StatusCode), nil
+	case err := <-wait.Error:
+		if ctx.Err() != nil {
+			return -1, ctx.Err()
+		}
+		return -1, fmt.Errorf("failed to wait for container: %w", err)
+	}
+}
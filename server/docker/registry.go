@@ -0,0 +1,115 @@
+package docker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/corecollectives/mist/models"
+	"github.com/distribution/reference"
+	"github.com/moby/moby/api/types/registry"
+	"github.com/moby/moby/client"
+	"github.com/rs/zerolog/log"
+)
+
+// registry host an image reference points to, docker hub images resolve to docker.io
+func ImageRegistryHost(imageName string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %q: %w", imageName, err)
+	}
+	return reference.Domain(named), nil
+}
+
+// base64 encoded credentials of the project registry serving the image, empty for anonymous pulls
+func RegistryAuthForImage(projectID int64, imageName string) (string, error) {
+	host, err := ImageRegistryHost(imageName)
+	if err != nil {
+		return "", err
+	}
+
+	reg, err := models.GetRegistryForHost(projectID, host)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up registry: %w", err)
+	}
+	// docker hub is commonly configured under one of its other hostnames
+	if reg == nil && host == "docker.io" {
+		for _, alias := range []string{"index.docker.io", "registry-1.docker.io"} {
+			if reg, err = models.GetRegistryForHost(projectID, alias); err != nil || reg != nil {
+				break
+			}
+		}
+	}
+	if reg == nil {
+		return "", nil
+	}
+	return encodeRegistryAuth(reg)
+}
+
+func encodeRegistryAuth(reg *models.Registry) (string, error) {
+	password, err := reg.DecryptedPassword()
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt registry password: %w", err)
+	}
+	data, err := json.Marshal(registry.AuthConfig{
+		Username:      reg.Username,
+		Password:      password,
+		ServerAddress: reg.Host(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(data), nil
+}
+
+// tags a locally built image for the registry and pushes it, returns the pushed reference
+func PushImage(ctx context.Context, reg *models.Registry, localTag, repoName, tag string, logfile *os.File) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Minute)
+	defer cancel()
+
+	cli, err := client.New(client.FromEnv)
+	if err != nil {
+		return "", fmt.Errorf("error opening moby client: %s", err.Error())
+	}
+
+	remoteRef := fmt.Sprintf("%s/%s:%s", reg.Repository(), repoName, tag)
+	if _, err := cli.ImageTag(ctx, client.ImageTagOptions{Source: localTag, Target: remoteRef}); err != nil {
+		return "", fmt.Errorf("failed to tag image: %w", err)
+	}
+
+	auth, err := encodeRegistryAuth(reg)
+	if err != nil {
+		return "", err
+	}
+
+	log.Info().Str("image", remoteRef).Msg("Pushing image to registry")
+	fmt.Fprintf(logfile, "[PUSH]: Pushing %s\n", remoteRef)
+
+	resp, err := cli.ImagePush(ctx, remoteRef, client.ImagePushOptions{RegistryAuth: auth})
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("image push timed out after 15 minutes")
+		}
+		return "", err
+	}
+	defer resp.Close()
+
+	// push errors like denied access only show up in the message stream
+	for msg, err := range resp.JSONMessages(ctx) {
+		if err != nil {
+			return "", err
+		}
+		if msg.Error != nil {
+			return "", fmt.Errorf("push failed: %s", msg.Error.Message)
+		}
+		if msg.Status != "" && msg.Progress == nil {
+			fmt.Fprintf(logfile, "[PUSH]: %s\n", strings.TrimSpace(strings.Join([]string{msg.ID, msg.Status}, " ")))
+		}
+	}
+
+	return remoteRef, nil
+}
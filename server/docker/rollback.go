@@ -0,0 +1,151 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/utils"
+	"gorm.io/gorm"
+)
+
+// rollback deployments carry the image tag of the deployment they roll back to,
+// so clone and build are skipped and the stored image is run again
+func RollbackMain(ctx context.Context, Id int64, db *gorm.DB, logFile *os.File, logger *utils.DeploymentLogger) (string, error) {
+	dep, err := LoadDeployment(Id, db)
+	if err != nil {
+		logger.Error(err, "Failed to load deployment")
+		return "", fmt.Errorf("failed to load deployment: %w", err)
+	}
+
+	if dep.RolledBackFrom == nil || dep.ImageTag == nil || *dep.ImageTag == "" {
+		return "", fmt.Errorf("deployment %d is not a rollback deployment", dep.ID)
+	}
+
+	app, err := models.GetApplicationByID(dep.AppID)
+	if err != nil {
+		logger.Error(err, "Failed to get app details")
+		return "", fmt.Errorf("failed to get app details: %w", err)
+	}
+
+	containerName := fmt.Sprintf("app-%d", app.ID)
+
+	err = RollbackApp(ctx, dep, app, *dep.ImageTag, containerName, db, logFile, logger)
+	if err != nil {
+		logger.Error(err, "RollbackApp failed")
+		return "", err
+	}
+
+	logger.Info("Rollback completed successfully")
+	return "Rollback completed", nil
+}
+
+func RollbackApp(ctx context.Context, dep *models.Deployment, app *models.App, imageTag, containerName string, db *gorm.DB, logfile *os.File, logger *utils.DeploymentLogger) error {
+	logger.InfoWithFields("Starting rollback", map[string]interface{}{
+		"target_deployment": *dep.RolledBackFrom,
+		"image":             imageTag,
+	})
+	fmt.Fprintf(logfile, "[ROLLBACK]: Rolling back to deployment %d using image %s\n", *dep.RolledBackFrom, imageTag)
+
+	fail := func(err error, errMsg string) error {
+		dep.Status = "failed"
+		dep.Stage = "failed"
+		dep.Progress = 0
+		dep.ErrorMessage = &errMsg
+		UpdateDeployment(dep, db)
+		models.UpdateDeploymentStatus(dep.ID, "failed", "failed", 0, &errMsg)
+		fmt.Fprintf(logfile, "[ROLLBACK]: %s\n", errMsg)
+		return err
+	}
+
+	// prebuilt images are stored by digest, so they can be pulled again if they were removed locally
+	if !ImageExists(imageTag) && app.IsImageSource() {
+		fmt.Fprintf(logfile, "[ROLLBACK]: Image %s not found locally, pulling it\n", imageTag)
+		if err := PullDockerImage(ctx, app.ProjectID, imageTag, logfile); err != nil {
+			logger.Error(err, "Failed to pull rollback image")
+		}
+	}
+
+	if !ImageExists(imageTag) {
+		errMsg := fmt.Sprintf("Image %s is no longer available", imageTag)
+		return fail(fmt.Errorf("image %s not found", imageTag), errMsg)
+	}
+
+	port, domains, envVars, err := GetDeploymentConfig(dep.ID, app, db)
+	if err != nil {
+		logger.Error(err, "Failed to get deployment configuration")
+		return fail(fmt.Errorf("get deployment config failed: %w", err), fmt.Sprintf("Failed to get deployment config: %v", err))
+	}
+
+	dep.Status = "deploying"
+	dep.Stage = "deploying"
+	dep.Progress = 80
+	UpdateDeployment(dep, db)
+	models.UpdateDeploymentStatus(dep.ID, "deploying", "deploying", 80, nil)
+
+	if CanSwapContainer(app, domains, containerName) {
+		if err := SwapContainer(ctx, app, imageTag, containerName, domains, port, envVars, logfile, logger); err != nil {
+			logger.Error(err, "Blue-green swap failed")
+			return fail(fmt.Errorf("swap container failed: %w", err), fmt.Sprintf("Failed to swap container: %v", err))
+		}
+	} else {
+		logger.Info("Stopping existing container if exists")
+		if err := StopRemoveContainer(containerName, logfile); err != nil {
+			logger.Error(err, "Failed to stop/remove existing container")
+			UpdateAppStatus(app.ID, "error", db)
+			return fail(fmt.Errorf("stop/remove container failed: %w", err), fmt.Sprintf("Failed to stop/remove container: %v", err))
+		}
+
+		if err := RunContainer(ctx, app, imageTag, containerName, domains, port, envVars, logfile); err != nil {
+			logger.Error(err, "Failed to run container")
+			UpdateAppStatus(app.ID, "error", db)
+			return fail(fmt.Errorf("run container failed: %w", err), fmt.Sprintf("Failed to run container: %v", err))
+		}
+
+		if err := WaitForHealthy(ctx, containerName, app, port, logfile); err != nil {
+			logger.Error(err, "Container did not become healthy")
+			writeContainerLogs(containerName, logfile)
+			UpdateAppStatus(app.ID, "error", db)
+			return fail(fmt.Errorf("container unhealthy: %w", err), fmt.Sprintf("Container is unhealthy: %v", err))
+		}
+	}
+
+	dep.Status = "success"
+	dep.Stage = "success"
+	dep.Progress = 100
+	now := time.Now()
+	dep.FinishedAt = &now
+	UpdateDeployment(dep, db)
+	models.UpdateDeploymentStatus(dep.ID, "success", "success", 100, nil)
+
+	containerID, err := GetContainerID(containerName)
+	if err != nil {
+		logger.Error(err, "Failed to get container ID (non-fatal)")
+	}
+	if err := models.UpdateContainerInfo(dep.ID, containerID, containerName, imageTag); err != nil {
+		logger.Error(err, "Failed to update deployment container info (non-fatal)")
+	}
+	if err := models.ActivateRollbackTarget(*dep.RolledBackFrom, app.ID); err != nil {
+		logger.Error(err, "Failed to mark rollback target as active (non-fatal)")
+	}
+
+	if err := UpdateAppStatus(app.ID, "running", db); err != nil {
+		logger.Error(err, "Failed to update app status (non-fatal)")
+	}
+
+	models.LogSystemAudit("rollback_completed", "deployment", &dep.ID, map[string]interface{}{
+		"app_id":           app.ID,
+		"rolled_back_from": *dep.RolledBackFrom,
+		"image_tag":        imageTag,
+	})
+
+	fmt.Fprintf(logfile, "[ROLLBACK]: Container %s is now running %s\n", containerName, imageTag)
+	logger.InfoWithFields("Rollback succeeded", map[string]interface{}{
+		"deployment_id": dep.ID,
+		"container":     containerName,
+	})
+
+	return nil
+}
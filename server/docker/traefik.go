@@ -0,0 +1,366 @@
+package docker
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// rewrites traefik's dynamic config with the current system settings and uploaded certificates.
+// traefik watches the file, so changes apply without a restart
+func SyncTraefikConfig() error {
+	settings, err := models.GetSystemSettings()
+	if err != nil {
+		return fmt.Errorf("failed to load system settings: %w", err)
+	}
+	dns, err := models.GetDNSProviderSettings()
+	if err != nil {
+		return fmt.Errorf("failed to load dns provider settings: %w", err)
+	}
+	certificates, err := models.GetCustomCertificates()
+	if err != nil {
+		return fmt.Errorf("failed to load certificates: %w", err)
+	}
+	return utils.GenerateDynamicConfig(settings.WildcardDomain, settings.MistAppName, dns.Configured(), certificates)
+}
+
+// labels of the app's enabled middlewares, returns their names in the order they run. a middleware
+// that can't be loaded fails the whole container, running it without e.g. its basic auth would expose the app
+func appMiddlewareLabels(labels map[string]string, serviceName string, appID int64) ([]string, error) {
+	middlewares, err := models.GetEnabledAppMiddlewares(appID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load app middlewares: %w", err)
+	}
+
+	var names []string
+	for i := range middlewares {
+		m := &middlewares[i]
+		name := fmt.Sprintf("%s-mw-%d", serviceName, m.ID)
+		prefix := "traefik.http.middlewares." + name + "."
+		set := func(key, value string) {
+			labels[prefix+key] = value
+		}
+
+		var err error
+		switch m.Type {
+		case models.MiddlewareBasicAuth:
+			var config models.BasicAuthConfig
+			if err = m.DecodeConfig(&config); err == nil {
+				users := make([]string, 0, len(config.Users))
+				for _, user := range config.Users {
+					users = append(users, user.Username+":"+user.PasswordHash)
+				}
+				set("basicauth.users", strings.Join(users, ","))
+				if config.Realm != "" {
+					set("basicauth.realm", config.Realm)
+				}
+				if config.RemoveHeader {
+					set("basicauth.removeheader", "true")
+				}
+			}
+		case models.MiddlewareIPAllowList:
+			var config models.IPAllowListConfig
+			if err = m.DecodeConfig(&config); err == nil {
+				set("ipallowlist.sourcerange", strings.Join(config.SourceRange, ","))
+				if config.Depth > 0 {
+					set("ipallowlist.ipstrategy.depth", fmt.Sprintf("%d", config.Depth))
+				}
+			}
+		case models.MiddlewareRateLimit:
+			var config models.RateLimitConfig
+			if err = m.DecodeConfig(&config); err == nil {
+				set("ratelimit.average", fmt.Sprintf("%d", config.Average))
+				if config.Burst > 0 {
+					set("ratelimit.burst", fmt.Sprintf("%d", config.Burst))
+				}
+				if config.Period != "" {
+					set("ratelimit.period", config.Period)
+				}
+			}
+		case models.MiddlewareHeaders:
+			var config models.HeadersConfig
+			if err = m.DecodeConfig(&config); err == nil {
+				for header, value := range config.RequestHeaders {
+					set("headers.customrequestheaders."+header, value)
+				}
+				for header, value := range config.ResponseHeaders {
+					set("headers.customresponseheaders."+header, value)
+				}
+				if len(config.CorsAllowOrigins) > 0 {
+					set("headers.accesscontrolalloworiginlist", strings.Join(config.CorsAllowOrigins, ","))
+					set("headers.addvaryheader", "true")
+				}
+				if len(config.CorsAllowMethods) > 0 {
+					set("headers.accesscontrolallowmethods", strings.Join(config.CorsAllowMethods, ","))
+				}
+				if len(config.CorsAllowHeaders) > 0 {
+					set("headers.accesscontrolallowheaders", strings.Join(config.CorsAllowHeaders, ","))
+				}
+				if config.CorsAllowCredentials {
+					set("headers.accesscontrolallowcredentials", "true")
+				}
+				if config.CorsMaxAge > 0 {
+					set("headers.accesscontrolmaxage", fmt.Sprintf("%d", config.CorsMaxAge))
+				}
+				if config.FrameDeny {
+					set("headers.framedeny", "true")
+				}
+				if config.ContentTypeNosniff {
+					set("headers.contenttypenosniff", "true")
+				}
+				if config.BrowserXssFilter {
+					set("headers.browserxssfilter", "true")
+				}
+				if config.ReferrerPolicy != "" {
+					set("headers.referrerpolicy", config.ReferrerPolicy)
+				}
+				if config.ContentSecurityPolicy != "" {
+					set("headers.contentsecuritypolicy", config.ContentSecurityPolicy)
+				}
+			}
+		case models.MiddlewareStripPrefix:
+			var config models.StripPrefixConfig
+			if err = m.DecodeConfig(&config); err == nil {
+				set("stripprefix.prefixes", strings.Join(config.Prefixes, ","))
+			}
+		case models.MiddlewareCompress:
+			var config models.CompressConfig
+			if err = m.DecodeConfig(&config); err == nil {
+				set("compress", "true")
+				if len(config.ExcludedContentTypes) > 0 {
+					set("compress.excludedcontenttypes", strings.Join(config.ExcludedContentTypes, ","))
+				}
+				if config.MinResponseBodyBytes > 0 {
+					set("compress.minresponsebodybytes", fmt.Sprintf("%d", config.MinResponseBodyBytes))
+				}
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid config of middleware %q: %w", m.Name, err)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// updates reset traefik-static.yml to the repository's copy, this puts the dns resolver back
+func RestoreDNSChallenge() error {
+	dns, err := models.GetDNSProviderSettings()
+	if err != nil {
+		return fmt.Errorf("failed to load dns provider settings: %w", err)
+	}
+	if !dns.Configured() {
+		return nil
+	}
+	configured, err := utils.HasCertResolver(utils.DNSCertResolver)
+	if err != nil || configured {
+		return err
+	}
+
+	log.Info().Str("provider", string(dns.Provider)).Msg("Restoring DNS challenge resolver")
+	if err := utils.ConfigureDNSChallenge(string(dns.Provider), dns.Env()); err != nil {
+		return err
+	}
+	return utils.RecreateTraefik()
+}
+
+// the wildcard domain whose subdomains share one certificate from the dns resolver, empty
+// without a dns provider
+func wildcardCertDomain() (*models.SystemSettings, string) {
+	settings, err := models.GetSystemSettings()
+	if err != nil {
+		return nil, ""
+	}
+	dns, err := models.GetDNSProviderSettings()
+	if err != nil || !dns.Configured() {
+		return settings, ""
+	}
+	return settings, settings.WildcardBase()
+}
+
+// routers of a path prefix take precedence over the routers of the whole host, longer prefixes
+// over shorter ones. without it traefik ranks by rule length, where a www redirect would win
+func routerPriority(pathPrefix string) int {
+	return len(pathPrefix) + 1
+}
+
+// the app's stored domains by name, in order. a host split by path has several, unknown names
+// get the defaults
+func domainEntries(records []models.Domain, domains []string) []models.Domain {
+	var entries []models.Domain
+	for i, name := range domains {
+		if slices.Contains(domains[:i], name) {
+			continue
+		}
+		found := false
+		for _, record := range records {
+			if record.Domain == name {
+				entries = append(entries, record)
+				found = true
+			}
+		}
+		if !found {
+			entries = append(entries, models.Domain{Domain: name, SslProvider: models.SSLProvider, ForceHttps: true})
+		}
+	}
+	return entries
+}
+
+// routers and middlewares for the app's domains. every domain gets its own routers, so its ssl
+// provider, https redirect, hsts and www redirect apply to it alone. a domain with a target port
+// gets its own service too
+func traefikLabels(app *models.App, domains []string, port int) (map[string]string, error) {
+	// routers are named after the app, not the container, so that a container started
+	// under a temporary name during a blue-green swap joins the same router and service
+	serviceName := GetContainerName(app.Name, app.ID)
+
+	labels := map[string]string{
+		"traefik.enable": "true",
+		fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port", serviceName): fmt.Sprintf("%d", port),
+	}
+
+	records, _ := models.GetDomainsByAppID(app.ID)
+	settings, wildcardBase := wildcardCertDomain()
+	appMiddlewares, err := appMiddlewareLabels(labels, serviceName, app.ID)
+	if err != nil {
+		return nil, err
+	}
+	httpsRedirect := false
+	for i, domain := range domainEntries(records, domains) {
+		routerName := fmt.Sprintf("%s-%d", serviceName, i)
+		if domain.ID != 0 {
+			routerName = fmt.Sprintf("%s-%d", serviceName, domain.ID)
+		}
+
+		routerService := serviceName
+		if domain.TargetPort != nil && *domain.TargetPort != port {
+			routerService = routerName
+			labels[fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port", routerService)] = fmt.Sprintf("%d", *domain.TargetPort)
+		}
+
+		hosts := []string{domain.Domain}
+		var wwwMiddleware string
+		if domain.RedirectWww {
+			hosts = append(hosts, domain.WwwAlias())
+			wwwMiddleware = routerName + "-www"
+			from, to := domain.WwwAlias(), domain.Domain
+			if domain.RedirectWwwToRoot == strings.HasPrefix(domain.Domain, "www.") {
+				from, to = to, from
+			}
+			labels[fmt.Sprintf("traefik.http.middlewares.%s.redirectregex.regex", wwwMiddleware)] = fmt.Sprintf("^(https?)://%s(.*)", regexp.QuoteMeta(from))
+			labels[fmt.Sprintf("traefik.http.middlewares.%s.redirectregex.replacement", wwwMiddleware)] = fmt.Sprintf("${1}://%s${2}", to)
+			labels[fmt.Sprintf("traefik.http.middlewares.%s.redirectregex.permanent", wwwMiddleware)] = "true"
+		}
+
+		var hostRules []string
+		for _, host := range hosts {
+			hostRules = append(hostRules, fmt.Sprintf("Host(`%s`)", host))
+		}
+		hostRule := strings.Join(hostRules, " || ")
+		if domain.PathPrefix != "" {
+			// the prefix itself and paths below it, not /apiv2 for /api
+			hostRule = fmt.Sprintf("(%s) && (Path(`%s`) || PathPrefix(`%s/`))", hostRule, domain.PathPrefix, domain.PathPrefix)
+		}
+		priority := fmt.Sprintf("%d", routerPriority(domain.PathPrefix))
+
+		// plain http router, redirects to https unless the domain has no certificate
+		var httpMiddlewares []string
+		if domain.SslProvider != models.SSLProviderNone && domain.ForceHttps {
+			httpMiddlewares = append(httpMiddlewares, serviceName+"-https-redirect")
+			httpsRedirect = true
+		} else {
+			if wwwMiddleware != "" {
+				httpMiddlewares = append(httpMiddlewares, wwwMiddleware)
+			}
+			httpMiddlewares = append(httpMiddlewares, appMiddlewares...)
+		}
+		labels[fmt.Sprintf("traefik.http.routers.%s-http.rule", routerName)] = hostRule
+		labels[fmt.Sprintf("traefik.http.routers.%s-http.entrypoints", routerName)] = "web"
+		labels[fmt.Sprintf("traefik.http.routers.%s-http.service", routerName)] = routerService
+		labels[fmt.Sprintf("traefik.http.routers.%s-http.priority", routerName)] = priority
+		if len(httpMiddlewares) > 0 {
+			labels[fmt.Sprintf("traefik.http.routers.%s-http.middlewares", routerName)] = strings.Join(httpMiddlewares, ",")
+		}
+
+		if domain.SslProvider == models.SSLProviderNone {
+			continue
+		}
+
+		var httpsMiddlewares []string
+		if wwwMiddleware != "" {
+			httpsMiddlewares = append(httpsMiddlewares, wwwMiddleware)
+		}
+		if domain.HstsEnabled {
+			hstsMiddleware := routerName + "-hsts"
+			labels[fmt.Sprintf("traefik.http.middlewares.%s.headers.stsSeconds", hstsMiddleware)] = fmt.Sprintf("%d", domain.HstsMaxAge)
+			httpsMiddlewares = append(httpsMiddlewares, hstsMiddleware)
+		}
+		httpsMiddlewares = append(httpsMiddlewares, appMiddlewares...)
+		labels[fmt.Sprintf("traefik.http.routers.%s.rule", routerName)] = hostRule
+		labels[fmt.Sprintf("traefik.http.routers.%s.entrypoints", routerName)] = "websecure"
+		labels[fmt.Sprintf("traefik.http.routers.%s.service", routerName)] = routerService
+		labels[fmt.Sprintf("traefik.http.routers.%s.priority", routerName)] = priority
+		labels[fmt.Sprintf("traefik.http.routers.%s.tls", routerName)] = "true"
+		// uploaded certificates are picked from traefik's file provider by host name
+		switch {
+		case domain.SslProvider == models.SSLProviderCustom:
+		case wildcardBase != "" && !domain.RedirectWww && settings.IsWildcardSubdomain(domain.Domain):
+			// every router asks for the same wildcard certificate, traefik only requests it once
+			labels[fmt.Sprintf("traefik.http.routers.%s.tls.certresolver", routerName)] = utils.DNSCertResolver
+			labels[fmt.Sprintf("traefik.http.routers.%s.tls.domains[0].main", routerName)] = "*." + wildcardBase
+		default:
+			labels[fmt.Sprintf("traefik.http.routers.%s.tls.certresolver", routerName)] = utils.DefaultCertResolver
+		}
+		if len(httpsMiddlewares) > 0 {
+			labels[fmt.Sprintf("traefik.http.routers.%s.middlewares", routerName)] = strings.Join(httpsMiddlewares, ",")
+		}
+	}
+
+	if httpsRedirect {
+		labels[fmt.Sprintf("traefik.http.middlewares.%s-https-redirect.redirectscheme.scheme", serviceName)] = "https"
+	}
+	return labels, nil
+}
+
+// recreates the containers of apps on a subdomain of the wildcard domain, so their routers
+// pick up a changed cert resolver
+func RecreateWildcardApps() error {
+	settings, err := models.GetSystemSettings()
+	if err != nil {
+		return fmt.Errorf("failed to load system settings: %w", err)
+	}
+	base := settings.WildcardBase()
+	if base == "" {
+		return nil
+	}
+
+	domains, err := models.GetDomainsWithSuffix("." + base)
+	if err != nil {
+		return fmt.Errorf("failed to get domains: %w", err)
+	}
+
+	recreated := map[int64]bool{}
+	for _, domain := range domains {
+		if recreated[domain.AppID] || !settings.IsWildcardSubdomain(domain.Domain) {
+			continue
+		}
+		recreated[domain.AppID] = true
+
+		app, err := models.GetApplicationByID(domain.AppID)
+		if err != nil {
+			continue
+		}
+		// apps that aren't running get the new labels on their next start
+		if err := RecreateContainer(app); err != nil {
+			log.Warn().Err(err).Int64("app_id", app.ID).Msg("Failed to recreate app container")
+		}
+	}
+	return nil
+}
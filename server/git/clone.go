@@ -1,28 +1,102 @@
 package git
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
 
 	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/config"
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/rs/zerolog/log"
 )
 
-func CloneRepo(url string, branch string, logFile *os.File, path string) error {
+var fullCommitHash = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// ErrCommitNotFound is returned when the commit to deploy is not reachable from the branch anymore,
+// e.g. after a force push
+var ErrCommitNotFound = errors.New("commit no longer exists in the repository")
+
+// clones the repo into path and checks out the given commit, an empty commit checks out the branch tip.
+// full hashes are fetched on their own with depth 1 when the server allows it, otherwise the branch
+// history is fetched and searched for the commit. returns the checked out commit hash
+func CloneRepo(ctx context.Context, url string, branch string, commit string, logFile *os.File, path string) (string, error) {
 	_, err := fmt.Fprintf(logFile, "[GIT]: Cloning into %s\n", path)
 	if err != nil {
 		log.Warn().Msg("error logging into log file")
 	}
-	_, err = git.PlainClone(path, &git.CloneOptions{
-		URL: url,
-		// Progress:      logFile,
-		ReferenceName: plumbing.NewBranchReferenceName(branch),
-		SingleBranch:  true,
-	})
+
+	if commit == "" {
+		repo, err := git.PlainCloneContext(ctx, path, &git.CloneOptions{
+			URL: url,
+			// Progress:      logFile,
+			ReferenceName: plumbing.NewBranchReferenceName(branch),
+			SingleBranch:  true,
+		})
+		if err != nil {
+			return "", err
+		}
+		head, err := repo.Head()
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve HEAD: %w", err)
+		}
+		fmt.Fprintf(logFile, "[GIT]: Checked out %s at %s\n", branch, head.Hash())
+		return head.Hash().String(), nil
+	}
+
+	repo, err := git.PlainInit(path, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to init repository: %w", err)
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{url}}); err != nil {
+		return "", fmt.Errorf("failed to add remote: %w", err)
+	}
+
+	fetched := false
+	if fullCommitHash.MatchString(commit) {
+		err = repo.FetchContext(ctx, &git.FetchOptions{
+			RefSpecs: []config.RefSpec{config.RefSpec(commit + ":refs/mist/deploy")},
+			Depth:    1,
+			Tags:     git.NoTags,
+		})
+		if err == nil || errors.Is(err, git.NoErrAlreadyUpToDate) {
+			fetched = true
+		} else if ctx.Err() != nil {
+			return "", err
+		} else {
+			fmt.Fprintf(logFile, "[GIT]: Shallow fetch of %s not possible (%v), fetching branch %s\n", commit, err, branch)
+		}
+	}
+
+	if !fetched {
+		branchRef := plumbing.NewBranchReferenceName(branch)
+		err = repo.FetchContext(ctx, &git.FetchOptions{
+			RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:refs/remotes/%s/%s", branchRef, git.DefaultRemoteName, branch))},
+			Tags:     git.NoTags,
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return "", err
+		}
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(commit))
 	if err != nil {
-		return err
+		return "", fmt.Errorf("%w: %s", ErrCommitNotFound, commit)
+	}
+	if _, err := repo.CommitObject(*hash); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrCommitNotFound, commit)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("failed to open worktree: %w", err)
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: *hash, Force: true}); err != nil {
+		return "", fmt.Errorf("failed to check out %s: %w", hash, err)
 	}
 
-	return nil
+	fmt.Fprintf(logFile, "[GIT]: Checked out commit %s\n", hash)
+	return hash.String(), nil
 }
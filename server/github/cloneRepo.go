@@ -2,6 +2,7 @@ package github
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -11,22 +12,23 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func CloneRepo(appId int64, logFile *os.File) error {
+// clones the app's repo and checks out commit, returns the resolved commit hash
+func CloneRepo(ctx context.Context, appId int64, commit string, logFile *os.File) (string, error) {
 	log.Info().Int64("app_id", appId).Msg("Starting repository clone")
 
 	userId, err := models.GetUserIDByAppID(appId)
 	if err != nil {
-		return fmt.Errorf("failed to get user id by app id: %w", err)
+		return "", fmt.Errorf("failed to get user id by app id: %w", err)
 	}
 
 	cloneURL, accessToken, shouldMigrate, err := models.GetAppCloneURL(appId, *userId)
 	if err != nil {
-		return fmt.Errorf("failed to get clone URL: %w", err)
+		return "", fmt.Errorf("failed to get clone URL: %w", err)
 	}
 
 	_, _, branch, _, projectId, name, err := models.GetAppGitInfo(appId)
 	if err != nil {
-		return fmt.Errorf("failed to fetch app: %w", err)
+		return "", fmt.Errorf("failed to fetch app: %w", err)
 	}
 
 	if shouldMigrate {
@@ -60,18 +62,18 @@ func CloneRepo(appId int64, logFile *os.File) error {
 		log.Info().Str("path", path).Msg("Repository already exists, removing directory")
 
 		if err := os.RemoveAll(path); err != nil {
-			return fmt.Errorf("failed to remove existing repository: %w", err)
+			return "", fmt.Errorf("failed to remove existing repository: %w", err)
 
 		}
 	}
 
 	if err := os.MkdirAll(path, 0o755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+		return "", fmt.Errorf("failed to create directory: %w", err)
 	}
 
 	log.Info().Str("clone_url", cloneURL).Str("branch", branch).Str("path", path).Msg("Cloning repository")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
 	defer cancel()
 
 	// old command implementation
@@ -87,14 +89,17 @@ func CloneRepo(appId int64, logFile *os.File) error {
 	// }
 
 	// new git sdk implementation
-	err = git.CloneRepo(repoURL, branch, logFile, path)
+	resolved, err := git.CloneRepo(ctx, repoURL, branch, commit, logFile, path)
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
-			return fmt.Errorf("git clone timed out after 10 minutes")
+			return "", fmt.Errorf("git clone timed out after 10 minutes")
 		}
-		return fmt.Errorf("error cloning repository: %v\n", err)
+		if errors.Is(err, git.ErrCommitNotFound) {
+			return "", err
+		}
+		return "", fmt.Errorf("error cloning repository: %v\n", err)
 	}
 
-	log.Info().Int64("app_id", appId).Str("path", path).Msg("Repository cloned successfully")
-	return nil
+	log.Info().Int64("app_id", appId).Str("path", path).Str("commit", resolved).Msg("Repository cloned successfully")
+	return resolved, nil
 }
@@ -1,9 +1,11 @@
 package lib
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/notify"
 	"github.com/corecollectives/mist/queue"
 	"github.com/rs/zerolog/log"
 )
@@ -17,6 +19,15 @@ func CleanupOnStartup() error {
 	if err != nil {
 		return err
 	}
+	if err := models.DeleteExpiredSessions(); err != nil {
+		log.Warn().Err(err).Msg("Failed to delete expired sessions")
+	}
+	if err := models.FailInterruptedBackups(); err != nil {
+		log.Warn().Err(err).Msg("Failed to mark interrupted backups as failed")
+	}
+	if err := models.FailInterruptedCronRuns(); err != nil {
+		log.Warn().Err(err).Msg("Failed to mark interrupted cron runs as failed")
+	}
 	return nil
 }
 
@@ -25,42 +36,37 @@ func cleanupDeployments() error {
 	if err != nil {
 		return err
 	}
-	if len(deployments) == 0 {
-		return nil
-	}
 
-	log.Info().
-		Int("count", len(deployments)).
-		Msg("Found incomplete deployments on startup, cleaning up")
+	if len(deployments) > 0 {
+		log.Info().
+			Int("count", len(deployments)).
+			Msg("Found incomplete deployments on startup, cleaning up")
+	}
 
 	errorMsg := "system died before deployment could complete"
 	for _, dep := range deployments {
-		if dep.Status == "deploying" || dep.Status == "building" {
-			log.Warn().
-				Int64("deployment_id", dep.ID).
-				Str("status", string(dep.Status)).
-				Msg("Marking interrupted deployment as failed")
-
-			err = models.UpdateDeploymentStatus(dep.ID, "failed", "failed", 0, &errorMsg)
-			if err != nil {
-				log.Error().Err(err).Int64("deployment_id", dep.ID).Msg("Failed to mark deployment as failed")
-				return err
-			}
-		} else if dep.Status == "pending" {
-			log.Info().
-				Int64("deployment_id", dep.ID).
-				Msg("Re-queuing pending deployment")
-
-			err = queue.GetQueue().AddJob(dep.ID)
-			if err != nil {
-				log.Error().Err(err).Int64("deployment_id", dep.ID).Msg("Failed to re-queue pending deployment")
-				return err
-			}
-		} else {
-			continue
+		log.Warn().
+			Int64("deployment_id", dep.ID).
+			Str("status", string(dep.Status)).
+			Msg("Marking interrupted deployment as failed")
+
+		err = models.UpdateDeploymentStatus(dep.ID, "failed", "failed", 0, &errorMsg)
+		if err != nil {
+			log.Error().Err(err).Int64("deployment_id", dep.ID).Msg("Failed to mark deployment as failed")
+			return err
 		}
 	}
 
+	// pending deployments never started, so they are picked up again in their queued order
+	requeued, err := queue.GetQueue().LoadPending()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to re-queue pending deployments")
+		return err
+	}
+	if requeued > 0 {
+		log.Info().Int("count", requeued).Msg("Re-queued pending deployments")
+	}
+
 	log.Info().Msg("Deployment cleanup completed successfully")
 	return nil
 }
@@ -116,6 +122,9 @@ func cleanupUpdates() error {
 			Str("from", latestLog.VersionFrom).
 			Str("to", latestLog.VersionTo).
 			Msg("Successfully completed pending update")
+		notify.Send(notify.SystemEvent(models.NotificationSystemUpdate, models.PriorityNormal,
+			"Mist updated",
+			fmt.Sprintf("Mist was updated from %s to %s.", latestLog.VersionFrom, latestLog.VersionTo), "/updates"))
 		return nil
 	}
 
@@ -137,6 +146,9 @@ func cleanupUpdates() error {
 	log.Info().
 		Int64("update_log_id", latestLog.ID).
 		Msg("Marked failed update as failed")
+	notify.Send(notify.SystemEvent(models.NotificationSystemUpdate, models.PriorityHigh,
+		"Mist update failed",
+		fmt.Sprintf("Updating Mist from %s to %s failed: %s", latestLog.VersionFrom, latestLog.VersionTo, errMsg), "/updates"))
 
 	return nil
 }
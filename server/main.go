@@ -1,11 +1,21 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/corecollectives/mist/api"
+	"github.com/corecollectives/mist/backup"
 	"github.com/corecollectives/mist/db"
+	"github.com/corecollectives/mist/docker"
 	"github.com/corecollectives/mist/lib"
 	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/notify"
 	"github.com/corecollectives/mist/queue"
+	"github.com/corecollectives/mist/scheduler"
+	"github.com/corecollectives/mist/ssl"
 	"github.com/corecollectives/mist/store"
 	"github.com/corecollectives/mist/utils"
 	"github.com/rs/zerolog/log"
@@ -15,7 +25,6 @@ func main() {
 	utils.InitLogger()
 	log.Info().Msg("Starting Mist server")
 	dbInstance, err := db.InitDB()
-	_ = queue.InitQueue(dbInstance)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Error initializing database")
 		return
@@ -29,6 +38,9 @@ func main() {
 	log.Info().Msg("Database initialized successfully")
 	models.SetDB(dbInstance)
 
+	// worker count is read from system settings, so the queue has to start after the db is set
+	deployQueue := queue.InitQueue(dbInstance)
+
 	// when we update the app, systemctl restarts the app, and we are unable to update the status of that
 	// particular update in the db, and it gets stuck in 'in_progress' which leads disability in doing
 	// updates, so on each startup we need to check if the last update was successfull or not and change
@@ -38,21 +50,32 @@ func main() {
 		log.Warn().Err(err).Msg("Failed to check pending updates and deployments")
 	}
 
+	backup.StartRetentionWorker()
+	scheduler.Start()
+	notify.StartWorker()
+	notify.WatchContainers()
+	ssl.StartMonitor()
+
 	err = store.InitStore()
 	if err != nil {
 		log.Fatal().Err(err).Msg("Error initializing store")
 		return
 	}
 	log.Info().Msg("Store initialized successfully")
-	settings, err := models.GetSystemSettings()
-	if err != nil {
-		log.Warn().Err(err).Msg("Failed to load system settings for Traefik initialization")
+	if err := docker.SyncTraefikConfig(); err != nil {
+		log.Warn().Err(err).Msg("Failed to initialize Traefik configuration")
 	} else {
-		if err := utils.InitializeTraefikConfig(settings.WildcardDomain, settings.MistAppName); err != nil {
-			log.Warn().Err(err).Msg("Failed to initialize Traefik configuration")
-		} else {
-			log.Info().Msg("Traefik configuration initialized successfully")
-		}
+		log.Info().Msg("Traefik configuration initialized successfully")
+	}
+	if err := docker.RestoreDNSChallenge(); err != nil {
+		log.Warn().Err(err).Msg("Failed to restore DNS challenge resolver")
 	}
-	api.InitApiServer()
+
+	// systemd stops mist with SIGTERM, running deployments are put back in the queue
+	// instead of being failed by the startup cleanup
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	api.InitApiServer(ctx)
+	log.Info().Msg("Shutting down Mist server")
+	deployQueue.Close()
 }
@@ -1,6 +1,11 @@
 package models
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/corecollectives/mist/utils"
@@ -13,7 +18,8 @@ type ApiToken struct {
 	Name        string     `gorm:"type:varchar(255);not null" json:"name"`
 	TokenHash   string     `gorm:"uniqueIndex;type:varchar(255);not null" json:"-"`
 	TokenPrefix string     `gorm:"index;type:varchar(50);not null" json:"tokenPrefix"`
-	Scopes      *string    `json:"scopes,omitempty"`
+	Scopes      *string    `json:"scopes,omitempty"`                 // comma separated, see ApiTokenScopes
+	ProjectID   *int64     `gorm:"index" json:"projectId,omitempty"` // limits the token to a single project when set
 	LastUsedAt  *time.Time `json:"lastUsedAt,omitempty"`
 	LastUsedIP  *string    `json:"lastUsedIp,omitempty"`
 	UsageCount  int        `gorm:"default:0" json:"usageCount"`
@@ -28,7 +34,8 @@ func (t *ApiToken) ToJson() map[string]interface{} {
 		"userId":      t.UserID,
 		"name":        t.Name,
 		"tokenPrefix": t.TokenPrefix,
-		"scopes":      t.Scopes,
+		"scopes":      t.ScopeList(),
+		"projectId":   t.ProjectID,
 		"lastUsedAt":  t.LastUsedAt,
 		"lastUsedIp":  t.LastUsedIP,
 		"usageCount":  t.UsageCount,
@@ -38,6 +45,58 @@ func (t *ApiToken) ToJson() map[string]interface{} {
 	}
 }
 
+const ApiTokenPrefix = "mist_"
+
+// scopes a token can be granted, the read scope of a resource is implied by its write scope
+var ApiTokenScopes = []string{
+	"projects:read",
+	"apps:read",
+	"apps:write",
+	"deploy:read",
+	"deploy:write",
+}
+
+func IsValidApiTokenScope(scope string) bool {
+	return slices.Contains(ApiTokenScopes, scope)
+}
+
+// returns the plaintext token, which is shown to the user once, and its hash for storage
+func GenerateApiToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := ApiTokenPrefix + hex.EncodeToString(b)
+	return token, HashApiToken(token), nil
+}
+
+func HashApiToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t *ApiToken) ScopeList() []string {
+	if t.Scopes == nil || *t.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(*t.Scopes, ",")
+}
+
+func (t *ApiToken) HasScope(scope string) bool {
+	scopes := t.ScopeList()
+	if slices.Contains(scopes, scope) {
+		return true
+	}
+	if resource, ok := strings.CutSuffix(scope, ":read"); ok {
+		return slices.Contains(scopes, resource+":write")
+	}
+	return false
+}
+
+func (t *ApiToken) IsExpired() bool {
+	return t.ExpiresAt != nil && t.ExpiresAt.Before(time.Now())
+}
+
 func (t *ApiToken) InsertInDB() error {
 	t.ID = utils.GenerateRandomId()
 	result := db.Create(t)
@@ -67,6 +126,15 @@ func (t *ApiToken) UpdateUsage(lastUsedIP string) error {
 	}).Error
 }
 
+func GetApiTokenByID(id int64) (*ApiToken, error) {
+	var token ApiToken
+	result := db.First(&token, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &token, nil
+}
+
 func (t *ApiToken) Revoke() error {
 	return db.Model(t).Update("revoked_at", time.Now()).Error
 }
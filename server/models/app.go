@@ -11,6 +11,7 @@ type DeploymentStrategy string
 type AppStatus string
 type AppType string
 type RestartPolicy string
+type SourceType string
 
 const (
 	DeploymentAuto   DeploymentStrategy = "auto"
@@ -30,6 +31,9 @@ const (
 	RestartPolicyAlways        RestartPolicy = "always"
 	RestartPolicyOnFailure     RestartPolicy = "on-failure"
 	RestartPolicyUnlessStopped RestartPolicy = "unless-stopped"
+
+	SourceTypeGit   SourceType = "git"
+	SourceTypeImage SourceType = "image"
 )
 
 type App struct {
@@ -49,7 +53,11 @@ type App struct {
 	RootDirectory       string             `gorm:"default:'.'" json:"root_directory,omitempty"`
 	BuildCommand        *string            `json:"build_command,omitempty"`
 	StartCommand        *string            `json:"start_command,omitempty"`
+	OutputDirectory     *string            `json:"output_directory,omitempty"`
 	DockerfilePath      *string            `gorm:"default:'DOCKERFILE'" json:"dockerfile_path,omitempty"`
+	SourceType          SourceType         `gorm:"default:'git'" json:"source_type"`
+	Image               *string            `json:"image,omitempty"`
+	PushRegistryID      *int64             `json:"push_registry_id,omitempty"`
 	CPULimit            *float64           `json:"cpu_limit,omitempty"`
 	MemoryLimit         *int               `json:"memory_limit,omitempty"`
 	RestartPolicy       RestartPolicy      `gorm:"default:'unless-stopped'" json:"restart_policy"`
@@ -80,7 +88,11 @@ func (a *App) ToJson() map[string]interface{} {
 		"rootDirectory":       a.RootDirectory,
 		"buildCommand":        a.BuildCommand,
 		"startCommand":        a.StartCommand,
+		"outputDirectory":     a.OutputDirectory,
 		"dockerfilePath":      a.DockerfilePath,
+		"sourceType":          a.SourceType,
+		"image":               a.Image,
+		"pushRegistryId":      a.PushRegistryID,
 		"cpuLimit":            a.CPULimit,
 		"memoryLimit":         a.MemoryLimit,
 		"restartPolicy":       a.RestartPolicy,
@@ -94,6 +106,11 @@ func (a *App) ToJson() map[string]interface{} {
 	}
 }
 
+// image source apps run a prebuilt image and skip clone and build
+func (a *App) IsImageSource() bool {
+	return a.SourceType == SourceTypeImage
+}
+
 func (a *App) InsertInDB() error {
 	a.ID = utils.GenerateRandomId()
 	if a.AppType == "" {
@@ -131,7 +148,8 @@ func (a *App) UpdateApplication() error {
 	return db.Model(a).Select("Name", "Description", "AppType", "TemplateName",
 		"GitProviderID", "GitRepository", "GitBranch", "GitCloneURL",
 		"DeploymentStrategy", "Port", "RootDirectory",
-		"BuildCommand", "StartCommand", "DockerfilePath",
+		"BuildCommand", "StartCommand", "OutputDirectory", "DockerfilePath",
+		"SourceType", "Image", "PushRegistryID",
 		"CPULimit", "MemoryLimit", "RestartPolicy",
 		"HealthcheckPath", "HealthcheckInterval", "HealthcheckTimeout", "HealthcheckRetries",
 		"Status", "UpdatedAt").Updates(a).Error
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AppMiddlewareType string
+
+const (
+	MiddlewareBasicAuth   AppMiddlewareType = "basic_auth"
+	MiddlewareIPAllowList AppMiddlewareType = "ip_allowlist"
+	MiddlewareRateLimit   AppMiddlewareType = "rate_limit"
+	MiddlewareHeaders     AppMiddlewareType = "headers"
+	MiddlewareStripPrefix AppMiddlewareType = "strip_prefix"
+	MiddlewareCompress    AppMiddlewareType = "compress"
+)
+
+func IsAppMiddlewareType(t AppMiddlewareType) bool {
+	switch t {
+	case MiddlewareBasicAuth, MiddlewareIPAllowList, MiddlewareRateLimit,
+		MiddlewareHeaders, MiddlewareStripPrefix, MiddlewareCompress:
+		return true
+	}
+	return false
+}
+
+type BasicAuthUser struct {
+	Username string `json:"username"`
+	// bcrypt
+	PasswordHash string `json:"passwordHash"`
+}
+
+type BasicAuthConfig struct {
+	Users []BasicAuthUser `json:"users"`
+	Realm string          `json:"realm,omitempty"`
+	// keeps the authorization header from reaching the app
+	RemoveHeader bool `json:"removeHeader,omitempty"`
+}
+
+type IPAllowListConfig struct {
+	// ips and cidr ranges
+	SourceRange []string `json:"sourceRange"`
+	// picks the client ip from X-Forwarded-For when there's another proxy in front of traefik,
+	// 0 uses the address of the connection
+	Depth int `json:"depth,omitempty"`
+}
+
+type RateLimitConfig struct {
+	// requests per period, per client ip
+	Average int `json:"average"`
+	Burst   int `json:"burst,omitempty"`
+	// go duration, defaults to 1s
+	Period string `json:"period,omitempty"`
+}
+
+type HeadersConfig struct {
+	// an empty value removes the header
+	RequestHeaders  map[string]string `json:"requestHeaders,omitempty"`
+	ResponseHeaders map[string]string `json:"responseHeaders,omitempty"`
+
+	CorsAllowOrigins     []string `json:"corsAllowOrigins,omitempty"`
+	CorsAllowMethods     []string `json:"corsAllowMethods,omitempty"`
+	CorsAllowHeaders     []string `json:"corsAllowHeaders,omitempty"`
+	CorsAllowCredentials bool     `json:"corsAllowCredentials,omitempty"`
+	// seconds
+	CorsMaxAge int `json:"corsMaxAge,omitempty"`
+
+	FrameDeny             bool   `json:"frameDeny,omitempty"`
+	ContentTypeNosniff    bool   `json:"contentTypeNosniff,omitempty"`
+	BrowserXssFilter      bool   `json:"browserXssFilter,omitempty"`
+	ReferrerPolicy        string `json:"referrerPolicy,omitempty"`
+	ContentSecurityPolicy string `json:"contentSecurityPolicy,omitempty"`
+}
+
+type StripPrefixConfig struct {
+	Prefixes []string `json:"prefixes"`
+}
+
+type CompressConfig struct {
+	ExcludedContentTypes []string `json:"excludedContentTypes,omitempty"`
+	MinResponseBodyBytes int      `json:"minResponseBodyBytes,omitempty"`
+}
+
+// traefik middleware chained on the routers of an app's domains, in priority order
+type AppMiddleware struct {
+	ID    int64             `gorm:"primaryKey;autoIncrement:true" json:"id"`
+	AppID int64             `gorm:"index;constraint:OnDelete:CASCADE;not null" json:"appId"`
+	Name  string            `gorm:"not null" json:"name"`
+	Type  AppMiddlewareType `gorm:"not null" json:"type"`
+	// json of the type's config struct
+	Config string `gorm:"type:text;not null" json:"-"`
+	// lower runs first
+	Priority int  `gorm:"default:0" json:"priority"`
+	Enabled  bool `gorm:"default:true" json:"enabled"`
+
+	CreatedBy *int64    `gorm:"constraint:OnDelete:SET NULL" json:"createdBy,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+func (m *AppMiddleware) ToJson() map[string]interface{} {
+	var config interface{}
+	_ = json.Unmarshal([]byte(m.Config), &config)
+
+	// password hashes stay on the server
+	if m.Type == MiddlewareBasicAuth {
+		var basicAuth BasicAuthConfig
+		if m.DecodeConfig(&basicAuth) == nil {
+			users := make([]map[string]string, 0, len(basicAuth.Users))
+			for _, user := range basicAuth.Users {
+				users = append(users, map[string]string{"username": user.Username})
+			}
+			config = map[string]interface{}{
+				"users":        users,
+				"realm":        basicAuth.Realm,
+				"removeHeader": basicAuth.RemoveHeader,
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"id":        m.ID,
+		"appId":     m.AppID,
+		"name":      m.Name,
+		"type":      m.Type,
+		"config":    config,
+		"priority":  m.Priority,
+		"enabled":   m.Enabled,
+		"createdBy": m.CreatedBy,
+		"createdAt": m.CreatedAt,
+		"updatedAt": m.UpdatedAt,
+	}
+}
+
+func (m *AppMiddleware) DecodeConfig(v interface{}) error {
+	return json.Unmarshal([]byte(m.Config), v)
+}
+
+func (m *AppMiddleware) SetConfig(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	m.Config = string(data)
+	return nil
+}
+
+func (m *AppMiddleware) InsertInDB() error {
+	enabled := m.Enabled
+	if err := db.Create(m).Error; err != nil {
+		return err
+	}
+	// gorm leaves out false on create because of the default tag
+	if !enabled {
+		m.Enabled = false
+		return db.Model(m).Update("enabled", false).Error
+	}
+	return nil
+}
+
+func (m *AppMiddleware) UpdateMiddleware() error {
+	return db.Model(m).Select("name", "config", "priority", "enabled").Updates(m).Error
+}
+
+func GetAppMiddlewares(appID int64) ([]AppMiddleware, error) {
+	var middlewares []AppMiddleware
+	result := db.Where("app_id = ?", appID).Order("priority ASC, id ASC").Find(&middlewares)
+	return middlewares, result.Error
+}
+
+func GetEnabledAppMiddlewares(appID int64) ([]AppMiddleware, error) {
+	var middlewares []AppMiddleware
+	result := db.Where("app_id = ? AND enabled = ?", appID, true).Order("priority ASC, id ASC").Find(&middlewares)
+	return middlewares, result.Error
+}
+
+func GetAppMiddlewareByID(id int64) (*AppMiddleware, error) {
+	var m AppMiddleware
+	result := db.First(&m, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &m, nil
+}
+
+func GetAppIDByMiddlewareID(id int64) (int64, error) {
+	var m AppMiddleware
+	err := db.Select("app_id").First(&m, id).Error
+	return m.AppID, err
+}
+
+func DeleteAppMiddleware(id int64) error {
+	return db.Delete(&AppMiddleware{}, id).Error
+}
+
+func DeleteAppMiddlewares(appID int64) error {
+	return db.Where("app_id = ?", appID).Delete(&AppMiddleware{}).Error
+}
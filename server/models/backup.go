@@ -64,6 +64,10 @@ type Backup struct {
 
 	RestoreCount int `gorm:"default:0" json:"restoreCount"`
 
+	// state of the last restore, restores run in the background like backups
+	RestoreStatus *BackupStatus `json:"restoreStatus,omitempty"`
+	RestoreError  *string       `json:"restoreError,omitempty"`
+
 	RetentionDays *int `json:"retentionDays,omitempty"`
 
 	AutoDeleteAt *time.Time `gorm:"index" json:"autoDeleteAt,omitempty"`
@@ -99,6 +103,8 @@ func (b *Backup) ToJson() map[string]interface{} {
 		"canRestore":        b.CanRestore,
 		"lastRestoreAt":     b.LastRestoreAt,
 		"restoreCount":      b.RestoreCount,
+		"restoreStatus":     b.RestoreStatus,
+		"restoreError":      b.RestoreError,
 		"retentionDays":     b.RetentionDays,
 		"autoDeleteAt":      b.AutoDeleteAt,
 		"createdBy":         b.CreatedBy,
@@ -144,15 +150,99 @@ func (b *Backup) UpdateProgress(progress int) error {
 	return db.Model(b).Update("progress", progress).Error
 }
 
+func (b *Backup) MarkAsStarted() error {
+	b.Status = BackupStatusInProgress
+	return db.Model(b).Updates(map[string]interface{}{
+		"status":   BackupStatusInProgress,
+		"progress": 0,
+	}).Error
+}
+
+func (b *Backup) MarkAsCompleted(fileSize int64, checksum string, duration int) error {
+	now := time.Now()
+	b.Status = BackupStatusCompleted
+	b.Progress = 100
+	b.FileSize = &fileSize
+	b.Checksum = &checksum
+	b.CompletedAt = &now
+	b.Duration = &duration
+	return db.Model(b).Updates(map[string]interface{}{
+		"status":       BackupStatusCompleted,
+		"progress":     100,
+		"file_size":    fileSize,
+		"checksum":     checksum,
+		"completed_at": now,
+		"duration":     duration,
+	}).Error
+}
+
+func (b *Backup) MarkAsVerified() error {
+	now := time.Now()
+	b.IsVerified = true
+	b.VerifiedAt = &now
+	return db.Model(b).Updates(map[string]interface{}{
+		"is_verified": true,
+		"verified_at": now,
+	}).Error
+}
+
+func (b *Backup) MarkAsDeleted() error {
+	b.Status = BackupStatusDeleted
+	return db.Model(b).Update("status", BackupStatusDeleted).Error
+}
+
+func (b *Backup) UpdateRestoreStatus(status BackupStatus, errorMsg *string) error {
+	b.RestoreStatus = &status
+	b.RestoreError = errorMsg
+	return db.Model(b).Updates(map[string]interface{}{
+		"restore_status": status,
+		"restore_error":  errorMsg,
+	}).Error
+}
+
 func (b *Backup) MarkAsRestored() error {
 	return db.Model(b).Updates(map[string]interface{}{
 		"last_restore_at": time.Now(),
 		"restore_count":   gorm.Expr("restore_count + ?", 1),
+		"restore_status":  BackupStatusCompleted,
+		"restore_error":   nil,
 	}).Error
 }
 
-func DeleteExpiredBackups() error {
-	return db.Where("auto_delete_at IS NOT NULL AND auto_delete_at < ? AND status != ?", time.Now(), BackupStatusDeleted).Update("status", BackupStatusDeleted).Error
+// size of the last completed backup of the app, used to estimate the progress of the next one
+func GetLastBackupSize(appId int64) int64 {
+	var backup Backup
+	err := db.Where("app_id=? AND status=? AND file_size IS NOT NULL", appId, BackupStatusCompleted).
+		Order("created_at DESC").First(&backup).Error
+	if err != nil || backup.FileSize == nil {
+		return 0
+	}
+	return *backup.FileSize
+}
+
+func GetAppIDByBackupID(backupId int64) (int64, error) {
+	var backup Backup
+	err := db.Select("app_id").First(&backup, backupId).Error
+	return backup.AppID, err
+}
+
+func GetExpiredBackups() ([]Backup, error) {
+	var backups []Backup
+	err := db.Where("auto_delete_at IS NOT NULL AND auto_delete_at < ? AND status != ?", time.Now(), BackupStatusDeleted).Find(&backups).Error
+	return backups, err
+}
+
+// backups and restores that were running when mist stopped can't finish anymore
+func FailInterruptedBackups() error {
+	errorMsg := "system died before backup could complete"
+	err := db.Model(&Backup{}).Where("status IN ?", []BackupStatus{BackupStatusPending, BackupStatusInProgress}).
+		Updates(map[string]interface{}{"status": BackupStatusFailed, "error_message": errorMsg}).Error
+	if err != nil {
+		return err
+	}
+	restoreMsg := "system died before restore could complete"
+	return db.Model(&Backup{}).Where("restore_status = ?", BackupStatusInProgress).
+		Updates(map[string]interface{}{"restore_status": BackupStatusFailed, "restore_error": restoreMsg}).Error
 }
 
 //##########################################################################################################################
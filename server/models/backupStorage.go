@@ -0,0 +1,101 @@
+package models
+
+import (
+	"github.com/corecollectives/mist/utils"
+)
+
+// where new backups are stored, backups keep the storage they were created with
+type BackupStorageSettings struct {
+	Type        StorageType `json:"type"`
+	S3Endpoint  string      `json:"s3Endpoint"`
+	S3Bucket    string      `json:"s3Bucket"`
+	S3Region    string      `json:"s3Region"`
+	S3Prefix    string      `json:"s3Prefix"`
+	S3PathStyle bool        `json:"s3PathStyle"`
+	S3AccessKey string      `json:"s3AccessKey"`
+	S3SecretKey string      `json:"-"`
+}
+
+func (s *BackupStorageSettings) ToJson() map[string]interface{} {
+	return map[string]interface{}{
+		"type":           s.Type,
+		"s3Endpoint":     s.S3Endpoint,
+		"s3Bucket":       s.S3Bucket,
+		"s3Region":       s.S3Region,
+		"s3Prefix":       s.S3Prefix,
+		"s3PathStyle":    s.S3PathStyle,
+		"s3AccessKey":    s.S3AccessKey,
+		"hasS3SecretKey": s.S3SecretKey != "",
+	}
+}
+
+func GetBackupStorageSettings() (*BackupStorageSettings, error) {
+	values := map[string]string{}
+	for _, key := range []string{
+		"backup_storage_type", "backup_s3_endpoint", "backup_s3_bucket", "backup_s3_region",
+		"backup_s3_prefix", "backup_s3_path_style", "backup_s3_access_key", "backup_s3_secret_key",
+	} {
+		value, err := GetSystemSetting(key)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+
+	settings := &BackupStorageSettings{
+		Type:        StorageType(values["backup_storage_type"]),
+		S3Endpoint:  values["backup_s3_endpoint"],
+		S3Bucket:    values["backup_s3_bucket"],
+		S3Region:    values["backup_s3_region"],
+		S3Prefix:    values["backup_s3_prefix"],
+		S3PathStyle: values["backup_s3_path_style"] == "true",
+	}
+	if settings.Type == "" {
+		settings.Type = StorageTypeLocal
+	}
+
+	// the keys are encrypted at rest like registry passwords
+	var err error
+	if values["backup_s3_access_key"] != "" {
+		if settings.S3AccessKey, err = utils.DecryptSecret(values["backup_s3_access_key"]); err != nil {
+			return nil, err
+		}
+	}
+	if values["backup_s3_secret_key"] != "" {
+		if settings.S3SecretKey, err = utils.DecryptSecret(values["backup_s3_secret_key"]); err != nil {
+			return nil, err
+		}
+	}
+	return settings, nil
+}
+
+func UpdateBackupStorageSettings(s *BackupStorageSettings) error {
+	accessKey, err := utils.EncryptSecret(s.S3AccessKey)
+	if err != nil {
+		return err
+	}
+	secretKey, err := utils.EncryptSecret(s.S3SecretKey)
+	if err != nil {
+		return err
+	}
+	pathStyle := "false"
+	if s.S3PathStyle {
+		pathStyle = "true"
+	}
+
+	for key, value := range map[string]string{
+		"backup_storage_type":  string(s.Type),
+		"backup_s3_endpoint":   s.S3Endpoint,
+		"backup_s3_bucket":     s.S3Bucket,
+		"backup_s3_region":     s.S3Region,
+		"backup_s3_prefix":     s.S3Prefix,
+		"backup_s3_path_style": pathStyle,
+		"backup_s3_access_key": accessKey,
+		"backup_s3_secret_key": secretKey,
+	} {
+		if err := SetSystemSetting(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,15 +1,136 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CronRunMode string
+
+const (
+	// docker exec in the app's running container
+	CronRunModeContainer CronRunMode = "container"
+	// a new container from the app's current image, removed after the run
+	CronRunModeOneOff CronRunMode = "oneoff"
+)
 
 type Cron struct {
-	ID        int64     `gorm:"primaryKey;autoIncrement:true" json:"id"`
-	AppID     int64     `gorm:"index;constraint:OnDelete:CASCADE;not null" json:"app_id"`
-	Name      string    `gorm:"index;not null" json:"name"`
-	Schedule  string    `gorm:"not null" json:"schedule"`
-	Command   string    `gorm:"not null" json:"command"`
-	LastRun   *time.Time `gorm:"type:timestamp" json:"last_run"`
-	NextRun   *time.Time `gorm:"type:timestamp" json:"next_run"`
-	Enable    bool      `gorm:"default:true" json:"enable"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	ID       int64       `gorm:"primaryKey;autoIncrement:true" json:"id"`
+	AppID    int64       `gorm:"index;constraint:OnDelete:CASCADE;not null" json:"appId"`
+	Name     string      `gorm:"index;not null" json:"name"`
+	Schedule string      `gorm:"not null" json:"schedule"`
+	Command  string      `gorm:"not null" json:"command"`
+	RunMode  CronRunMode `gorm:"default:'container'" json:"runMode"`
+	// seconds before a run is stopped
+	Timeout int `gorm:"default:3600" json:"timeout"`
+
+	LastRun    *time.Time     `gorm:"type:timestamp" json:"lastRun"`
+	LastStatus *CronRunStatus `json:"lastStatus,omitempty"`
+	NextRun    *time.Time     `gorm:"type:timestamp;index" json:"nextRun"`
+	Enable     bool           `gorm:"default:true" json:"enable"`
+
+	CreatedBy *int64    `gorm:"constraint:OnDelete:SET NULL" json:"createdBy,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+func (c *Cron) ToJson() map[string]interface{} {
+	return map[string]interface{}{
+		"id":         c.ID,
+		"appId":      c.AppID,
+		"name":       c.Name,
+		"schedule":   c.Schedule,
+		"command":    c.Command,
+		"runMode":    c.RunMode,
+		"timeout":    c.Timeout,
+		"lastRun":    c.LastRun,
+		"lastStatus": c.LastStatus,
+		"nextRun":    c.NextRun,
+		"enable":     c.Enable,
+		"createdBy":  c.CreatedBy,
+		"createdAt":  c.CreatedAt,
+		"updatedAt":  c.UpdatedAt,
+	}
+}
+
+func (c *Cron) InsertInDB() error {
+	enable := c.Enable
+	if err := db.Create(c).Error; err != nil {
+		return err
+	}
+	// gorm leaves out false on create because of the default tag
+	if !enable {
+		c.Enable = false
+		return db.Model(c).Update("enable", false).Error
+	}
+	return nil
+}
+
+func GetCronsByAppID(appID int64) ([]Cron, error) {
+	var crons []Cron
+	result := db.Where("app_id = ?", appID).Order("name ASC").Find(&crons)
+	return crons, result.Error
+}
+
+func GetCronByID(cronID int64) (*Cron, error) {
+	var cron Cron
+	result := db.First(&cron, cronID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &cron, nil
+}
+
+func GetAppIDByCronID(cronID int64) (int64, error) {
+	var cron Cron
+	err := db.Select("app_id").First(&cron, cronID).Error
+	return cron.AppID, err
+}
+
+func (c *Cron) UpdateCron() error {
+	return db.Model(c).Select("name", "schedule", "command", "run_mode", "timeout", "next_run", "enable").Updates(c).Error
+}
+
+// enabled jobs whose next run is due
+func GetDueCrons(now time.Time) ([]Cron, error) {
+	var crons []Cron
+	result := db.Where("enable = ? AND next_run IS NOT NULL AND next_run <= ?", true, now).Find(&crons)
+	return crons, result.Error
+}
+
+// enabled jobs without a next run, e.g. created before the scheduler existed
+func GetUnscheduledCrons() ([]Cron, error) {
+	var crons []Cron
+	result := db.Where("enable = ? AND next_run IS NULL", true).Find(&crons)
+	return crons, result.Error
+}
+
+func SetCronNextRun(cronID int64, nextRun *time.Time) error {
+	return db.Model(&Cron{}).Where("id = ?", cronID).Update("next_run", nextRun).Error
+}
+
+func SetCronLastRun(cronID int64, lastRun time.Time, status CronRunStatus) error {
+	return db.Model(&Cron{}).Where("id = ?", cronID).Updates(map[string]interface{}{
+		"last_run":    lastRun,
+		"last_status": status,
+	}).Error
+}
+
+func DeleteCron(cronID int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("cron_id = ?", cronID).Delete(&CronRun{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Cron{}, cronID).Error
+	})
+}
+
+func DeleteAppCrons(appID int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("app_id = ?", appID).Delete(&CronRun{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("app_id = ?", appID).Delete(&Cron{}).Error
+	})
 }
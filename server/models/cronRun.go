@@ -0,0 +1,120 @@
+package models
+
+import "time"
+
+type CronRunStatus string
+type CronRunTrigger string
+
+const (
+	CronRunStatusRunning  CronRunStatus = "running"
+	CronRunStatusSuccess  CronRunStatus = "success"
+	CronRunStatusFailed   CronRunStatus = "failed"
+	CronRunStatusTimedOut CronRunStatus = "timed_out"
+
+	CronRunTriggerSchedule CronRunTrigger = "schedule"
+	CronRunTriggerManual   CronRunTrigger = "manual"
+)
+
+// runs kept per job, older ones are pruned after each run
+const cronRunHistoryLimit = 50
+
+type CronRun struct {
+	ID     int64 `gorm:"primaryKey;autoIncrement:true" json:"id"`
+	CronID int64 `gorm:"index;not null;constraint:OnDelete:CASCADE" json:"cronId"`
+	AppID  int64 `gorm:"index;not null;constraint:OnDelete:CASCADE" json:"appId"`
+
+	Trigger     CronRunTrigger `gorm:"not null" json:"trigger"`
+	TriggeredBy *int64         `gorm:"constraint:OnDelete:SET NULL" json:"triggeredBy,omitempty"`
+	Command     string         `gorm:"not null" json:"command"`
+	RunMode     CronRunMode    `json:"runMode"`
+
+	Status   CronRunStatus `gorm:"default:'running';index" json:"status"`
+	ExitCode *int          `json:"exitCode,omitempty"`
+	// combined stdout and stderr, only the tail is kept for chatty commands
+	Output       string  `gorm:"type:text" json:"output"`
+	ErrorMessage *string `json:"errorMessage,omitempty"`
+
+	StartedAt  time.Time  `gorm:"autoCreateTime;index:,sort:desc" json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	// milliseconds
+	Duration *int64 `json:"duration,omitempty"`
+}
+
+func (r *CronRun) ToJson() map[string]interface{} {
+	return map[string]interface{}{
+		"id":           r.ID,
+		"cronId":       r.CronID,
+		"appId":        r.AppID,
+		"trigger":      r.Trigger,
+		"triggeredBy":  r.TriggeredBy,
+		"command":      r.Command,
+		"runMode":      r.RunMode,
+		"status":       r.Status,
+		"exitCode":     r.ExitCode,
+		"output":       r.Output,
+		"errorMessage": r.ErrorMessage,
+		"startedAt":    r.StartedAt,
+		"finishedAt":   r.FinishedAt,
+		"duration":     r.Duration,
+	}
+}
+
+func (r *CronRun) InsertInDB() error {
+	r.Status = CronRunStatusRunning
+	return db.Create(r).Error
+}
+
+func (r *CronRun) MarkAsFinished(status CronRunStatus, exitCode *int, output string, errorMsg *string) error {
+	now := time.Now()
+	duration := now.Sub(r.StartedAt).Milliseconds()
+	r.Status = status
+	r.ExitCode = exitCode
+	r.Output = output
+	r.ErrorMessage = errorMsg
+	r.FinishedAt = &now
+	r.Duration = &duration
+	return db.Model(r).Updates(map[string]interface{}{
+		"status":        status,
+		"exit_code":     exitCode,
+		"output":        output,
+		"error_message": errorMsg,
+		"finished_at":   now,
+		"duration":      duration,
+	}).Error
+}
+
+// latest runs first, output is left out to keep the list small
+func GetCronRuns(cronID int64, limit int) ([]CronRun, error) {
+	var runs []CronRun
+	result := db.Omit("output").Where("cron_id = ?", cronID).Order("started_at DESC").Limit(limit).Find(&runs)
+	return runs, result.Error
+}
+
+func GetCronRunByID(runID int64) (*CronRun, error) {
+	var run CronRun
+	result := db.First(&run, runID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &run, nil
+}
+
+func GetAppIDByCronRunID(runID int64) (int64, error) {
+	var run CronRun
+	err := db.Select("app_id").First(&run, runID).Error
+	return run.AppID, err
+}
+
+func PruneCronRuns(cronID int64) error {
+	keep := db.Model(&CronRun{}).Select("id").Where("cron_id = ?", cronID).Order("started_at DESC").Limit(cronRunHistoryLimit)
+	return db.Where("cron_id = ? AND id NOT IN (?)", cronID, keep).Delete(&CronRun{}).Error
+}
+
+// runs cut off by a restart are still "running", the process that ran them is gone
+func FailInterruptedCronRuns() error {
+	return db.Model(&CronRun{}).Where("status = ?", CronRunStatusRunning).Updates(map[string]interface{}{
+		"status":        CronRunStatusFailed,
+		"error_message": "Run was interrupted by a server restart",
+		"finished_at":   time.Now(),
+	}).Error
+}
@@ -11,12 +11,14 @@ type DeploymentStatus string
 
 const (
 	DeploymentStatusPending    DeploymentStatus = "pending"
+	DeploymentStatusCloning    DeploymentStatus = "cloning"
 	DeploymentStatusBuilding   DeploymentStatus = "building"
 	DeploymentStatusDeploying  DeploymentStatus = "deploying"
 	DeploymentStatusSuccess    DeploymentStatus = "success"
 	DeploymentStatusFailed     DeploymentStatus = "failed"
 	DeploymentStatusStopped    DeploymentStatus = "stopped"
 	DeploymentStatusRolledBack DeploymentStatus = "rolled_back"
+	DeploymentStatusCancelled  DeploymentStatus = "cancelled"
 )
 
 type Deployment struct {
@@ -56,6 +58,8 @@ type Deployment struct {
 	IsActive bool `gorm:"default:false;index:idx_deployments_is_active" json:"is_active"`
 
 	RolledBackFrom *int64 `gorm:"constraint:OnDelete:SET NULL" json:"rolled_back_from,omitempty"`
+
+	QueuePosition int64 `gorm:"default:0;index:idx_deployments_queue_position" json:"queue_position"`
 }
 
 func (d *Deployment) ToJson() map[string]interface{} {
@@ -82,6 +86,7 @@ func (d *Deployment) ToJson() map[string]interface{} {
 		"duration":         d.Duration,
 		"isActive":         d.IsActive,
 		"rolledBackFrom":   d.RolledBackFrom,
+		"queuePosition":    d.QueuePosition,
 	}
 }
 
@@ -170,7 +175,7 @@ func UpdateDeploymentStatus(depID int64, status, stage string, progress int, err
 		"progress":      progress,
 		"error_message": errorMsg,
 	}
-	if status == string(DeploymentStatusFailed) || status == string(DeploymentStatusSuccess) || status == string(DeploymentStatusStopped) {
+	if status == string(DeploymentStatusFailed) || status == string(DeploymentStatusSuccess) || status == string(DeploymentStatusStopped) || status == string(DeploymentStatusCancelled) {
 		now := time.Now()
 		updates["finished_at"] = &now
 		if d.StartedAt != nil {
@@ -207,6 +212,97 @@ func UpdateContainerInfo(depID int64, containerID, containerName, imageTag strin
 	return db.Model(&Deployment{}).Where("id = ?", depID).Updates(updates).Error
 }
 
+// returns the successful deployments of an app that still have an image to roll back to, newest first
+func GetRollbackCandidates(appID int64, limit int) ([]Deployment, error) {
+	var deployments []Deployment
+	query := db.Where("app_id = ? AND status IN ? AND image_tag IS NOT NULL AND image_tag != ''",
+		appID, []DeploymentStatus{DeploymentStatusSuccess, DeploymentStatusRolledBack}).
+		Order("created_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&deployments).Error
+	return deployments, err
+}
+
+// marks the currently active deployment of the app as rolled back and makes the target active again
+func ActivateRollbackTarget(targetID int64, appID int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Deployment{}).
+			Where("app_id = ? AND is_active = ? AND id != ?", appID, true, targetID).
+			Update("status", DeploymentStatusRolledBack).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&Deployment{}).Where("app_id = ?", appID).Update("is_active", false).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&Deployment{}).Where("id = ?", targetID).Updates(map[string]interface{}{
+			"is_active": true,
+			"status":    DeploymentStatusSuccess,
+		}).Error
+	})
+}
+
+// puts the deployment at the end of the persisted queue and returns its position
+func EnqueueDeployment(depID int64) (int64, error) {
+	var position int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var maxPos *int64
+		err := tx.Model(&Deployment{}).
+			Where("status = ?", DeploymentStatusPending).
+			Pluck("MAX(queue_position)", &maxPos).Error
+		if err != nil {
+			return err
+		}
+		position = 1
+		if maxPos != nil {
+			position = *maxPos + 1
+		}
+		return tx.Model(&Deployment{}).Where("id = ?", depID).Update("queue_position", position).Error
+	})
+	return position, err
+}
+
+// puts a deployment interrupted by a shutdown back at the front of the queue, it starts over
+// once the server is back
+func RequeueDeployment(depID int64) error {
+	return db.Model(&Deployment{}).Where("id = ?", depID).Updates(map[string]interface{}{
+		"status":         DeploymentStatusPending,
+		"stage":          "pending",
+		"progress":       0,
+		"error_message":  nil,
+		"started_at":     nil,
+		"queue_position": 0,
+	}).Error
+}
+
+// returns the deployments still waiting in the queue, in the order they should run
+func GetQueuedDeployments() ([]Deployment, error) {
+	var deployments []Deployment
+	err := db.Where("status = ?", DeploymentStatusPending).
+		Order("queue_position ASC").
+		Order("created_at ASC").
+		Find(&deployments).Error
+	return deployments, err
+}
+
+// persists a new queue order, the first id gets position 1
+func ReorderQueuedDeployments(depIDs []int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for i, id := range depIDs {
+			err := tx.Model(&Deployment{}).
+				Where("id = ? AND status = ?", id, DeploymentStatusPending).
+				Update("queue_position", i+1).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 //#############################################################################################################
 //ARCHIVED CODE BELOW------>
 
@@ -503,7 +599,7 @@ func GetIncompleteDeployments() ([]Deployment, error) {
 	var deployments []Deployment
 
 	err := db.
-		Where("status IN ?", []string{"building", "deploying"}).
+		Where("status IN ?", []DeploymentStatus{DeploymentStatusCloning, DeploymentStatusBuilding, DeploymentStatusDeploying}).
 		Order("created_at DESC").
 		Find(&deployments).Error
 
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/corecollectives/mist/utils"
+)
+
+type DNSProvider string
+
+const (
+	DNSProviderCloudflare   DNSProvider = "cloudflare"
+	DNSProviderRoute53      DNSProvider = "route53"
+	DNSProviderDigitalOcean DNSProvider = "digitalocean"
+	DNSProviderRFC2136      DNSProvider = "rfc2136"
+)
+
+// a credential of a dns provider, passed to traefik as the environment variable its
+// provider reads
+type DNSCredential struct {
+	Field    string `json:"field"`
+	Env      string `json:"env"`
+	Required bool   `json:"required"`
+	Secret   bool   `json:"secret"`
+}
+
+var DNSProviderCredentials = map[DNSProvider][]DNSCredential{
+	DNSProviderCloudflare: {
+		{Field: "apiToken", Env: "CF_DNS_API_TOKEN", Required: true, Secret: true},
+		{Field: "zoneApiToken", Env: "CF_ZONE_API_TOKEN", Secret: true},
+	},
+	DNSProviderRoute53: {
+		{Field: "accessKeyId", Env: "AWS_ACCESS_KEY_ID", Required: true},
+		{Field: "secretAccessKey", Env: "AWS_SECRET_ACCESS_KEY", Required: true, Secret: true},
+		{Field: "region", Env: "AWS_REGION", Required: true},
+		{Field: "hostedZoneId", Env: "AWS_HOSTED_ZONE_ID"},
+	},
+	DNSProviderDigitalOcean: {
+		{Field: "authToken", Env: "DO_AUTH_TOKEN", Required: true, Secret: true},
+	},
+	DNSProviderRFC2136: {
+		{Field: "nameserver", Env: "RFC2136_NAMESERVER", Required: true},
+		{Field: "tsigKey", Env: "RFC2136_TSIG_KEY"},
+		{Field: "tsigSecret", Env: "RFC2136_TSIG_SECRET", Secret: true},
+		{Field: "tsigAlgorithm", Env: "RFC2136_TSIG_ALGORITHM"},
+	},
+}
+
+// dns provider traefik's dns-01 resolver creates challenge records with
+type DNSProviderSettings struct {
+	Provider DNSProvider `json:"provider"`
+	// keyed by DNSCredential.Field
+	Credentials map[string]string `json:"-"`
+}
+
+func (s *DNSProviderSettings) ToJson() map[string]interface{} {
+	credentials := map[string]string{}
+	secrets := map[string]bool{}
+	for _, credential := range DNSProviderCredentials[s.Provider] {
+		if credential.Secret {
+			secrets[credential.Field] = s.Credentials[credential.Field] != ""
+		} else {
+			credentials[credential.Field] = s.Credentials[credential.Field]
+		}
+	}
+	return map[string]interface{}{
+		"provider":    s.Provider,
+		"configured":  s.Configured(),
+		"credentials": credentials,
+		"hasSecrets":  secrets,
+	}
+}
+
+func (s *DNSProviderSettings) Configured() bool {
+	return s.Provider != ""
+}
+
+// environment variables for traefik's dns provider
+func (s *DNSProviderSettings) Env() map[string]string {
+	env := map[string]string{}
+	for _, credential := range DNSProviderCredentials[s.Provider] {
+		if value := s.Credentials[credential.Field]; value != "" {
+			env[credential.Env] = value
+		}
+	}
+	return env
+}
+
+func GetDNSProviderSettings() (*DNSProviderSettings, error) {
+	provider, err := GetSystemSetting("dns_provider")
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := GetSystemSetting("dns_credentials")
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &DNSProviderSettings{Provider: DNSProvider(provider), Credentials: map[string]string{}}
+	if credentials != "" {
+		// the whole set is encrypted, most providers only have secrets
+		decrypted, err := utils.DecryptSecret(credentials)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(decrypted), &settings.Credentials); err != nil {
+			return nil, err
+		}
+	}
+	return settings, nil
+}
+
+func UpdateDNSProviderSettings(s *DNSProviderSettings) error {
+	credentials := ""
+	if s.Configured() {
+		data, err := json.Marshal(s.Credentials)
+		if err != nil {
+			return err
+		}
+		if credentials, err = utils.EncryptSecret(string(data)); err != nil {
+			return err
+		}
+	}
+
+	if err := SetSystemSetting("dns_provider", string(s.Provider)); err != nil {
+		return err
+	}
+	return SetSystemSetting("dns_credentials", credentials)
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"crypto/x509"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/corecollectives/mist/utils"
@@ -26,12 +29,24 @@ const (
 	AcmeChallengeTypeTlsAlpn01 acmeChallengeType = "tls-alpn-01"
 )
 
+func ParseSSLProvider(provider string) (sslProvider, bool) {
+	switch p := sslProvider(provider); p {
+	case SSLProvider, SSLProviderCustom, SSLProviderNone:
+		return p, true
+	}
+	return "", false
+}
+
 type Domain struct {
 	ID int64 `gorm:"primaryKey;autoIncrement:false" json:"id"`
 
 	AppID int64 `gorm:"index;not null;constraint:OnDelete:CASCADE" json:"appId"`
 
-	Domain string `gorm:"column:domain_name;uniqueIndex;not null" json:"domain"`
+	Domain string `gorm:"column:domain_name;uniqueIndex:idx_domains_host_path;not null" json:"domain"`
+	// serves the app under a path of the host, empty for the whole host
+	PathPrefix string `gorm:"uniqueIndex:idx_domains_host_path;not null;default:''" json:"pathPrefix"`
+	// container port the domain routes to, the app's port when unset
+	TargetPort *int `json:"targetPort,omitempty"`
 
 	SslStatus   sslStatus   `gorm:"default:'pending';index" json:"sslStatus"`
 	SslProvider sslProvider `gorm:"default:'letsencrypt'" json:"sslProvider,omitempty"`
@@ -50,9 +65,11 @@ type Domain struct {
 	ExpiresAt          *time.Time `gorm:"index" json:"expiresAt,omitempty"`
 	LastRenewalAttempt *time.Time `json:"lastRenewalAttempt,omitempty"`
 	RenewalError       *string    `json:"renewalError,omitempty"`
+	// when users were warned about the current certificate expiring
+	ExpiryWarningSentAt *time.Time `json:"expiryWarningSentAt,omitempty"`
 
 	AutoRenew         bool `gorm:"default:true" json:"autoRenew"`
-	ForceHttps        bool `gorm:"default:false" json:"forceHttps"`
+	ForceHttps        bool `gorm:"default:true" json:"forceHttps"`
 	HstsEnabled       bool `gorm:"default:false" json:"hstsEnabled"`
 	HstsMaxAge        int  `gorm:"default:31536000" json:"hstsMaxAge"`
 	RedirectWww       bool `gorm:"default:false" json:"redirectWww"`
@@ -67,12 +84,16 @@ type Domain struct {
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
 }
 
-func CreateDomain(appID int64, domain string) (*Domain, error) {
+func CreateDomain(appID int64, domain, pathPrefix string, targetPort *int) (*Domain, error) {
 	var d Domain
 	id := utils.GenerateRandomId()
 	d.ID = id
 	d.AppID = appID
 	d.Domain = domain
+	d.PathPrefix = pathPrefix
+	d.TargetPort = targetPort
+	// set explicitly, the column default of databases created before it was configurable is false
+	d.ForceHttps = true
 	result := db.Create(&d)
 	if result.Error != nil {
 		return nil, result.Error
@@ -88,20 +109,45 @@ func GetDomainsByAppID(appID int64) ([]Domain, error) {
 	return domains, nil
 }
 
+// the oldest domain serving the app's own port, ones for the whole host before path prefixes
 func GetPrimaryDomainByAppID(appID int64) (*Domain, error) {
 	var d Domain
-	result := db.Where("app_id = ?", appID).Order("created_at ASC").First(&d)
+	result := db.Where("app_id = ?", appID).
+		Order("target_port IS NOT NULL, path_prefix != '', created_at ASC").
+		First(&d)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return &d, nil
 }
 
-func UpdateDomain(id int64, domain string) error {
-	result := db.Model(&Domain{}).Where("id=?", id).Update("domain_name", domain)
+func UpdateDomain(id int64, domain, pathPrefix string, targetPort *int) error {
+	result := db.Model(&Domain{}).Where("id=?", id).Updates(map[string]interface{}{
+		"domain_name": domain,
+		"path_prefix": pathPrefix,
+		"target_port": targetPort,
+	})
 	return result.Error
 }
 
+// whether another domain already routes the host and path
+func DomainRouteExists(domain, pathPrefix string, excludeID int64) (bool, error) {
+	var count int64
+	err := db.Model(&Domain{}).Where("domain_name = ? AND path_prefix = ? AND id != ?", domain, pathPrefix, excludeID).Count(&count).Error
+	return count > 0, err
+}
+
+// projects of the apps that already route a path of the host
+func GetDomainHostProjectIDs(domain string, excludeID int64) ([]int64, error) {
+	var projectIDs []int64
+	err := db.Model(&Domain{}).
+		Joins("JOIN apps ON apps.id = domains.app_id").
+		Where("domains.domain_name = ? AND domains.id != ?", domain, excludeID).
+		Distinct().
+		Pluck("apps.project_id", &projectIDs).Error
+	return projectIDs, err
+}
+
 func DeleteDomain(id int64) error {
 	result := db.Delete(&Domain{}, id)
 	return result.Error
@@ -116,6 +162,12 @@ func GetDomainByID(id int64) (*Domain, error) {
 	return &d, nil
 }
 
+func GetAppIDByDomainID(id int64) (int64, error) {
+	var d Domain
+	err := db.Select("app_id").First(&d, id).Error
+	return d.AppID, err
+}
+
 func UpdateDomainDnsStatus(id int64, configured bool, errorMsg *string) error {
 	updates := map[string]interface{}{
 		"last_dns_check": time.Now(),
@@ -133,6 +185,97 @@ func UpdateDomainDnsStatus(id int64, configured bool, errorMsg *string) error {
 	return db.Model(&Domain{ID: id}).Updates(updates).Error
 }
 
+// the other host a www redirect covers, www.example.com for example.com and the other way round
+func (d *Domain) WwwAlias() string {
+	if after, ok := strings.CutPrefix(d.Domain, "www."); ok {
+		return after
+	}
+	return "www." + d.Domain
+}
+
+// where the domain serves the app, https unless the domain has no certificate
+func (d *Domain) URL() string {
+	scheme := "https"
+	if d.SslProvider == SSLProviderNone {
+		scheme = "http"
+	}
+	return scheme + "://" + d.Domain + d.PathPrefix
+}
+
+// stores an uploaded certificate, the key is encrypted like other credentials
+func (d *Domain) SetCertificate(certPEM, keyPEM string, leaf *x509.Certificate) error {
+	key, err := utils.EncryptSecret(keyPEM)
+	if err != nil {
+		return err
+	}
+	issuer := leaf.Issuer.String()
+	d.CertificateData = &certPEM
+	d.KeyData = &key
+	d.Issuer = &issuer
+	d.IssuedAt = &leaf.NotBefore
+	d.ExpiresAt = &leaf.NotAfter
+	d.ExpiryWarningSentAt = nil
+	return nil
+}
+
+func (d *Domain) DecryptedKey() (string, error) {
+	if d.KeyData == nil {
+		return "", nil
+	}
+	return utils.DecryptSecret(*d.KeyData)
+}
+
+func (d *Domain) UpdateSSLSettings() error {
+	return db.Model(d).Select(
+		"ssl_provider", "ssl_status", "certificate_data", "key_data", "issuer", "issued_at", "expires_at", "expiry_warning_sent_at",
+		"force_https", "hsts_enabled", "hsts_max_age", "redirect_www", "redirect_www_to_root",
+	).Updates(d).Error
+}
+
+func GetDomainsWithSuffix(suffix string) ([]Domain, error) {
+	var domains []Domain
+	err := db.Where("domain_name LIKE ?", "%"+suffix).Find(&domains).Error
+	return domains, err
+}
+
+// domains served over https, checked by the certificate monitor
+func GetSSLDomains() ([]Domain, error) {
+	var domains []Domain
+	err := db.Where("ssl_provider != ?", SSLProviderNone).Find(&domains).Error
+	return domains, err
+}
+
+// saves what the certificate monitor found. updated_at is left alone, it tracks changes made by users
+func (d *Domain) UpdateCertificateStatus() error {
+	return db.Model(d).Select(
+		"ssl_status", "acme_challenge_type", "issuer", "issued_at", "expires_at", "last_renewal_attempt", "renewal_error", "expiry_warning_sent_at",
+	).UpdateColumns(d).Error
+}
+
+// uploaded certificates of all domains, written to traefik's file provider
+func GetCustomCertificates() ([]utils.TLSCertificate, error) {
+	var domains []Domain
+	err := db.Where("ssl_provider = ? AND certificate_data IS NOT NULL AND key_data IS NOT NULL", SSLProviderCustom).
+		Find(&domains).Error
+	if err != nil {
+		return nil, err
+	}
+
+	certificates := make([]utils.TLSCertificate, 0, len(domains))
+	for i := range domains {
+		key, err := domains[i].DecryptedKey()
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, utils.TLSCertificate{
+			Name:        strconv.FormatInt(domains[i].ID, 10),
+			Certificate: *domains[i].CertificateData,
+			Key:         key,
+		})
+	}
+	return certificates, nil
+}
+
 //#############################################################################################################################################
 //ARCHIVED CODE BELOW------->
 
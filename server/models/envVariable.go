@@ -67,6 +67,12 @@ func GetEnvVariableByID(id int64) (*EnvVariable, error) {
 	return &env, nil
 }
 
+func GetAppIDByEnvVariableID(id int64) (int64, error) {
+	var env EnvVariable
+	err := db.Select("app_id").First(&env, id).Error
+	return env.AppID, err
+}
+
 //##########################################################################################################
 //ARCHIVED CODE BELOW
 
@@ -26,6 +26,18 @@ const (
 	NotificationCustom            NotificationType = "custom"
 )
 
+func IsNotificationType(t NotificationType) bool {
+	switch t {
+	case NotificationDeploymentSuccess, NotificationDeploymentFailed, NotificationDeploymentStarted,
+		NotificationSSLExpiryWarning, NotificationSSLRenewalSuccess, NotificationSSLRenewalFailed,
+		NotificationResourceAlert, NotificationAppError, NotificationAppStopped,
+		NotificationBackupSuccess, NotificationBackupFailed, NotificationUserInvited,
+		NotificationMemberAdded, NotificationSystemUpdate, NotificationCustom:
+		return true
+	}
+	return false
+}
+
 type NotificationPriority string
 
 const (
@@ -122,6 +134,15 @@ func GetNotificationsByUserID(userID int64, unreadOnly bool) ([]Notification, er
 	return notifications, result.Error
 }
 
+func GetNotificationByID(notificationID int64) (*Notification, error) {
+	var notification Notification
+	result := db.First(&notification, notificationID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &notification, nil
+}
+
 func (n *Notification) MarkAsRead() error {
 	return db.Model(n).Updates(map[string]interface{}{
 		"is_read": true,
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/corecollectives/mist/utils"
+	"gorm.io/gorm"
+)
+
+type NotificationChannelType string
+
+const (
+	ChannelTypeSlack   NotificationChannelType = "slack"
+	ChannelTypeDiscord NotificationChannelType = "discord"
+	ChannelTypeWebhook NotificationChannelType = "webhook"
+	ChannelTypeEmail   NotificationChannelType = "email"
+)
+
+// where notifications are delivered outside of mist. project channels receive the events of
+// their project, personal channels the events their user is notified about
+type NotificationChannel struct {
+	ID int64 `gorm:"primaryKey;autoIncrement:false" json:"id"`
+
+	Name string                  `gorm:"not null" json:"name"`
+	Type NotificationChannelType `gorm:"index;not null" json:"type"`
+
+	ProjectID *int64 `gorm:"index;constraint:OnDelete:CASCADE" json:"projectId,omitempty"`
+	UserID    *int64 `gorm:"index;constraint:OnDelete:CASCADE" json:"userId,omitempty"`
+
+	// slack, discord or webhook url, encrypted at rest since it is the credential
+	URL string `json:"-"`
+	// signs webhook payloads, encrypted at rest
+	Secret string `json:"-"`
+	// recipient of email channels
+	Email string `json:"email"`
+
+	// event types the channel receives, all when empty
+	Events       []NotificationType `gorm:"-" json:"events"`
+	EventsString string             `gorm:"column:events" json:"-"`
+
+	Enabled bool `gorm:"default:true" json:"enabled"`
+
+	CreatedBy *int64    `gorm:"constraint:OnDelete:SET NULL" json:"createdBy,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+func (c *NotificationChannel) BeforeSave(tx *gorm.DB) (err error) {
+	events := make([]string, 0, len(c.Events))
+	for _, event := range c.Events {
+		events = append(events, string(event))
+	}
+	c.EventsString = strings.Join(events, ",")
+	return
+}
+
+func (c *NotificationChannel) AfterFind(tx *gorm.DB) (err error) {
+	c.Events = []NotificationType{}
+	if c.EventsString != "" {
+		for _, event := range strings.Split(c.EventsString, ",") {
+			c.Events = append(c.Events, NotificationType(event))
+		}
+	}
+	return
+}
+
+func (c *NotificationChannel) ToJson() map[string]interface{} {
+	events := c.Events
+	if events == nil {
+		events = []NotificationType{}
+	}
+	return map[string]interface{}{
+		"id":        c.ID,
+		"name":      c.Name,
+		"type":      c.Type,
+		"projectId": c.ProjectID,
+		"userId":    c.UserID,
+		"hasUrl":    c.URL != "",
+		"hasSecret": c.Secret != "",
+		"email":     c.Email,
+		"events":    events,
+		"enabled":   c.Enabled,
+		"createdBy": c.CreatedBy,
+		"createdAt": c.CreatedAt,
+		"updatedAt": c.UpdatedAt,
+	}
+}
+
+func (c *NotificationChannel) Subscribes(eventType NotificationType) bool {
+	return len(c.Events) == 0 || slices.Contains(c.Events, eventType)
+}
+
+func (c *NotificationChannel) DecryptedURL() (string, error) {
+	return utils.DecryptSecret(c.URL)
+}
+
+func (c *NotificationChannel) DecryptedSecret() (string, error) {
+	return utils.DecryptSecret(c.Secret)
+}
+
+// encrypts url and secret, called with the plain values before the channel is saved
+func (c *NotificationChannel) SetCredentials(url, secret string) error {
+	var err error
+	if c.URL, err = utils.EncryptSecret(url); err != nil {
+		return err
+	}
+	if c.Secret, err = utils.EncryptSecret(secret); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *NotificationChannel) InsertInDB() error {
+	c.ID = utils.GenerateRandomId()
+	enabled := c.Enabled
+	if err := db.Create(c).Error; err != nil {
+		return err
+	}
+	// gorm leaves out false on create because of the default tag
+	if !enabled {
+		c.Enabled = false
+		return db.Model(c).Update("enabled", false).Error
+	}
+	return nil
+}
+
+func (c *NotificationChannel) UpdateChannel() error {
+	return db.Model(c).Select("name", "url", "secret", "email", "events", "enabled").Updates(c).Error
+}
+
+func GetNotificationChannelByID(channelID int64) (*NotificationChannel, error) {
+	var channel NotificationChannel
+	err := db.First(&channel, channelID).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &channel, nil
+}
+
+func GetProjectNotificationChannels(projectID int64) ([]NotificationChannel, error) {
+	var channels []NotificationChannel
+	err := db.Where("project_id = ?", projectID).Order("created_at ASC").Find(&channels).Error
+	return channels, err
+}
+
+func GetUserNotificationChannels(userID int64) ([]NotificationChannel, error) {
+	var channels []NotificationChannel
+	err := db.Where("user_id = ?", userID).Order("created_at ASC").Find(&channels).Error
+	return channels, err
+}
+
+// enabled channels of the project and of the given users
+func GetNotificationChannelsFor(projectID *int64, userIDs []int64) ([]NotificationChannel, error) {
+	var channels []NotificationChannel
+	query := db.Where("enabled = ?", true)
+	if projectID != nil {
+		query = query.Where(db.Where("project_id = ?", *projectID).Or("user_id IN ?", userIDs))
+	} else {
+		query = query.Where("user_id IN ?", userIDs)
+	}
+	err := query.Find(&channels).Error
+	return channels, err
+}
+
+func DeleteNotificationChannel(channelID int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("channel_id = ?", channelID).Delete(&NotificationDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&NotificationChannel{}, channelID).Error
+	})
+}
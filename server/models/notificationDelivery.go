@@ -0,0 +1,135 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryStatusPending DeliveryStatus = "pending"
+	DeliveryStatusSent    DeliveryStatus = "sent"
+	DeliveryStatusFailed  DeliveryStatus = "failed"
+)
+
+// one notification sent to one channel. failed attempts are retried with a backoff until
+// the delivery is sent or runs out of attempts
+type NotificationDelivery struct {
+	ID int64 `gorm:"primaryKey;autoIncrement:true" json:"id"`
+
+	ChannelID   int64                   `gorm:"index;not null;constraint:OnDelete:CASCADE" json:"channelId"`
+	ChannelType NotificationChannelType `gorm:"not null" json:"channelType"`
+	// in-app notification of the channel's user, unset for project channels
+	NotificationID *int64 `gorm:"index;constraint:OnDelete:SET NULL" json:"notificationId,omitempty"`
+
+	EventType NotificationType `gorm:"index;not null" json:"eventType"`
+	// the event as json, so retries send exactly what the first attempt sent
+	Payload string `gorm:"type:text;not null" json:"-"`
+
+	Status        DeliveryStatus `gorm:"default:'pending';index" json:"status"`
+	Attempts      int            `gorm:"default:0" json:"attempts"`
+	LastError     *string        `json:"lastError,omitempty"`
+	NextAttemptAt *time.Time     `gorm:"index" json:"nextAttemptAt,omitempty"`
+	SentAt        *time.Time     `json:"sentAt,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime;index:,sort:desc" json:"createdAt"`
+}
+
+func (d *NotificationDelivery) ToJson() map[string]interface{} {
+	return map[string]interface{}{
+		"id":             d.ID,
+		"channelId":      d.ChannelID,
+		"channelType":    d.ChannelType,
+		"notificationId": d.NotificationID,
+		"eventType":      d.EventType,
+		"status":         d.Status,
+		"attempts":       d.Attempts,
+		"lastError":      d.LastError,
+		"nextAttemptAt":  d.NextAttemptAt,
+		"sentAt":         d.SentAt,
+		"createdAt":      d.CreatedAt,
+	}
+}
+
+func CreateNotificationDeliveries(deliveries []NotificationDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for i := range deliveries {
+		deliveries[i].Status = DeliveryStatusPending
+		deliveries[i].NextAttemptAt = &now
+	}
+	return db.Create(&deliveries).Error
+}
+
+func GetDueNotificationDeliveries(now time.Time, limit int) ([]NotificationDelivery, error) {
+	var deliveries []NotificationDelivery
+	err := db.Where("status = ? AND next_attempt_at <= ?", DeliveryStatusPending, now).
+		Order("next_attempt_at ASC").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+func GetChannelDeliveries(channelID int64, limit int) ([]NotificationDelivery, error) {
+	var deliveries []NotificationDelivery
+	err := db.Where("channel_id = ?", channelID).Order("created_at DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (d *NotificationDelivery) MarkAsSent() error {
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(d).Updates(map[string]interface{}{
+			"status":          DeliveryStatusSent,
+			"attempts":        d.Attempts + 1,
+			"sent_at":         now,
+			"next_attempt_at": nil,
+		}).Error
+		if err != nil || d.NotificationID == nil {
+			return err
+		}
+
+		// keeps the per channel flags of the in-app notification in sync
+		column := ""
+		switch d.ChannelType {
+		case ChannelTypeEmail:
+			column = "email"
+		case ChannelTypeSlack:
+			column = "slack"
+		case ChannelTypeDiscord:
+			column = "discord"
+		case ChannelTypeWebhook:
+			column = "webhook"
+		default:
+			return nil
+		}
+		return tx.Model(&Notification{}).Where("id = ?", *d.NotificationID).Updates(map[string]interface{}{
+			column + "_sent":    true,
+			column + "_sent_at": now,
+		}).Error
+	})
+}
+
+// records a failed attempt, the delivery is given up once nextAttempt is nil
+func (d *NotificationDelivery) MarkAttemptFailed(errMsg string, nextAttempt *time.Time) error {
+	status := DeliveryStatusPending
+	if nextAttempt == nil {
+		status = DeliveryStatusFailed
+	}
+	return db.Model(d).Updates(map[string]interface{}{
+		"status":          status,
+		"attempts":        d.Attempts + 1,
+		"last_error":      errMsg,
+		"next_attempt_at": nextAttempt,
+	}).Error
+}
+
+// sent and given up deliveries are kept for a month
+func DeleteOldNotificationDeliveries() error {
+	return db.Where("status != ? AND created_at < ?", DeliveryStatusPending, time.Now().AddDate(0, 0, -30)).
+		Delete(&NotificationDelivery{}).Error
+}
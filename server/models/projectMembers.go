@@ -13,3 +13,9 @@ type ProjectMember struct {
 func (ProjectMember) TableName() string {
 	return "project_members"
 }
+
+func GetProjectMemberIDs(projectID int64) ([]int64, error) {
+	var userIDs []int64
+	err := db.Model(&ProjectMember{}).Where("project_id = ?", projectID).Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
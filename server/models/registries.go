@@ -1,6 +1,12 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"github.com/corecollectives/mist/utils"
+	"gorm.io/gorm"
+)
 
 type Registry struct {
 	ID int64 `gorm:"primaryKey;autoIncrement:true" json:"id"`
@@ -10,7 +16,8 @@ type Registry struct {
 	RegistryURL string `gorm:"uniqueIndex:idx_project_registry;not null" json:"registryUrl"`
 
 	Username string `json:"username"`
-	Password string `json:"password"`
+	// encrypted at rest, use DecryptedPassword to read it
+	Password string `json:"-"`
 
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
 }
@@ -18,3 +25,108 @@ type Registry struct {
 func (Registry) TableName() string {
 	return "registries"
 }
+
+func (r *Registry) ToJson() map[string]interface{} {
+	return map[string]interface{}{
+		"id":          r.ID,
+		"projectId":   r.ProjectID,
+		"registryUrl": r.RegistryURL,
+		"username":    r.Username,
+		"hasPassword": r.Password != "",
+		"createdAt":   r.CreatedAt,
+	}
+}
+
+func (r *Registry) DecryptedPassword() (string, error) {
+	return utils.DecryptSecret(r.Password)
+}
+
+// host part of the registry url, "https://ghcr.io/org" becomes "ghcr.io"
+func (r *Registry) Host() string {
+	host := strings.TrimPrefix(strings.TrimPrefix(r.RegistryURL, "https://"), "http://")
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+	return strings.ToLower(host)
+}
+
+// registry url without scheme, used as prefix when pushing images
+func (r *Registry) Repository() string {
+	repo := strings.TrimPrefix(strings.TrimPrefix(r.RegistryURL, "https://"), "http://")
+	return strings.TrimSuffix(repo, "/")
+}
+
+func GetRegistriesByProjectID(projectID int64) ([]Registry, error) {
+	var registries []Registry
+	err := db.Where("project_id = ?", projectID).Order("created_at DESC").Find(&registries).Error
+	return registries, err
+}
+
+func GetRegistryByID(id int64) (*Registry, error) {
+	var registry Registry
+	err := db.First(&registry, id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &registry, nil
+}
+
+// returns the project registry serving the given registry host, nil when there is none
+func GetRegistryForHost(projectID int64, host string) (*Registry, error) {
+	registries, err := GetRegistriesByProjectID(projectID)
+	if err != nil {
+		return nil, err
+	}
+	host = strings.ToLower(host)
+	for i := range registries {
+		if registries[i].Host() == host {
+			return &registries[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func CreateRegistry(projectID int64, registryURL, username, password string) (*Registry, error) {
+	encrypted, err := utils.EncryptSecret(password)
+	if err != nil {
+		return nil, err
+	}
+	registry := &Registry{
+		ProjectID:   projectID,
+		RegistryURL: registryURL,
+		Username:    username,
+		Password:    encrypted,
+	}
+	if err := db.Create(registry).Error; err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// a nil password keeps the stored one
+func UpdateRegistry(id int64, registryURL, username string, password *string) error {
+	updates := map[string]interface{}{
+		"registry_url": registryURL,
+		"username":     username,
+	}
+	if password != nil {
+		encrypted, err := utils.EncryptSecret(*password)
+		if err != nil {
+			return err
+		}
+		updates["password"] = encrypted
+	}
+	return db.Model(&Registry{ID: id}).Updates(updates).Error
+}
+
+func DeleteRegistry(id int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&App{}).Where("push_registry_id = ?", id).Update("push_registry_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Registry{}, id).Error
+	})
+}
@@ -94,6 +94,17 @@ func RevokeAllUserSessions(userID int64, reason string) error {
 		}).Error
 }
 
+// revokes every active session of the user except keepSessionID, e.g. the one changing the password
+func RevokeOtherUserSessions(userID int64, keepSessionID string, reason string) error {
+	return db.Model(&Session{}).
+		Where("user_id = ? AND is_active = ? AND id <> ?", userID, true, keepSessionID).
+		Updates(map[string]interface{}{
+			"is_active":      false,
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		}).Error
+}
+
 func DeleteExpiredSessions() error {
 	return db.Where("expires_at < ?", time.Now()).Delete(&Session{}).Error
 }
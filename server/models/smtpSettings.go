@@ -0,0 +1,97 @@
+package models
+
+import (
+	"strconv"
+
+	"github.com/corecollectives/mist/utils"
+)
+
+type SMTPSecurity string
+
+const (
+	SMTPSecurityStartTLS SMTPSecurity = "starttls"
+	SMTPSecurityTLS      SMTPSecurity = "tls"
+	SMTPSecurityNone     SMTPSecurity = "none"
+)
+
+// mail server used by email notification channels
+type SMTPSettings struct {
+	Host     string       `json:"host"`
+	Port     int          `json:"port"`
+	Security SMTPSecurity `json:"security"`
+	Username string       `json:"username"`
+	Password string       `json:"-"`
+	From     string       `json:"from"`
+}
+
+func (s *SMTPSettings) ToJson() map[string]interface{} {
+	return map[string]interface{}{
+		"host":        s.Host,
+		"port":        s.Port,
+		"security":    s.Security,
+		"username":    s.Username,
+		"hasPassword": s.Password != "",
+		"from":        s.From,
+	}
+}
+
+func (s *SMTPSettings) Configured() bool {
+	return s.Host != "" && s.From != ""
+}
+
+func GetSMTPSettings() (*SMTPSettings, error) {
+	values := map[string]string{}
+	for _, key := range []string{
+		"smtp_host", "smtp_port", "smtp_security", "smtp_username", "smtp_password", "smtp_from",
+	} {
+		value, err := GetSystemSetting(key)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+
+	settings := &SMTPSettings{
+		Host:     values["smtp_host"],
+		Security: SMTPSecurity(values["smtp_security"]),
+		Username: values["smtp_username"],
+		From:     values["smtp_from"],
+	}
+	if settings.Security == "" {
+		settings.Security = SMTPSecurityStartTLS
+	}
+	settings.Port, _ = strconv.Atoi(values["smtp_port"])
+	if settings.Port == 0 {
+		settings.Port = 587
+	}
+
+	// encrypted at rest like registry passwords
+	if values["smtp_password"] != "" {
+		var err error
+		if settings.Password, err = utils.DecryptSecret(values["smtp_password"]); err != nil {
+			return nil, err
+		}
+	}
+	return settings, nil
+}
+
+func UpdateSMTPSettings(s *SMTPSettings) error {
+	password, err := utils.EncryptSecret(s.Password)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range map[string]string{
+		"smtp_host":     s.Host,
+		"smtp_port":     strconv.Itoa(s.Port),
+		"smtp_security": string(s.Security),
+		"smtp_username": s.Username,
+		"smtp_password": password,
+		"smtp_from":     s.From,
+	} {
+		if err := SetSystemSetting(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
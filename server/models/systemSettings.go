@@ -4,6 +4,8 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -21,8 +23,13 @@ type SystemSettings struct {
 	SecureCookies         bool    `json:"secureCookies"`
 	AutoCleanupContainers bool    `json:"autoCleanupContainers"`
 	AutoCleanupImages     bool    `json:"autoCleanupImages"`
+	DeploymentWorkers     int     `json:"deploymentWorkers"`
+	// days before a certificate expires that users are warned, 0 turns warnings off
+	SslExpiryWarningDays int `json:"sslExpiryWarningDays"`
 }
 
+const DefaultSslExpiryWarningDays = 14
+
 type SystemSettingEntry struct {
 	Key       string    `gorm:"primaryKey" json:"key"`
 	Value     string    `json:"value"`
@@ -142,6 +149,24 @@ func GetSystemSettings() (*SystemSettings, error) {
 	}
 	settings.AutoCleanupImages = autoCleanupImages == "true"
 
+	deploymentWorkers, err := GetSystemSetting("deployment_workers")
+	if err != nil {
+		return nil, err
+	}
+	settings.DeploymentWorkers = 1
+	if workers, err := strconv.Atoi(deploymentWorkers); err == nil && workers > 0 {
+		settings.DeploymentWorkers = workers
+	}
+
+	sslExpiryWarningDays, err := GetSystemSetting("ssl_expiry_warning_days")
+	if err != nil {
+		return nil, err
+	}
+	settings.SslExpiryWarningDays = DefaultSslExpiryWarningDays
+	if days, err := strconv.Atoi(sslExpiryWarningDays); err == nil && days >= 0 {
+		settings.SslExpiryWarningDays = days
+	}
+
 	return &settings, nil
 }
 
@@ -206,6 +231,14 @@ func UpdateDockerSettings(autoCleanupContainers, autoCleanupImages bool) error {
 	return nil
 }
 
+func UpdateDeploymentWorkers(workers int) error {
+	return SetSystemSetting("deployment_workers", strconv.Itoa(workers))
+}
+
+func UpdateSslExpiryWarningDays(days int) error {
+	return SetSystemSetting("ssl_expiry_warning_days", strconv.Itoa(days))
+}
+
 func (s *SystemSettings) UpdateSystemSettings() error {
 	wildcardValue := ""
 	if s.WildcardDomain != nil {
@@ -251,6 +284,18 @@ func (s *SystemSettings) UpdateSystemSettings() error {
 		return err
 	}
 
+	if s.DeploymentWorkers > 0 {
+		if err := SetSystemSetting("deployment_workers", strconv.Itoa(s.DeploymentWorkers)); err != nil {
+			return err
+		}
+	}
+
+	if s.SslExpiryWarningDays >= 0 {
+		if err := SetSystemSetting("ssl_expiry_warning_days", strconv.Itoa(s.SslExpiryWarningDays)); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -260,19 +305,33 @@ func GenerateAutoDomain(projectName, appName string) (string, error) {
 		return "", err
 	}
 
-	if settings.WildcardDomain == nil || *settings.WildcardDomain == "" {
+	wildcardDomain := settings.WildcardBase()
+	if wildcardDomain == "" {
 		return "", nil
 	}
 
-	wildcardDomain := *settings.WildcardDomain
-	if len(wildcardDomain) > 0 && wildcardDomain[0] == '*' {
-		wildcardDomain = wildcardDomain[1:]
-	}
-	if len(wildcardDomain) > 0 && wildcardDomain[0] == '.' {
-		wildcardDomain = wildcardDomain[1:]
+	return projectName + "-" + appName + "." + wildcardDomain, nil
+}
+
+// the wildcard domain without its *. prefix, apps get subdomains of it
+func (s *SystemSettings) WildcardBase() string {
+	if s.WildcardDomain == nil {
+		return ""
 	}
+	wildcardDomain := strings.TrimSpace(*s.WildcardDomain)
+	wildcardDomain = strings.TrimPrefix(wildcardDomain, "*")
+	return strings.TrimPrefix(wildcardDomain, ".")
+}
 
-	return projectName + "-" + appName + "." + wildcardDomain, nil
+// whether the host is a direct subdomain of the wildcard domain, the hosts a wildcard
+// certificate covers
+func (s *SystemSettings) IsWildcardSubdomain(host string) bool {
+	base := s.WildcardBase()
+	if base == "" {
+		return false
+	}
+	label, ok := strings.CutSuffix(host, "."+base)
+	return ok && label != "" && !strings.Contains(label, ".")
 }
 
 //############################################################################################################
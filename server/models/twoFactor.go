@@ -0,0 +1,170 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/corecollectives/mist/utils"
+)
+
+const backupCodeCount = 10
+
+// stores a new secret while enrollment is pending, two factor stays disabled until a first code confirms it
+func (u *User) SetPendingTwoFactorSecret(secret string) error {
+	encrypted, err := utils.EncryptSecret(secret)
+	if err != nil {
+		return err
+	}
+	u.TwoFactorSecret = &encrypted
+	return db.Model(u).Updates(map[string]interface{}{
+		"two_factor_secret":    encrypted,
+		"two_factor_enabled":   false,
+		"two_factor_last_step": nil,
+		"updated_at":           time.Now(),
+	}).Error
+}
+
+func (u *User) DecryptedTwoFactorSecret() (string, error) {
+	if u.TwoFactorSecret == nil || *u.TwoFactorSecret == "" {
+		return "", fmt.Errorf("two factor authentication is not set up")
+	}
+	return utils.DecryptSecret(*u.TwoFactorSecret)
+}
+
+// a code is accepted once, the step it belongs to is stored and codes of that or an earlier step are refused
+func (u *User) ValidateTOTP(code string) bool {
+	secret, err := u.DecryptedTwoFactorSecret()
+	if err != nil {
+		return false
+	}
+	step, ok := utils.MatchTOTP(secret, code, time.Now())
+	if !ok {
+		return false
+	}
+	// the where makes a concurrent use of the same code fail
+	result := db.Model(&User{}).
+		Where("id = ? AND (two_factor_last_step IS NULL OR two_factor_last_step < ?)", u.ID, step).
+		Update("two_factor_last_step", step)
+	if result.Error != nil || result.RowsAffected != 1 {
+		return false
+	}
+	u.TwoFactorLastStep = &step
+	return true
+}
+
+// enables two factor and returns fresh backup codes, only their hashes are stored
+func (u *User) EnableTwoFactor() ([]string, error) {
+	codes, hashes, err := generateBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+	u.TwoFactorEnabled = true
+	u.TwoFactorBackupCodes = &hashes
+	err = db.Model(u).Updates(map[string]interface{}{
+		"two_factor_enabled":      true,
+		"two_factor_backup_codes": hashes,
+		"updated_at":              time.Now(),
+	}).Error
+	return codes, err
+}
+
+func (u *User) RegenerateBackupCodes() ([]string, error) {
+	codes, hashes, err := generateBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+	u.TwoFactorBackupCodes = &hashes
+	err = db.Model(u).Updates(map[string]interface{}{
+		"two_factor_backup_codes": hashes,
+		"updated_at":              time.Now(),
+	}).Error
+	return codes, err
+}
+
+// checks a backup code and removes it, so every code works once
+func (u *User) ConsumeBackupCode(code string) (bool, error) {
+	if u.TwoFactorBackupCodes == nil || *u.TwoFactorBackupCodes == "" {
+		return false, nil
+	}
+	var hashes []string
+	if err := json.Unmarshal([]byte(*u.TwoFactorBackupCodes), &hashes); err != nil {
+		return false, err
+	}
+
+	hash := hashBackupCode(code)
+	for i, h := range hashes {
+		if h != hash {
+			continue
+		}
+		remaining := append(hashes[:i:i], hashes[i+1:]...)
+		data, err := json.Marshal(remaining)
+		if err != nil {
+			return false, err
+		}
+		encoded := string(data)
+		// the where on the old value makes a concurrent use of the same code fail
+		result := db.Model(&User{}).
+			Where("id = ? AND two_factor_backup_codes = ?", u.ID, *u.TwoFactorBackupCodes).
+			Update("two_factor_backup_codes", encoded)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		u.TwoFactorBackupCodes = &encoded
+		return result.RowsAffected == 1, nil
+	}
+	return false, nil
+}
+
+func (u *User) RemainingBackupCodes() int {
+	if u.TwoFactorBackupCodes == nil || *u.TwoFactorBackupCodes == "" {
+		return 0
+	}
+	var hashes []string
+	if err := json.Unmarshal([]byte(*u.TwoFactorBackupCodes), &hashes); err != nil {
+		return 0
+	}
+	return len(hashes)
+}
+
+// turns two factor off and forgets the secret and backup codes, used for disabling and admin resets
+func DisableTwoFactor(userID int64) error {
+	return db.Model(&User{ID: userID}).Updates(map[string]interface{}{
+		"two_factor_enabled":      false,
+		"two_factor_secret":       nil,
+		"two_factor_backup_codes": nil,
+		"two_factor_last_step":    nil,
+		"updated_at":              time.Now(),
+	}).Error
+}
+
+func generateBackupCodes() ([]string, string, error) {
+	codes := make([]string, 0, backupCodeCount)
+	hashes := make([]string, 0, backupCodeCount)
+	for range backupCodeCount {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, "", err
+		}
+		raw := hex.EncodeToString(b)
+		code := raw[:5] + "-" + raw[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashBackupCode(code))
+	}
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return nil, "", err
+	}
+	return codes, string(data), nil
+}
+
+// backup codes are random, so a plain sha256 is enough, unlike passwords they can't be guessed from a dictionary
+func hashBackupCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
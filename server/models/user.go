@@ -30,6 +30,7 @@ type User struct {
 	TwoFactorEnabled     bool    `gorm:"default:false" json:"twoFactorEnabled"`
 	TwoFactorSecret      *string `json:"-"`
 	TwoFactorBackupCodes *string `json:"-"`
+	TwoFactorLastStep    *int64  `json:"-"`
 
 	LastLoginAt         *time.Time `json:"lastLoginAt,omitempty"`
 	LastLoginIP         *string    `json:"lastLoginIp,omitempty"`
@@ -138,6 +139,12 @@ func GetAllUsers() ([]User, error) {
 	return users, err
 }
 
+func GetActiveUserIDsByRole(roles ...string) ([]int64, error) {
+	var userIDs []int64
+	err := db.Model(&User{}).Where("role IN ? AND is_active = ?", roles, true).Pluck("id", &userIDs).Error
+	return userIDs, err
+}
+
 func GetUserRole(userID int64) (string, error) {
 	var role string
 	err := db.Model(&User{}).
@@ -157,6 +164,61 @@ func GetUserCount() (int64, error) {
 	return count, err
 }
 
+// failed logins allowed before the account gets locked, every further failure doubles the lock
+const maxFailedLoginAttempts = 5
+
+const (
+	baseLockoutDuration = time.Minute
+	maxLockoutDuration  = 24 * time.Hour
+)
+
+func (u *User) IsLocked() bool {
+	return u.AccountLockedUntil != nil && u.AccountLockedUntil.After(time.Now())
+}
+
+// counts a failed password or two factor attempt, returns the lock end when this attempt locked the account
+func (u *User) RecordFailedLogin() (*time.Time, error) {
+	u.FailedLoginAttempts++
+	updates := map[string]interface{}{
+		"failed_login_attempts": gorm.Expr("failed_login_attempts + ?", 1),
+	}
+
+	var lockedUntil *time.Time
+	if u.FailedLoginAttempts >= maxFailedLoginAttempts {
+		duration := maxLockoutDuration
+		if shift := u.FailedLoginAttempts - maxFailedLoginAttempts; shift < 11 {
+			duration = min(baseLockoutDuration<<shift, maxLockoutDuration)
+		}
+		until := time.Now().Add(duration)
+		lockedUntil = &until
+		u.AccountLockedUntil = lockedUntil
+		updates["account_locked_until"] = until
+	}
+
+	return lockedUntil, db.Model(&User{ID: u.ID}).Updates(updates).Error
+}
+
+func (u *User) RecordSuccessfulLogin(ip string) error {
+	now := time.Now()
+	u.FailedLoginAttempts = 0
+	u.AccountLockedUntil = nil
+	u.LastLoginAt = &now
+	u.LastLoginIP = &ip
+	return db.Model(&User{ID: u.ID}).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"account_locked_until":  nil,
+		"last_login_at":         now,
+		"last_login_ip":         ip,
+	}).Error
+}
+
+func UnlockUser(userID int64) error {
+	return db.Model(&User{ID: userID}).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"account_locked_until":  nil,
+	}).Error
+}
+
 //##############################################################################################################
 //ARCHIVED CODE BELOW
 
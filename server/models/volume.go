@@ -46,6 +46,12 @@ func GetVolumeByID(id int64) (*Volume, error) {
 	return &vol, nil
 }
 
+func GetAppIDByVolumeID(id int64) (int64, error) {
+	var vol Volume
+	err := db.Select("app_id").First(&vol, id).Error
+	return vol.AppID, err
+}
+
 func CreateVolume(appID int64, name, hostPath, containerPath string, readOnly bool) (*Volume, error) {
 	vol := &Volume{
 		AppID:         appID,
@@ -0,0 +1,210 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/corecollectives/mist/models"
+)
+
+var ErrPrivateTarget = errors.New("notifications can't be sent to loopback, link-local or private addresses")
+
+// the address is checked when connecting, after dns resolution, so a host that resolves
+// to a public address when the channel is saved can't be pointed at the internal network later
+var httpClient = &http.Client{
+	Timeout: deliveryTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+					return ErrPrivateTarget
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+// carrier-grade NAT range, not covered by net.IP.IsPrivate
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified() && !sharedAddressSpace.Contains(ip)
+}
+
+// resolves the host of a channel url and rejects it unless all of its addresses are public
+func ValidateTargetURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return fmt.Errorf("invalid url")
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return ErrPrivateTarget
+		}
+	}
+	return nil
+}
+
+// sends the payload to the channel, deliveryID is sent along with webhooks so receivers can
+// drop retries they already handled
+func Deliver(ctx context.Context, channel *models.NotificationChannel, payload *Payload, deliveryID int64) error {
+	switch channel.Type {
+	case models.ChannelTypeSlack:
+		return sendSlack(ctx, channel, payload)
+	case models.ChannelTypeDiscord:
+		return sendDiscord(ctx, channel, payload)
+	case models.ChannelTypeWebhook:
+		return sendWebhook(ctx, channel, payload, deliveryID)
+	case models.ChannelTypeEmail:
+		return sendEmail(channel, payload)
+	default:
+		return fmt.Errorf("unsupported channel type: %s", channel.Type)
+	}
+}
+
+// incoming webhook of a slack app
+func sendSlack(ctx context.Context, channel *models.NotificationChannel, payload *Payload) error {
+	url, err := channel.DecryptedURL()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"text": payload.Title,
+		"attachments": []map[string]interface{}{{
+			"color":  priorityColor(payload.Priority),
+			"title":  payload.Title,
+			"text":   payload.Message,
+			"footer": "Mist",
+			"ts":     payload.Timestamp.Unix(),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	return post(ctx, url, body, nil)
+}
+
+func sendDiscord(ctx context.Context, channel *models.NotificationChannel, payload *Payload) error {
+	url, err := channel.DecryptedURL()
+	if err != nil {
+		return err
+	}
+	color, _ := strconv.ParseInt(strings.TrimPrefix(priorityColor(payload.Priority), "#"), 16, 64)
+	body, err := json.Marshal(map[string]interface{}{
+		"username": "Mist",
+		"embeds": []map[string]interface{}{{
+			"title":       payload.Title,
+			"description": payload.Message,
+			"color":       color,
+			"timestamp":   payload.Timestamp.Format(time.RFC3339),
+			"footer":      map[string]string{"text": "Mist"},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	return post(ctx, url, body, nil)
+}
+
+// posts the payload as json. with a secret the request carries
+// X-Mist-Signature: sha256=hex(hmac_sha256(secret, timestamp + "." + body))
+// where timestamp is the X-Mist-Timestamp header, so receivers can reject replays
+func sendWebhook(ctx context.Context, channel *models.NotificationChannel, payload *Payload, deliveryID int64) error {
+	url, err := channel.DecryptedURL()
+	if err != nil {
+		return err
+	}
+	secret, err := channel.DecryptedSecret()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	headers := map[string]string{
+		"X-Mist-Event":     string(payload.Event),
+		"X-Mist-Delivery":  strconv.FormatInt(deliveryID, 10),
+		"X-Mist-Timestamp": timestamp,
+	}
+	if secret != "" {
+		headers["X-Mist-Signature"] = "sha256=" + Sign(secret, timestamp, body)
+	}
+	return post(ctx, url, body, headers)
+}
+
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func post(ctx context.Context, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Mist-Notifications")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrPrivateTarget) {
+			return ErrPrivateTarget
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	// the response body isn't returned, test deliveries would otherwise show what internal services answer
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("request failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func priorityColor(priority models.NotificationPriority) string {
+	switch priority {
+	case models.PriorityUrgent:
+		return "#dc2626"
+	case models.PriorityHigh:
+		return "#ea580c"
+	case models.PriorityLow:
+		return "#6b7280"
+	default:
+		return "#2563eb"
+	}
+}
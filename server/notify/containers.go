@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/corecollectives/mist/models"
+	"github.com/moby/moby/client"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// a container that dies this soon after being killed was stopped on purpose
+	killGracePeriod = time.Minute
+	// crash loops are only reported once per app in this window
+	crashThrottle  = 10 * time.Minute
+	reconnectDelay = 10 * time.Second
+)
+
+var appContainerName = regexp.MustCompile(`^app-(\d+)$`)
+
+// watches docker events for app containers that exit with an error or get killed by the
+// oom killer. stops, restarts and redeploys send a kill first and are not reported
+func WatchContainers() {
+	go func() {
+		w := &containerWatcher{
+			killed:   map[string]time.Time{},
+			reported: map[int64]time.Time{},
+		}
+		for {
+			if err := w.watch(); err != nil {
+				log.Warn().Err(err).Msg("Container event stream ended, reconnecting")
+			}
+			time.Sleep(reconnectDelay)
+		}
+	}()
+}
+
+type containerWatcher struct {
+	mu       sync.Mutex
+	killed   map[string]time.Time
+	reported map[int64]time.Time
+}
+
+func (w *containerWatcher) watch() error {
+	cli, err := client.New(client.FromEnv)
+	if err != nil {
+		return fmt.Errorf("error creating moby client: %s", err.Error())
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	filters := make(client.Filters)
+	filters.Add("type", "container")
+	filters.Add("event", "kill")
+	filters.Add("event", "die")
+	filters.Add("event", "oom")
+	events := cli.Events(ctx, client.EventsListOptions{Filters: filters})
+
+	for {
+		select {
+		case msg, ok := <-events.Messages:
+			if !ok {
+				return nil
+			}
+			w.handle(string(msg.Action), msg.Actor.ID, msg.Actor.Attributes)
+		case err := <-events.Err:
+			return err
+		}
+	}
+}
+
+func (w *containerWatcher) handle(action, containerID string, attributes map[string]string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	for id, at := range w.killed {
+		if now.Sub(at) > killGracePeriod {
+			delete(w.killed, id)
+		}
+	}
+
+	match := appContainerName.FindStringSubmatch(attributes["name"])
+	if match == nil {
+		return
+	}
+	appID, _ := strconv.ParseInt(match[1], 10, 64)
+
+	var message string
+	switch action {
+	case "kill":
+		w.killed[containerID] = now
+		return
+	case "oom":
+		message = "The container ran out of memory and was killed. Consider raising its memory limit."
+	case "die":
+		if _, ok := w.killed[containerID]; ok {
+			delete(w.killed, containerID)
+			return
+		}
+		exitCode := attributes["exitCode"]
+		if exitCode == "" || exitCode == "0" {
+			return
+		}
+		message = fmt.Sprintf("The container exited unexpectedly with code %s.", exitCode)
+	default:
+		return
+	}
+
+	if at, ok := w.reported[appID]; ok && now.Sub(at) < crashThrottle {
+		return
+	}
+	w.reported[appID] = now
+
+	app, err := models.GetApplicationByID(appID)
+	if err != nil || app == nil {
+		return
+	}
+	log.Warn().Int64("app_id", appID).Str("event", action).Msg("App container crashed")
+	Send(AppEvent(app, models.NotificationAppError, models.PriorityUrgent,
+		fmt.Sprintf("%s crashed", app.Name), message))
+}
@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/utils"
+)
+
+const smtpTimeout = 30 * time.Second
+
+var ErrSMTPNotConfigured = errors.New("smtp is not configured")
+
+func sendEmail(channel *models.NotificationChannel, payload *Payload) error {
+	settings, err := models.GetSMTPSettings()
+	if err != nil {
+		return fmt.Errorf("failed to load smtp settings: %w", err)
+	}
+	body := payload.Message
+	if payload.Link != "" {
+		body += "\n\n" + payload.Link
+	}
+	return SendMail(settings, channel.Email, "[Mist] "+payload.Title, body)
+}
+
+// sends a plain text mail through the configured server
+func SendMail(settings *models.SMTPSettings, to, subject, body string) error {
+	if !settings.Configured() {
+		return ErrSMTPNotConfigured
+	}
+	from, err := mail.ParseAddress(settings.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+	rcpt, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	addr := net.JoinHostPort(settings.Host, strconv.Itoa(settings.Port))
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	tlsConfig := &tls.Config{ServerName: settings.Host}
+
+	var conn net.Conn
+	if settings.Security == models.SMTPSecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := smtp.NewClient(conn, settings.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+	defer client.Close()
+
+	if settings.Security == models.SMTPSecurityStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starttls failed: %w", err)
+		}
+	}
+	if settings.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", settings.Username, settings.Password, settings.Host)); err != nil {
+			return fmt.Errorf("smtp authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp server rejected sender: %w", err)
+	}
+	if err := client.Rcpt(rcpt.Address); err != nil {
+		return fmt.Errorf("smtp server rejected recipient: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	var msg strings.Builder
+	msg.WriteString("From: " + from.String() + "\r\n")
+	msg.WriteString("To: " + rcpt.String() + "\r\n")
+	msg.WriteString("Subject: " + mime.QEncoding.Encode("UTF-8", strings.NewReplacer("\r", "", "\n", " ").Replace(subject)) + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString(fmt.Sprintf("Message-ID: <%d@%s>\r\n", utils.GenerateRandomId(), domainOf(from.Address)))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	if _, err := w.Write([]byte(msg.String())); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return client.Quit()
+}
+
+func domainOf(address string) string {
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		return address[i+1:]
+	}
+	return "mist"
+}
@@ -0,0 +1,183 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/corecollectives/mist/models"
+	"github.com/rs/zerolog/log"
+)
+
+// in-app notifications are removed after this long
+const notificationTTL = 30 * 24 * time.Hour
+
+// something users are told about. events of a project go to its members and channels,
+// events without a project go to owners and admins
+type Event struct {
+	Type     models.NotificationType
+	Title    string
+	Message  string
+	Priority models.NotificationPriority
+
+	ProjectID    *int64
+	ResourceType string
+	ResourceID   *int64
+	// dashboard path, e.g. /projects/1/apps/2
+	Link     string
+	Metadata map[string]interface{}
+}
+
+// what channels receive, also the body of webhook requests
+type Payload struct {
+	Event        models.NotificationType     `json:"event"`
+	Title        string                      `json:"title"`
+	Message      string                      `json:"message"`
+	Priority     models.NotificationPriority `json:"priority"`
+	ProjectID    *int64                      `json:"projectId,omitempty"`
+	ResourceType string                      `json:"resourceType,omitempty"`
+	ResourceID   *int64                      `json:"resourceId,omitempty"`
+	Link         string                      `json:"link,omitempty"`
+	Metadata     map[string]interface{}      `json:"metadata,omitempty"`
+	Timestamp    time.Time                   `json:"timestamp"`
+}
+
+// event about an app, the app's project is notified
+func AppEvent(app *models.App, eventType models.NotificationType, priority models.NotificationPriority, title, message string) Event {
+	return Event{
+		Type:         eventType,
+		Title:        title,
+		Message:      message,
+		Priority:     priority,
+		ProjectID:    &app.ProjectID,
+		ResourceType: "app",
+		ResourceID:   &app.ID,
+		Link:         fmt.Sprintf("/projects/%d/apps/%d", app.ProjectID, app.ID),
+		Metadata: map[string]interface{}{
+			"appId":   app.ID,
+			"appName": app.Name,
+		},
+	}
+}
+
+// event about mist itself, owners and admins are notified
+func SystemEvent(eventType models.NotificationType, priority models.NotificationPriority, title, message, link string) Event {
+	return Event{
+		Type:     eventType,
+		Title:    title,
+		Message:  message,
+		Priority: priority,
+		Link:     link,
+	}
+}
+
+// records the event and queues it for its channels in the background, callers are never
+// slowed down or failed by notifications
+func Send(event Event) {
+	go func() {
+		if err := send(event); err != nil {
+			log.Error().Err(err).Str("type", string(event.Type)).Msg("Failed to send notification")
+		}
+	}()
+}
+
+func send(event Event) error {
+	if event.Priority == "" {
+		event.Priority = models.PriorityNormal
+	}
+
+	var recipients []int64
+	var err error
+	if event.ProjectID != nil {
+		recipients, err = models.GetProjectMemberIDs(*event.ProjectID)
+	} else {
+		recipients, err = models.GetActiveUserIDsByRole("owner", "admin")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get recipients: %w", err)
+	}
+
+	var metadata *string
+	if len(event.Metadata) > 0 {
+		if data, err := json.Marshal(event.Metadata); err == nil {
+			value := string(data)
+			metadata = &value
+		}
+	}
+	var link, resourceType *string
+	if event.Link != "" {
+		link = &event.Link
+	}
+	if event.ResourceType != "" {
+		resourceType = &event.ResourceType
+	}
+
+	expiresAt := time.Now().Add(notificationTTL)
+	notificationIDs := map[int64]int64{}
+	for _, userID := range recipients {
+		n := &models.Notification{
+			UserID:       &userID,
+			Type:         event.Type,
+			Title:        event.Title,
+			Message:      event.Message,
+			Link:         link,
+			ResourceType: resourceType,
+			ResourceID:   event.ResourceID,
+			Priority:     event.Priority,
+			Metadata:     metadata,
+			ExpiresAt:    &expiresAt,
+		}
+		if err := n.InsertInDB(); err != nil {
+			log.Warn().Err(err).Int64("user_id", userID).Msg("Failed to create notification")
+			continue
+		}
+		notificationIDs[userID] = n.ID
+	}
+
+	channels, err := models.GetNotificationChannelsFor(event.ProjectID, recipients)
+	if err != nil {
+		return fmt.Errorf("failed to get notification channels: %w", err)
+	}
+
+	payload, err := json.Marshal(Payload{
+		Event:        event.Type,
+		Title:        event.Title,
+		Message:      event.Message,
+		Priority:     event.Priority,
+		ProjectID:    event.ProjectID,
+		ResourceType: event.ResourceType,
+		ResourceID:   event.ResourceID,
+		Link:         event.Link,
+		Metadata:     event.Metadata,
+		Timestamp:    time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	var deliveries []models.NotificationDelivery
+	for _, channel := range channels {
+		if !channel.Subscribes(event.Type) {
+			continue
+		}
+		delivery := models.NotificationDelivery{
+			ChannelID:   channel.ID,
+			ChannelType: channel.Type,
+			EventType:   event.Type,
+			Payload:     string(payload),
+		}
+		if channel.UserID != nil {
+			if id, ok := notificationIDs[*channel.UserID]; ok {
+				delivery.NotificationID = &id
+			}
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := models.CreateNotificationDeliveries(deliveries); err != nil {
+		return fmt.Errorf("failed to queue notification deliveries: %w", err)
+	}
+	if len(deliveries) > 0 {
+		wakeWorker()
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/corecollectives/mist/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	workerInterval  = 30 * time.Second
+	cleanupInterval = 24 * time.Hour
+	deliveryTimeout = 30 * time.Second
+	deliveryBatch   = 50
+)
+
+// wait before each retry, a delivery is given up after the last one
+var retryBackoff = []time.Duration{
+	30 * time.Second,
+	2 * time.Minute,
+	10 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+}
+
+var wake = make(chan struct{}, 1)
+
+func wakeWorker() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// delivers queued notifications and retries failed ones. pending deliveries are kept in
+// the db, so retries survive restarts
+func StartWorker() {
+	go func() {
+		ticker := time.NewTicker(workerInterval)
+		defer ticker.Stop()
+		lastCleanup := time.Time{}
+		for {
+			deliverDue()
+			if time.Since(lastCleanup) > cleanupInterval {
+				cleanup()
+				lastCleanup = time.Now()
+			}
+			select {
+			case <-ticker.C:
+			case <-wake:
+			}
+		}
+	}()
+}
+
+func deliverDue() {
+	for {
+		deliveries, err := models.GetDueNotificationDeliveries(time.Now(), deliveryBatch)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to get pending notification deliveries")
+			return
+		}
+		for i := range deliveries {
+			deliver(&deliveries[i])
+		}
+		if len(deliveries) < deliveryBatch {
+			return
+		}
+	}
+}
+
+func deliver(d *models.NotificationDelivery) {
+	err := attempt(d)
+	if err == nil {
+		if err := d.MarkAsSent(); err != nil {
+			log.Error().Err(err).Int64("delivery_id", d.ID).Msg("Failed to mark notification as delivered")
+		}
+		return
+	}
+
+	var nextAttempt *time.Time
+	if d.Attempts < len(retryBackoff) {
+		next := time.Now().Add(retryBackoff[d.Attempts])
+		nextAttempt = &next
+	}
+	log.Warn().Err(err).
+		Int64("delivery_id", d.ID).
+		Int64("channel_id", d.ChannelID).
+		Int("attempt", d.Attempts+1).
+		Bool("retrying", nextAttempt != nil).
+		Msg("Failed to deliver notification")
+	if err := d.MarkAttemptFailed(err.Error(), nextAttempt); err != nil {
+		log.Error().Err(err).Int64("delivery_id", d.ID).Msg("Failed to record failed notification delivery")
+	}
+}
+
+func attempt(d *models.NotificationDelivery) error {
+	channel, err := models.GetNotificationChannelByID(d.ChannelID)
+	if err != nil {
+		return fmt.Errorf("failed to get channel: %w", err)
+	}
+	if channel == nil {
+		return fmt.Errorf("channel was deleted")
+	}
+	if !channel.Enabled {
+		return fmt.Errorf("channel is disabled")
+	}
+
+	var payload Payload
+	if err := json.Unmarshal([]byte(d.Payload), &payload); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+	defer cancel()
+	return Deliver(ctx, channel, &payload, d.ID)
+}
+
+func cleanup() {
+	if err := models.DeleteExpiredNotifications(); err != nil {
+		log.Warn().Err(err).Msg("Failed to delete expired notifications")
+	}
+	if err := models.DeleteOldNotificationDeliveries(); err != nil {
+		log.Warn().Err(err).Msg("Failed to delete old notification deliveries")
+	}
+}
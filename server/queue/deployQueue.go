@@ -2,31 +2,72 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/corecollectives/mist/models"
 	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
 )
 
+const MaxWorkers = 16
+
+// cause of a running deployment's context when a user cancelled it, a closing queue cancels
+// without one
+var errCancelled = errors.New("deployment cancelled")
+
+type Job struct {
+	DeploymentID int64     `json:"deploymentId"`
+	AppID        int64     `json:"appId"`
+	ProjectID    int64     `json:"projectId"`
+	Position     int64     `json:"position"`
+	QueuedAt     time.Time `json:"queuedAt"`
+}
+
+type RunningJob struct {
+	Job
+	WorkerID  int       `json:"workerId"`
+	StartedAt time.Time `json:"startedAt"`
+
+	cancel context.CancelCauseFunc
+}
+
+type QueueStatus struct {
+	Workers int          `json:"workers"`
+	Queued  []Job        `json:"queued"`
+	Running []RunningJob `json:"running"`
+}
+
 type Queue struct {
-	jobs   chan int64
-	ctx    context.Context
-	cancel context.CancelFunc
-	wg     sync.WaitGroup
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []Job
+	running map[int64]*RunningJob
+	workers int
+	alive   map[int]bool
+	closed  bool
+	db      *gorm.DB
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
 }
 
 var queue *Queue
 
-func NewQueue(buffer int, db *gorm.DB) *Queue {
+func NewQueue(workers int, db *gorm.DB) *Queue {
 	ctx, cancel := context.WithCancel(context.Background())
 	q := &Queue{
-		jobs: make(chan int64, buffer),
-
-		ctx:    ctx,
-		cancel: cancel,
+		running: make(map[int64]*RunningJob),
+		alive:   make(map[int]bool),
+		db:      db,
+		ctx:     ctx,
+		cancel:  cancel,
 	}
-	q.StartWorker(db)
+	q.cond = sync.NewCond(&q.mu)
+	q.SetWorkers(workers)
 	queue = q
 	return q
 
@@ -36,31 +77,274 @@ func GetQueue() *Queue {
 	return queue
 }
 
-func (q *Queue) StartWorker(db *gorm.DB) {
+// resizes the worker pool, extra workers exit once they finish their current job
+func (q *Queue) SetWorkers(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > MaxWorkers {
+		workers = MaxWorkers
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.workers = workers
+	for id := 1; id <= workers; id++ {
+		if !q.alive[id] {
+			q.alive[id] = true
+			q.StartWorker(id)
+		}
+	}
+	q.cond.Broadcast()
+	log.Info().Int("workers", workers).Msg("Deployment queue workers configured")
+}
+
+func (q *Queue) StartWorker(workerID int) {
 	q.wg.Add(1)
 	go func() {
 		defer q.wg.Done()
-		for id := range q.jobs {
-			q.HandleWork(id, db)
+		for {
+			job, ctx, ok := q.next(workerID)
+			if !ok {
+				return
+			}
+			if q.stillPending(job.DeploymentID) {
+				q.HandleWork(ctx, job.DeploymentID, q.db)
+			}
+			q.finish(job.DeploymentID)
 		}
-
 	}()
 }
 
+// blocks until there is a job this worker may run, returns false when the worker should exit
+func (q *Queue) next(workerID int) (Job, context.Context, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if q.closed || workerID > q.workers {
+			delete(q.alive, workerID)
+			return Job{}, nil, false
+		}
+		if idx := q.pickLocked(); idx >= 0 {
+			job := q.pending[idx]
+			q.pending = append(q.pending[:idx], q.pending[idx+1:]...)
+			ctx, cancel := context.WithCancelCause(q.ctx)
+			q.running[job.DeploymentID] = &RunningJob{
+				Job:       job,
+				WorkerID:  workerID,
+				StartedAt: time.Now(),
+				cancel:    cancel,
+			}
+			return job, ctx, true
+		}
+		q.cond.Wait()
+	}
+}
+
+// picks the oldest job of the project with the fewest running deployments,
+// skipping apps that already have a deployment running
+func (q *Queue) pickLocked() int {
+	runningApps := make(map[int64]bool)
+	runningPerProject := make(map[int64]int)
+	for _, r := range q.running {
+		runningApps[r.AppID] = true
+		runningPerProject[r.ProjectID]++
+	}
+
+	best := -1
+	for i, job := range q.pending {
+		if runningApps[job.AppID] {
+			continue
+		}
+		if best == -1 || runningPerProject[job.ProjectID] < runningPerProject[q.pending[best].ProjectID] {
+			best = i
+		}
+	}
+	return best
+}
+
+// the deployments table is the source of truth, jobs whose deployment left the
+// pending state while waiting (cancelled, deleted) are dropped
+func (q *Queue) stillPending(depID int64) bool {
+	dep, err := models.GetDeploymentByID(depID)
+	if err != nil {
+		log.Warn().Err(err).Int64("deployment_id", depID).Msg("Dropping queued job, deployment not found")
+		return false
+	}
+	if dep.Status != models.DeploymentStatusPending {
+		log.Info().Int64("deployment_id", depID).Str("status", string(dep.Status)).Msg("Skipping queued job, deployment is no longer pending")
+		return false
+	}
+	return true
+}
+
+func (q *Queue) finish(depID int64) {
+	q.mu.Lock()
+	if r, ok := q.running[depID]; ok {
+		r.cancel(nil)
+		delete(q.running, depID)
+	}
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
 func (q *Queue) AddJob(Id int64) error {
-	select {
-	case q.jobs <- Id:
-		return nil
-	case <-q.ctx.Done():
+	job, err := newJob(Id)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
 		return fmt.Errorf("queue is closed")
-	default:
-		return fmt.Errorf("queue is full")
 	}
+	position, err := models.EnqueueDeployment(Id)
+	if err != nil {
+		return fmt.Errorf("failed to persist queue position: %w", err)
+	}
+	job.Position = position
+	q.pending = append(q.pending, job)
+	q.cond.Broadcast()
+	return nil
+}
+
+func newJob(Id int64) (Job, error) {
+	job := Job{DeploymentID: Id, QueuedAt: time.Now()}
+	appID, err := models.GetAppIDByDeploymentID(Id)
+	if err != nil {
+		return job, fmt.Errorf("failed to get app for deployment: %w", err)
+	}
+	job.AppID = appID
+	if app, err := models.GetApplicationByID(appID); err == nil {
+		job.ProjectID = app.ProjectID
+	}
+	return job, nil
+}
+
+// re-queues the pending deployments from the database in their persisted order, used on startup
+func (q *Queue) LoadPending() (int, error) {
+	deployments, err := models.GetQueuedDeployments()
+	if err != nil {
+		return 0, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	queued := make(map[int64]bool)
+	for _, job := range q.pending {
+		queued[job.DeploymentID] = true
+	}
+	for id := range q.running {
+		queued[id] = true
+	}
+
+	count := 0
+	for _, dep := range deployments {
+		if queued[dep.ID] {
+			continue
+		}
+		job, err := newJob(dep.ID)
+		if err != nil {
+			log.Warn().Err(err).Int64("deployment_id", dep.ID).Msg("Skipping pending deployment")
+			continue
+		}
+		job.Position = dep.QueuePosition
+		job.QueuedAt = dep.CreatedAt
+		q.pending = append(q.pending, job)
+		count++
+	}
+	q.cond.Broadcast()
+	return count, nil
+}
+
+// moves the given queued deployments to the front in the given order,
+// the remaining queued deployments keep their relative order behind them
+func (q *Queue) Reorder(depIDs []int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	byID := make(map[int64]Job, len(q.pending))
+	for _, job := range q.pending {
+		byID[job.DeploymentID] = job
+	}
+
+	reordered := make([]Job, 0, len(q.pending))
+	seen := make(map[int64]bool, len(depIDs))
+	for _, id := range depIDs {
+		job, ok := byID[id]
+		if !ok {
+			return fmt.Errorf("deployment %d is not queued", id)
+		}
+		if seen[id] {
+			return fmt.Errorf("deployment %d is listed more than once", id)
+		}
+		seen[id] = true
+		reordered = append(reordered, job)
+	}
+	for _, job := range q.pending {
+		if !seen[job.DeploymentID] {
+			reordered = append(reordered, job)
+		}
+	}
+
+	ids := make([]int64, len(reordered))
+	for i := range reordered {
+		ids[i] = reordered[i].DeploymentID
+		reordered[i].Position = int64(i + 1)
+	}
+	if err := models.ReorderQueuedDeployments(ids); err != nil {
+		return fmt.Errorf("failed to persist queue order: %w", err)
+	}
+
+	q.pending = reordered
+	return nil
+}
+
+// removes a queued job or cancels the context of a running one.
+// returns whether the job was still waiting in the queue
+func (q *Queue) Cancel(depID int64) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, job := range q.pending {
+		if job.DeploymentID == depID {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			return true, nil
+		}
+	}
+	if r, ok := q.running[depID]; ok {
+		r.cancel(errCancelled)
+		return false, nil
+	}
+	return false, fmt.Errorf("deployment %d is not queued or running", depID)
+}
+
+func (q *Queue) Status() QueueStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	status := QueueStatus{
+		Workers: q.workers,
+		Queued:  append([]Job{}, q.pending...),
+		Running: []RunningJob{},
+	}
+	for _, r := range q.running {
+		status.Running = append(status.Running, *r)
+	}
+	sort.Slice(status.Running, func(i, j int) bool {
+		return status.Running[i].WorkerID < status.Running[j].WorkerID
+	})
+	return status
 }
 
+// stops the workers, running deployments are interrupted and queued again for the next start
 func (q *Queue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
 	q.cancel()
-	close(q.jobs)
+	q.cond.Broadcast()
 	q.wg.Wait()
 	log.Info().Msg("Deployment queue closed")
 }
@@ -1,25 +1,30 @@
 package queue
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"sync"
 
 	"github.com/corecollectives/mist/docker"
 	"github.com/corecollectives/mist/fs"
 	"github.com/corecollectives/mist/github"
 	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/notify"
 	"github.com/corecollectives/mist/utils"
 	"gorm.io/gorm"
 )
 
 // to prevent concurrent deployments of same app
 // two deployments of same app shouldn't be happening at the same time to prevent race conditions
-// currently its impossible, bcz we only support one deployment at a time,
-// but future plans include configuratble concurrent deployments
-// then this will be helpful
+// the queue already skips apps with a running deployment when picking the next job,
+// this is the last line of defence when multiple workers are configured
 var deploymentLocks sync.Map
 
-func (q *Queue) HandleWork(id int64, db *gorm.DB) {
+func (q *Queue) HandleWork(ctx context.Context, id int64, db *gorm.DB) {
+	// registered first so it runs last, after a panic has been recorded as failure
+	defer notifyResult(id)
 	defer func() {
 		if r := recover(); r != nil {
 			errMsg := fmt.Sprintf("panic during deployment: %v", r)
@@ -75,11 +80,31 @@ func (q *Queue) HandleWork(id int64, db *gorm.DB) {
 	}
 	defer logFile.Close()
 
-	if app.AppType != models.AppTypeDatabase {
+	if dep.RolledBackFrom != nil {
+		logger.Info("Rollback deployment, skipping clone and build")
+		_, err = docker.RollbackMain(ctx, id, db, logFile, logger)
+		if err != nil && interrupted(ctx, id, logFile, logger) {
+			return
+		}
+		if err != nil {
+			logger.Error(err, "Rollback failed")
+			errMsg := fmt.Sprintf("Rollback failed: %v", err)
+			models.UpdateDeploymentStatus(id, "failed", "failed", 0, &errMsg)
+			return
+		}
+		logger.Info("Rollback completed successfully")
+		return
+	}
+
+	if app.AppType != models.AppTypeDatabase && !app.IsImageSource() {
 		logger.Info("Cloning repository")
-		models.UpdateDeploymentStatus(id, "cloning", "cloning", 20, nil)
+		models.UpdateDeploymentStatus(id, string(models.DeploymentStatusCloning), "cloning", 20, nil)
 
-		err = github.CloneRepo(appId, logFile)
+		// the exact commit recorded on the deployment is built, not whatever the branch points to now
+		resolvedCommit, err := github.CloneRepo(ctx, appId, dep.CommitHash, logFile)
+		if err != nil && interrupted(ctx, id, logFile, logger) {
+			return
+		}
 		if err != nil {
 			logger.Error(err, "Failed to clone repository")
 			errMsg := fmt.Sprintf("Failed to clone repository: %v", err)
@@ -87,12 +112,18 @@ func (q *Queue) HandleWork(id int64, db *gorm.DB) {
 			return
 		}
 
-		logger.Info("Repository cloned successfully")
+		logger.InfoWithFields("Repository cloned successfully", map[string]interface{}{
+			"commit": resolvedCommit,
+		})
 	} else {
-		logger.Info("Skipping git clone for database app")
+		logger.Info("Skipping git clone for database or image app")
 	}
 
-	_, err = docker.DeployerMain(id, db, logFile, logger)
+	// a deploy that finished before the cancel reached it stays successful
+	_, err = docker.DeployerMain(ctx, id, db, logFile, logger)
+	if err != nil && interrupted(ctx, id, logFile, logger) {
+		return
+	}
 	if err != nil {
 		logger.Error(err, "Deployment failed")
 		errMsg := fmt.Sprintf("Deployment failed: %v", err)
@@ -102,3 +133,67 @@ func (q *Queue) HandleWork(id int64, db *gorm.DB) {
 
 	logger.Info("Deployment completed successfully")
 }
+
+// tells the app's project how the deployment ended, cancelled deployments were stopped by a user
+func notifyResult(id int64) {
+	dep, err := models.GetDeploymentByID(id)
+	if err != nil {
+		return
+	}
+	app, err := models.GetApplicationByID(dep.AppID)
+	if err != nil || app == nil {
+		return
+	}
+
+	name := fmt.Sprintf("Deployment %d", dep.ID)
+	if dep.DeploymentNumber != nil {
+		name = fmt.Sprintf("Deployment #%d", *dep.DeploymentNumber)
+	}
+	switch dep.Status {
+	case models.DeploymentStatusSuccess:
+		notify.Send(notify.AppEvent(app, models.NotificationDeploymentSuccess, models.PriorityNormal,
+			fmt.Sprintf("%s deployed", app.Name),
+			fmt.Sprintf("%s of %s completed successfully.", name, app.Name)))
+	case models.DeploymentStatusFailed:
+		message := fmt.Sprintf("%s of %s failed.", name, app.Name)
+		if dep.ErrorMessage != nil {
+			message += " " + *dep.ErrorMessage
+		}
+		notify.Send(notify.AppEvent(app, models.NotificationDeploymentFailed, models.PriorityHigh,
+			fmt.Sprintf("%s deployment failed", app.Name), message))
+	}
+}
+
+// records why a step failed when its context was cancelled, a user cancelling the deployment or
+// the queue shutting down. returns false for ordinary failures
+func interrupted(ctx context.Context, id int64, logFile *os.File, logger *utils.DeploymentLogger) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	if errors.Is(context.Cause(ctx), errCancelled) {
+		markCancelled(id, logFile, logger)
+	} else {
+		markRequeued(id, logFile, logger)
+	}
+	return true
+}
+
+// the step that was running when the context got cancelled has already marked the deployment
+// as failed, so the final status is overwritten here. the app lock is released once HandleWork returns
+func markCancelled(id int64, logFile *os.File, logger *utils.DeploymentLogger) {
+	logger.Info("Deployment cancelled")
+	errMsg := "Deployment was cancelled"
+	if err := models.UpdateDeploymentStatus(id, string(models.DeploymentStatusCancelled), "cancelled", 0, &errMsg); err != nil {
+		logger.Error(err, "Failed to mark deployment as cancelled")
+	}
+	fmt.Fprintf(logFile, "[DEPLOY]: Deployment cancelled\n")
+}
+
+// the server is shutting down, the deployment runs again from the start once it's back
+func markRequeued(id int64, logFile *os.File, logger *utils.DeploymentLogger) {
+	logger.Info("Deployment interrupted by shutdown, re-queueing it")
+	if err := models.RequeueDeployment(id); err != nil {
+		logger.Error(err, "Failed to re-queue interrupted deployment")
+	}
+	fmt.Fprintf(logFile, "[DEPLOY]: Interrupted by server shutdown, the deployment will run again on the next start\n")
+}
@@ -1,10 +1,19 @@
 package queue
 
 import (
+	"github.com/corecollectives/mist/models"
+	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
 )
 
 func InitQueue(db *gorm.DB) *Queue {
-	q := NewQueue(5, db)
+	workers := 1
+	settings, err := models.GetSystemSettings()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to load deployment worker setting, using a single worker")
+	} else {
+		workers = settings.DeploymentWorkers
+	}
+	q := NewQueue(workers, db)
 	return q
 }
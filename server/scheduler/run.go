@@ -0,0 +1,166 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/corecollectives/mist/docker"
+	"github.com/corecollectives/mist/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultTimeout = time.Hour
+	// output kept in the run history, the tail is kept since that's where errors end up
+	maxOutput = 64 << 10
+)
+
+var (
+	ErrBusy       = errors.New("this cron job is already running")
+	ErrNoInstance = errors.New("app has no container, deploy it first")
+	ErrNotRunning = errors.New("app container is not running")
+)
+
+// one run at a time per job, a run that outlasts its interval makes the next one skip
+var running sync.Map
+
+func IsRunning(cronID int64) bool {
+	_, busy := running.Load(cronID)
+	return busy
+}
+
+type RunOptions struct {
+	Trigger     models.CronRunTrigger
+	TriggeredBy *int64
+	// receives the output as it is produced, e.g. a websocket
+	Output io.Writer
+	// called once the run record exists, before the command starts
+	OnStart func(run *models.CronRun)
+}
+
+// runs the job's command and records it in the run history. it blocks until the command
+// exits, the error is only set when the run couldn't be started, a failing command is
+// reported through the returned run's status and exit code
+func Run(c *models.Cron, opts RunOptions) (*models.CronRun, error) {
+	if !lock(c.ID) {
+		return nil, ErrBusy
+	}
+	defer unlock(c.ID)
+
+	app, err := models.GetApplicationByID(c.AppID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get application: %w", err)
+	}
+	if app == nil {
+		return nil, fmt.Errorf("application %d not found", c.AppID)
+	}
+	containerName := docker.GetContainerName(app.Name, app.ID)
+	if !docker.ContainerExists(containerName) {
+		return nil, ErrNoInstance
+	}
+	if c.RunMode != models.CronRunModeOneOff {
+		if status, err := docker.GetContainerStatus(containerName); err != nil {
+			return nil, err
+		} else if status.State != "running" {
+			return nil, ErrNotRunning
+		}
+	}
+
+	runMode := c.RunMode
+	if runMode == "" {
+		runMode = models.CronRunModeContainer
+	}
+	run := &models.CronRun{
+		CronID:      c.ID,
+		AppID:       c.AppID,
+		Trigger:     opts.Trigger,
+		TriggeredBy: opts.TriggeredBy,
+		Command:     c.Command,
+		RunMode:     runMode,
+	}
+	if err := run.InsertInDB(); err != nil {
+		return nil, fmt.Errorf("failed to record cron run: %w", err)
+	}
+	if opts.OnStart != nil {
+		opts.OnStart(run)
+	}
+
+	timeout := defaultTimeout
+	if c.Timeout > 0 {
+		timeout = time.Duration(c.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	output := docker.NewTailBuffer(maxOutput)
+	var w io.Writer = output
+	if opts.Output != nil {
+		w = io.MultiWriter(output, opts.Output)
+	}
+
+	var exitCode int
+	if runMode == models.CronRunModeOneOff {
+		labels := map[string]string{
+			"mist.app_id":  strconv.FormatInt(app.ID, 10),
+			"mist.cron_id": strconv.FormatInt(c.ID, 10),
+		}
+		exitCode, err = docker.RunOneOff(ctx, containerName, c.Command, labels, w, w)
+	} else {
+		exitCode, err = docker.Exec(ctx, containerName, []string{"sh", "-c", c.Command}, nil, w, w)
+	}
+
+	status := models.CronRunStatusSuccess
+	var code *int
+	var errMsg *string
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		status = models.CronRunStatusTimedOut
+		msg := fmt.Sprintf("Command timed out after %s", timeout)
+		errMsg = &msg
+	case err != nil:
+		status = models.CronRunStatusFailed
+		msg := err.Error()
+		errMsg = &msg
+	default:
+		code = &exitCode
+		if exitCode != 0 {
+			status = models.CronRunStatusFailed
+		}
+	}
+
+	outputText := output.String()
+	if output.Truncated() {
+		outputText = "[output truncated]\n" + outputText
+	}
+	if err := run.MarkAsFinished(status, code, outputText, errMsg); err != nil {
+		log.Error().Err(err).Int64("cron_run_id", run.ID).Msg("Failed to save cron run result")
+	}
+	if err := models.SetCronLastRun(c.ID, run.StartedAt, status); err != nil {
+		log.Error().Err(err).Int64("cron_id", c.ID).Msg("Failed to update last cron run")
+	}
+	if err := models.PruneCronRuns(c.ID); err != nil {
+		log.Warn().Err(err).Int64("cron_id", c.ID).Msg("Failed to prune cron run history")
+	}
+
+	log.Info().
+		Int64("cron_id", c.ID).
+		Int64("app_id", c.AppID).
+		Str("status", string(status)).
+		Str("trigger", string(opts.Trigger)).
+		Msg("Cron job finished")
+	return run, nil
+}
+
+func lock(cronID int64) bool {
+	_, busy := running.LoadOrStore(cronID, struct{}{})
+	return !busy
+}
+
+func unlock(cronID int64) {
+	running.Delete(cronID)
+}
@@ -0,0 +1,31 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// standard five field expressions like "*/5 * * * *" and macros like "@daily" or "@every 10m",
+// evaluated in the server's time zone
+func ParseSchedule(expr string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(strings.TrimSpace(expr))
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", expr, err)
+	}
+	return schedule, nil
+}
+
+func NextRun(expr string, after time.Time) (time.Time, error) {
+	schedule, err := ParseSchedule(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := schedule.Next(after)
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("schedule %q never runs", expr)
+	}
+	return next, nil
+}
@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/corecollectives/mist/models"
+	"github.com/rs/zerolog/log"
+)
+
+// checks for due jobs at the start of every minute. next runs are kept in the db, so
+// schedules survive restarts, runs missed while the server was down are skipped
+func Start() {
+	go func() {
+		reschedule()
+		for {
+			now := time.Now()
+			time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+			runDue(time.Now())
+		}
+	}()
+}
+
+// gives jobs without a next run one and moves runs missed during downtime to the next slot
+func reschedule() {
+	now := time.Now()
+	crons, err := models.GetUnscheduledCrons()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get unscheduled cron jobs")
+	}
+	missed, err := models.GetDueCrons(now.Add(-time.Minute))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get missed cron jobs")
+	}
+	for _, c := range append(crons, missed...) {
+		scheduleNext(&c, now)
+	}
+}
+
+func runDue(now time.Time) {
+	crons, err := models.GetDueCrons(now)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get due cron jobs")
+		return
+	}
+	for i := range crons {
+		c := crons[i]
+		// the next run is saved first, a slow or failing run must not fire twice
+		scheduleNext(&c, now)
+		go func() {
+			if _, err := Run(&c, RunOptions{Trigger: models.CronRunTriggerSchedule}); err != nil {
+				log.Warn().Err(err).Int64("cron_id", c.ID).Int64("app_id", c.AppID).Msg("Skipped scheduled cron job")
+			}
+		}()
+	}
+}
+
+func scheduleNext(c *models.Cron, after time.Time) {
+	next, err := NextRun(c.Schedule, after)
+	if err != nil {
+		log.Warn().Err(err).Int64("cron_id", c.ID).Msg("Failed to compute next cron run, job won't run until its schedule is fixed")
+		if err := models.SetCronNextRun(c.ID, nil); err != nil {
+			log.Error().Err(err).Int64("cron_id", c.ID).Msg("Failed to clear next cron run")
+		}
+		return
+	}
+	c.NextRun = &next
+	if err := models.SetCronNextRun(c.ID, &next); err != nil {
+		log.Error().Err(err).Int64("cron_id", c.ID).Msg("Failed to save next cron run")
+	}
+}
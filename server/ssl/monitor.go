@@ -0,0 +1,196 @@
+package ssl
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/notify"
+	"github.com/corecollectives/mist/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	checkInterval = time.Hour
+	// gives traefik time to start and load its certificates after a reboot
+	startupDelay = time.Minute
+	// how long let's encrypt gets to issue a certificate for a new domain before it's failed
+	issuanceGracePeriod = 15 * time.Minute
+	// traefik renews let's encrypt certificates 30 days before they expire, it's overdue
+	// once a couple of daily attempts were missed
+	renewalOverdue = 28 * 24 * time.Hour
+)
+
+// checks the certificates of all https domains once an hour
+func StartMonitor() {
+	go func() {
+		time.Sleep(startupDelay)
+		CheckCertificates()
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			CheckCertificates()
+		}
+	}()
+}
+
+// reconciles each domain's ssl status and certificate details with what traefik actually serves
+// and warns users about certificates that are about to expire
+func CheckCertificates() {
+	domains, err := models.GetSSLDomains()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get domains for certificate check")
+		return
+	}
+	if len(domains) == 0 {
+		return
+	}
+
+	settings, err := models.GetSystemSettings()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load system settings for certificate check")
+		return
+	}
+
+	// an unreadable storage only means the served certificate is all there is to go on
+	acmeCertificates, err := utils.ReadAcmeCertificates()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to read Traefik ACME storage")
+	}
+
+	for i := range domains {
+		if err := checkDomain(&domains[i], acmeCertificates, settings.SslExpiryWarningDays); err != nil {
+			log.Error().Err(err).Str("domain", domains[i].Domain).Msg("Failed to update certificate status")
+		}
+	}
+}
+
+func checkDomain(domain *models.Domain, acmeCertificates map[string][]*x509.Certificate, warningDays int) error {
+	previousStatus := domain.SslStatus
+	previousExpiry := domain.ExpiresAt
+	now := time.Now()
+
+	var leaf *x509.Certificate
+	switch domain.SslProvider {
+	case models.SSLProviderCustom:
+		if domain.CertificateData != nil {
+			leaf, _ = utils.ParseCertificatePEM(*domain.CertificateData)
+		}
+	default:
+		var resolver string
+		leaf, resolver = latestCertificateFor(domain.Domain, acmeCertificates)
+		if resolver == utils.DNSCertResolver {
+			domain.AcmeChallengeType = models.AcmeChallengeTypeDns01
+		} else if leaf != nil {
+			domain.AcmeChallengeType = models.AcmeChallengeTypeHttp01
+		}
+	}
+
+	served, probeErr := utils.ProbeCertificate(domain.Domain)
+	if leaf == nil && probeErr == nil {
+		leaf = served
+	}
+
+	if leaf == nil {
+		if domain.SslProvider != models.SSLProviderCustom && now.Sub(domain.UpdatedAt) < issuanceGracePeriod {
+			return nil
+		}
+		reason := "no certificate has been issued for this domain"
+		if probeErr != nil {
+			reason = probeErr.Error()
+		}
+		domain.SslStatus = models.SSLStatusFailed
+		domain.RenewalError = &reason
+		return domain.UpdateCertificateStatus()
+	}
+
+	issuer := leaf.Issuer.String()
+	domain.Issuer = &issuer
+	domain.IssuedAt = &leaf.NotBefore
+	domain.ExpiresAt = &leaf.NotAfter
+	if previousExpiry == nil || !previousExpiry.Equal(leaf.NotAfter) {
+		domain.ExpiryWarningSentAt = nil
+		// a later expiry on a domain that already had a certificate means traefik renewed it
+		if previousExpiry != nil && leaf.NotAfter.After(*previousExpiry) && domain.SslProvider == models.SSLProvider {
+			domain.LastRenewalAttempt = &now
+		}
+	}
+
+	remaining := leaf.NotAfter.Sub(now)
+	domain.SslStatus = models.SSLStatusActive
+	domain.RenewalError = nil
+	switch {
+	case remaining <= 0:
+		domain.SslStatus = models.SSLStatusExpired
+	case probeErr != nil:
+		reason := probeErr.Error()
+		domain.SslStatus = models.SSLStatusFailed
+		domain.RenewalError = &reason
+	case domain.SslProvider == models.SSLProvider && remaining < renewalOverdue:
+		reason := fmt.Sprintf("Let's Encrypt certificate was not renewed, it expires in %d days", daysLeft(remaining))
+		domain.RenewalError = &reason
+	}
+
+	if domain.SslStatus == models.SSLStatusExpired {
+		if previousStatus != models.SSLStatusExpired {
+			warn(domain, models.PriorityUrgent, fmt.Sprintf("SSL certificate for %s has expired", domain.Domain),
+				fmt.Sprintf("The certificate for %s expired on %s. Visitors will see a security warning until it is replaced.",
+					domain.Domain, leaf.NotAfter.Format("Jan 2, 2006")))
+		}
+	} else if warningDays > 0 && remaining <= time.Duration(warningDays)*24*time.Hour && domain.ExpiryWarningSentAt == nil {
+		message := fmt.Sprintf("The certificate for %s expires in %d days, on %s.", domain.Domain, daysLeft(remaining), leaf.NotAfter.Format("Jan 2, 2006"))
+		if domain.SslProvider == models.SSLProviderCustom {
+			message += " Upload a renewed certificate before then."
+		} else if domain.RenewalError != nil {
+			message += " Let's Encrypt has not renewed it yet, check that the domain still points to this server."
+		} else {
+			message += " Traefik renews it automatically 30 days before it expires."
+		}
+		warn(domain, models.PriorityHigh, fmt.Sprintf("SSL certificate for %s expires soon", domain.Domain), message)
+		domain.ExpiryWarningSentAt = &now
+	}
+
+	if previousStatus != domain.SslStatus {
+		log.Info().
+			Str("domain", domain.Domain).
+			Str("from", string(previousStatus)).
+			Str("to", string(domain.SslStatus)).
+			Msg("SSL status changed")
+	}
+	return domain.UpdateCertificateStatus()
+}
+
+// the certificate covering the host that expires last and the resolver that obtained it,
+// traefik keeps serving the newest one
+func latestCertificateFor(host string, certificates map[string][]*x509.Certificate) (*x509.Certificate, string) {
+	var latest *x509.Certificate
+	var latestResolver string
+	for resolver, certs := range certificates {
+		for _, cert := range certs {
+			if cert.VerifyHostname(host) != nil {
+				continue
+			}
+			if latest == nil || cert.NotAfter.After(latest.NotAfter) {
+				latest, latestResolver = cert, resolver
+			}
+		}
+	}
+	return latest, latestResolver
+}
+
+func warn(domain *models.Domain, priority models.NotificationPriority, title, message string) {
+	app, err := models.GetApplicationByID(domain.AppID)
+	if err != nil || app == nil {
+		return
+	}
+	event := notify.AppEvent(app, models.NotificationSSLExpiryWarning, priority, title, message)
+	event.Metadata["domain"] = domain.Domain
+	event.Metadata["domainId"] = domain.ID
+	event.Metadata["expiresAt"] = domain.ExpiresAt
+	notify.Send(event)
+}
+
+func daysLeft(remaining time.Duration) int {
+	return int(remaining.Hours() / 24)
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// https entrypoint of traefik on this host
+	traefikHTTPSAddr = "127.0.0.1:443"
+	probeTimeout     = 10 * time.Second
+)
+
+// parses an uploaded pem certificate chain and private key and checks that they belong together,
+// that the certificate is currently valid and that it covers every given host
+func ValidateCertificate(certPEM, keyPEM string, hosts ...string) (*x509.Certificate, error) {
+	pair, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate or key: %w", err)
+	}
+
+	// the first certificate of the chain is the one served for the domain
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %w", err)
+	}
+
+	now := time.Now()
+	if now.Before(leaf.NotBefore) {
+		return nil, fmt.Errorf("certificate is not valid until %s", leaf.NotBefore.Format(time.RFC3339))
+	}
+	if now.After(leaf.NotAfter) {
+		return nil, fmt.Errorf("certificate expired on %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	for _, host := range hosts {
+		if err := leaf.VerifyHostname(host); err != nil {
+			return nil, fmt.Errorf("certificate does not cover %s", host)
+		}
+	}
+	return leaf, nil
+}
+
+// first certificate of a pem chain
+func ParseCertificatePEM(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no certificate found in PEM data")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// certificates traefik obtained, keyed by the acme resolver that requested them
+func ReadAcmeCertificates() (map[string][]*x509.Certificate, error) {
+	data, err := os.ReadFile(filepath.Join(TraefikStaticDir, TraefikAcmeFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var storage map[string]struct {
+		Certificates []struct {
+			// pem chain, base64 encoded in the file
+			Certificate []byte `json:"certificate"`
+		} `json:"Certificates"`
+	}
+	if err := json.Unmarshal(data, &storage); err != nil {
+		return nil, fmt.Errorf("failed to parse acme storage: %w", err)
+	}
+
+	certificates := map[string][]*x509.Certificate{}
+	for resolver, entry := range storage {
+		for _, cert := range entry.Certificates {
+			leaf, err := ParseCertificatePEM(string(cert.Certificate))
+			if err != nil {
+				continue
+			}
+			certificates[resolver] = append(certificates[resolver], leaf)
+		}
+	}
+	return certificates, nil
+}
+
+// connects to traefik with the host as sni and returns the certificate it serves, an error
+// means https isn't working for the host
+func ProbeCertificate(host string) (*x509.Certificate, error) {
+	dialer := &net.Dialer{Timeout: probeTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", traefikHTTPSAddr, &tls.Config{
+		ServerName: host,
+		// the served certificate is checked below, a self signed one should still be reported
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tls handshake failed: %w", err)
+	}
+	defer conn.Close()
+
+	peers := conn.ConnectionState().PeerCertificates
+	if len(peers) == 0 {
+		return nil, errors.New("no certificate served")
+	}
+	leaf := peers[0]
+	if err := leaf.VerifyHostname(host); err != nil {
+		return leaf, fmt.Errorf("served certificate does not cover %s", host)
+	}
+	return leaf, nil
+}
@@ -15,6 +15,7 @@ const (
 	StageSuccess     DeploymentStage = "success"
 	StageFailed      DeploymentStage = "failed"
 	StageRollingBack DeploymentStage = "rolling_back"
+	StageCancelled   DeploymentStage = "cancelled"
 )
 
 type DeploymentError struct {
@@ -58,6 +59,8 @@ func GetProgressFromStage(stage string) int {
 		return 100
 	case StageFailed:
 		return 0
+	case StageCancelled:
+		return 0
 	default:
 		return 0
 	}
@@ -79,6 +82,8 @@ func GetStageMessage(stage string) string {
 		return "Deployment failed"
 	case StageRollingBack:
 		return "Rolling back to previous version"
+	case StageCancelled:
+		return "Deployment cancelled"
 	default:
 		return "Unknown stage"
 	}
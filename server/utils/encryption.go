@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/corecollectives/mist/constants"
+)
+
+// secrets are stored as encryptedPrefix + base64(nonce | ciphertext), values without
+// the prefix were written before encryption was added and are returned as they are
+const encryptedPrefix = "enc:v1:"
+
+var (
+	encryptionKey     []byte
+	encryptionKeyErr  error
+	encryptionKeyOnce sync.Once
+)
+
+// the key lives next to the database instead of inside it, so a copy of the
+// database alone is not enough to read the stored credentials
+func loadEncryptionKey() ([]byte, error) {
+	encryptionKeyOnce.Do(func() {
+		keyPath := filepath.Join(constants.Constants["RootPath"].(string), "secret.key")
+
+		data, err := os.ReadFile(keyPath)
+		if err == nil {
+			key, decErr := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+			if decErr != nil || len(key) != 32 {
+				encryptionKeyErr = fmt.Errorf("invalid encryption key in %s", keyPath)
+				return
+			}
+			encryptionKey = key
+			return
+		}
+		if !os.IsNotExist(err) {
+			encryptionKeyErr = fmt.Errorf("failed to read encryption key: %w", err)
+			return
+		}
+
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			encryptionKeyErr = fmt.Errorf("failed to generate encryption key: %w", err)
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(keyPath), 0o755); err != nil {
+			encryptionKeyErr = fmt.Errorf("failed to create key directory: %w", err)
+			return
+		}
+		if err := os.WriteFile(keyPath, []byte(base64.StdEncoding.EncodeToString(key)), 0o600); err != nil {
+			encryptionKeyErr = fmt.Errorf("failed to write encryption key: %w", err)
+			return
+		}
+		encryptionKey = key
+	})
+	return encryptionKey, encryptionKeyErr
+}
+
+func EncryptSecret(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	key, err := loadEncryptionKey()
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func DecryptSecret(value string) (string, error) {
+	if !IsEncryptedSecret(value) {
+		return value, nil
+	}
+	key, err := loadEncryptionKey()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode secret: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted secret is too short")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func IsEncryptedSecret(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/rs/zerolog/log"
@@ -17,19 +18,46 @@ const (
 	TraefikDynamicFile = "dynamic.yml"
 	TraefikStaticDir   = "/opt/mist"
 	TraefikStaticFile  = "traefik-static.yml"
+	// acme storage of the le resolver, mounted next to the static config
+	TraefikAcmeFile = "letsencrypt/acme.json"
+	// credentials of the dns provider, loaded by the traefik service
+	TraefikDNSEnvFile = "traefik-dns.env"
+
+	// resolver for the http-01 challenge, always configured
+	DefaultCertResolver = "le"
+	// resolver for the dns-01 challenge, configured along with a dns provider
+	DNSCertResolver = "le-dns"
+
+	// where TraefikConfigDir is mounted inside the traefik container
+	traefikContainerConfigDir = "/etc/traefik/dynamic"
+	traefikCertsDir           = "certs"
 )
 
-func InitializeTraefikConfig(wildcardDomain *string, mistAppName string) error {
-	return GenerateDynamicConfig(wildcardDomain, mistAppName)
+// an uploaded certificate served by traefik for the hosts it covers
+type TLSCertificate struct {
+	// file name the pair is stored under
+	Name        string
+	Certificate string
+	Key         string
 }
 
-func GenerateDynamicConfig(wildcardDomain *string, mistAppName string) error {
+func InitializeTraefikConfig(wildcardDomain *string, mistAppName string, dnsChallenge bool, certificates []TLSCertificate) error {
+	return GenerateDynamicConfig(wildcardDomain, mistAppName, dnsChallenge, certificates)
+}
+
+// dnsChallenge serves the dashboard with the wildcard certificate of the dns resolver
+func GenerateDynamicConfig(wildcardDomain *string, mistAppName string, dnsChallenge bool, certificates []TLSCertificate) error {
 	if err := os.MkdirAll(TraefikConfigDir, 0755); err != nil {
 		return fmt.Errorf("failed to create traefik config directory: %w", err)
 	}
 
+	certFiles, err := writeCertificates(certificates)
+	if err != nil {
+		return err
+	}
+
 	dynamicConfigPath := filepath.Join(TraefikConfigDir, TraefikDynamicFile)
-	content := generateDynamicYAML(wildcardDomain, mistAppName)
+	content := generateDynamicYAML(wildcardDomain, mistAppName, dnsChallenge, certFiles)
 
 	if err := os.WriteFile(dynamicConfigPath, []byte(content), 0644); err != nil {
 		return fmt.Errorf("failed to write dynamic config: %w", err)
@@ -42,7 +70,39 @@ func GenerateDynamicConfig(wildcardDomain *string, mistAppName string) error {
 	return nil
 }
 
-func generateDynamicYAML(wildcardDomain *string, mistAppName string) string {
+// rewrites the certs directory with the given pairs, so removed certificates don't linger.
+// returns the cert and key paths as seen from inside the traefik container
+func writeCertificates(certificates []TLSCertificate) ([][2]string, error) {
+	certsDir := filepath.Join(TraefikConfigDir, traefikCertsDir)
+	if err := os.RemoveAll(certsDir); err != nil {
+		return nil, fmt.Errorf("failed to clear certificates directory: %w", err)
+	}
+	if len(certificates) == 0 {
+		return nil, nil
+	}
+	if err := os.MkdirAll(certsDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create certificates directory: %w", err)
+	}
+
+	var files [][2]string
+	for _, cert := range certificates {
+		certFile := cert.Name + ".crt"
+		keyFile := cert.Name + ".key"
+		if err := os.WriteFile(filepath.Join(certsDir, certFile), []byte(cert.Certificate), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write certificate %s: %w", cert.Name, err)
+		}
+		if err := os.WriteFile(filepath.Join(certsDir, keyFile), []byte(cert.Key), 0600); err != nil {
+			return nil, fmt.Errorf("failed to write key %s: %w", cert.Name, err)
+		}
+		files = append(files, [2]string{
+			path.Join(traefikContainerConfigDir, traefikCertsDir, certFile),
+			path.Join(traefikContainerConfigDir, traefikCertsDir, keyFile),
+		})
+	}
+	return files, nil
+}
+
+func generateDynamicYAML(wildcardDomain *string, mistAppName string, dnsChallenge bool, certFiles [][2]string) string {
 	var b strings.Builder
 
 	b.WriteString(`http:
@@ -50,20 +110,29 @@ func generateDynamicYAML(wildcardDomain *string, mistAppName string) string {
 `)
 
 	var mistDomain string
-	if wildcardDomain != nil && *wildcardDomain != "" {
-		domain := strings.TrimPrefix(*wildcardDomain, "*")
+	if wildcardDomain != nil && strings.TrimSpace(*wildcardDomain) != "" {
+		domain := strings.TrimPrefix(strings.TrimSpace(*wildcardDomain), "*")
 		domain = strings.TrimPrefix(domain, ".")
 
 		mistDomain = mistAppName + "." + domain
 
+		tls := `
+      tls:
+        certResolver: ` + DefaultCertResolver
+		if dnsChallenge {
+			tls = `
+      tls:
+        certResolver: ` + DNSCertResolver + `
+        domains:
+          - main: "*.` + domain + `"`
+		}
+
 		b.WriteString(fmt.Sprintf(`
     mist-dashboard:
       rule: Host(`+"`%s`"+`)
       entryPoints:
         - websecure
-      service: mist-dashboard
-      tls:
-        certResolver: le
+      service: mist-dashboard%s
 
     mist-dashboard-http:
       rule: Host(`+"`%s`"+`)
@@ -72,7 +141,7 @@ func generateDynamicYAML(wildcardDomain *string, mistAppName string) string {
       middlewares:
         - https-redirect
       service: mist-dashboard
-`, mistDomain, mistDomain))
+`, mistDomain, tls, mistDomain))
 	}
 
 	b.WriteString(`
@@ -96,54 +165,36 @@ func generateDynamicYAML(wildcardDomain *string, mistAppName string) string {
         permanent: true
 `)
 
+	if len(certFiles) > 0 {
+		b.WriteString(`
+tls:
+  certificates:
+`)
+		for _, files := range certFiles {
+			b.WriteString(fmt.Sprintf(`    - certFile: %s
+      keyFile: %s
+`, files[0], files[1]))
+		}
+	}
+
 	return b.String()
 }
 
 func ChangeLetsEncryptEmail(email string) error {
 	staticConfigPath := path.Join(TraefikStaticDir, TraefikStaticFile)
 
-	content, err := os.ReadFile(staticConfigPath)
+	config, err := readStaticConfig()
 	if err != nil {
-		return fmt.Errorf("failed to read traefik-static.yml: %w", err)
-	}
-
-	var config yaml.Node
-	if err := yaml.Unmarshal(content, &config); err != nil {
-		return fmt.Errorf("failed to parse traefik-static.yml: %w", err)
+		return err
 	}
 
+	// every resolver registers with let's encrypt, the http and the dns one
 	emailUpdated := false
-	if len(config.Content) > 0 {
-		rootNode := config.Content[0]
-		for i := 0; i < len(rootNode.Content); i += 2 {
-			if rootNode.Content[i].Value == "certificatesResolvers" && i+1 < len(rootNode.Content) {
-				certResolvers := rootNode.Content[i+1]
-				for j := 0; j < len(certResolvers.Content); j += 2 {
-					if certResolvers.Content[j].Value == "le" && j+1 < len(certResolvers.Content) {
-						leNode := certResolvers.Content[j+1]
-						for k := 0; k < len(leNode.Content); k += 2 {
-							if leNode.Content[k].Value == "acme" && k+1 < len(leNode.Content) {
-								acmeNode := leNode.Content[k+1]
-								for l := 0; l < len(acmeNode.Content); l += 2 {
-									if acmeNode.Content[l].Value == "email" && l+1 < len(acmeNode.Content) {
-										acmeNode.Content[l+1].Value = email
-										emailUpdated = true
-										break
-									}
-								}
-							}
-							if emailUpdated {
-								break
-							}
-						}
-					}
-					if emailUpdated {
-						break
-					}
-				}
-			}
-			if emailUpdated {
-				break
+	if resolvers := mappingValue(config.Content[0], "certificatesResolvers"); resolvers != nil {
+		for i := 1; i < len(resolvers.Content); i += 2 {
+			if emailNode := mappingValue(mappingValue(resolvers.Content[i], "acme"), "email"); emailNode != nil {
+				emailNode.Value = email
+				emailUpdated = true
 			}
 		}
 	}
@@ -152,13 +203,8 @@ func ChangeLetsEncryptEmail(email string) error {
 		return fmt.Errorf("email field not found in traefik-static.yml")
 	}
 
-	updatedContent, err := yaml.Marshal(&config)
-	if err != nil {
-		return fmt.Errorf("failed to marshal updated config: %w", err)
-	}
-
-	if err := os.WriteFile(staticConfigPath, updatedContent, 0644); err != nil {
-		return fmt.Errorf("failed to write traefik-static.yml: %w", err)
+	if err := writeStaticConfig(config); err != nil {
+		return err
 	}
 
 	log.Info().
@@ -173,6 +219,138 @@ func ChangeLetsEncryptEmail(email string) error {
 	return nil
 }
 
+// adds the dns-01 resolver for the given provider to the static config and writes the provider's
+// credentials to the env file the traefik service loads. an empty provider removes the resolver.
+// traefik has to be recreated for either to apply
+func ConfigureDNSChallenge(provider string, env map[string]string) error {
+	config, err := readStaticConfig()
+	if err != nil {
+		return err
+	}
+
+	root := config.Content[0]
+	resolvers := mappingValue(root, "certificatesResolvers")
+	if resolvers == nil {
+		return fmt.Errorf("certificatesResolvers not found in traefik-static.yml")
+	}
+	email := mappingValue(mappingValue(mappingValue(resolvers, DefaultCertResolver), "acme"), "email")
+	if email == nil {
+		return fmt.Errorf("email field not found in traefik-static.yml")
+	}
+
+	removeMappingKey(resolvers, DNSCertResolver)
+	if provider != "" {
+		var resolver yaml.Node
+		err := resolver.Encode(map[string]interface{}{
+			"acme": map[string]interface{}{
+				"email": email.Value,
+				// shared with the http resolver, traefik keys certificates by resolver
+				"storage": "/letsencrypt/acme.json",
+				"dnsChallenge": map[string]interface{}{
+					"provider": provider,
+					// public resolvers see the challenge record before split horizon ones do
+					"resolvers": []string{"1.1.1.1:53", "8.8.8.8:53"},
+				},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to encode dns resolver: %w", err)
+		}
+		resolvers.Content = append(resolvers.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: DNSCertResolver}, &resolver)
+	}
+
+	if err := writeDNSEnv(env); err != nil {
+		return err
+	}
+	if err := writeStaticConfig(config); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("provider", provider).
+		Msg("Updated DNS challenge resolver in traefik-static.yml")
+	return nil
+}
+
+func HasCertResolver(name string) (bool, error) {
+	config, err := readStaticConfig()
+	if err != nil {
+		return false, err
+	}
+	return mappingValue(mappingValue(config.Content[0], "certificatesResolvers"), name) != nil, nil
+}
+
+// credentials in the compose env file format, readable by root only
+func writeDNSEnv(env map[string]string) error {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		// single quoted values are taken literally
+		b.WriteString(fmt.Sprintf("%s='%s'\n", key, env[key]))
+	}
+
+	envPath := path.Join(TraefikStaticDir, TraefikDNSEnvFile)
+	if err := os.WriteFile(envPath, []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", TraefikDNSEnvFile, err)
+	}
+	return os.Chmod(envPath, 0600)
+}
+
+func readStaticConfig() (*yaml.Node, error) {
+	content, err := os.ReadFile(path.Join(TraefikStaticDir, TraefikStaticFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read traefik-static.yml: %w", err)
+	}
+
+	var config yaml.Node
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse traefik-static.yml: %w", err)
+	}
+	if len(config.Content) == 0 || config.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("traefik-static.yml is empty")
+	}
+	return &config, nil
+}
+
+func writeStaticConfig(config *yaml.Node) error {
+	updatedContent, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal updated config: %w", err)
+	}
+
+	if err := os.WriteFile(path.Join(TraefikStaticDir, TraefikStaticFile), updatedContent, 0644); err != nil {
+		return fmt.Errorf("failed to write traefik-static.yml: %w", err)
+	}
+	return nil
+}
+
+// value of a key in a yaml mapping, nil when the node isn't a mapping or doesn't have the key
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func removeMappingKey(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
+
 func RestartTraefik() error {
 	log.Info().Msg("Restarting Traefik container...")
 
@@ -194,3 +372,23 @@ func RestartTraefik() error {
 
 	return nil
 }
+
+// recreates the traefik container so it picks up a changed env file, a restart keeps the old
+// environment
+func RecreateTraefik() error {
+	log.Info().Msg("Recreating Traefik container...")
+
+	cmd := exec.Command("docker", "compose", "-f", "/opt/mist/traefik-compose.yml", "up", "-d", "--force-recreate", "traefik")
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("output", string(output)).
+			Msg("Failed to recreate Traefik container")
+		return fmt.Errorf("docker compose up failed: %w", err)
+	}
+
+	log.Info().Msg("Traefik container recreated successfully")
+	return nil
+}
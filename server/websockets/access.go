@@ -0,0 +1,98 @@
+package websockets
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/models"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// open streams re-check access this often, so logging out, revoking a token or removing
+// someone from a project closes their streams shortly after
+const accessCheckInterval = 15 * time.Second
+
+// checks that the user of the request is a member of the project, writing the error response
+// when not. called before upgrading so clients get a proper status code
+func AuthorizeProject(w http.ResponseWriter, r *http.Request, projectID int64) bool {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		http.Error(w, "Not logged in", http.StatusUnauthorized)
+		return false
+	}
+	isUserMember, err := models.HasUserAccessToProject(userInfo.ID, projectID)
+	if err != nil {
+		http.Error(w, "Failed to verify access", http.StatusInternalServerError)
+		return false
+	}
+	if !isUserMember {
+		http.Error(w, "You do not have access to this application", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// loads the app of the appId query parameter and checks the user can access it
+func authorizeApp(w http.ResponseWriter, r *http.Request) (*models.App, bool) {
+	appIDStr := r.URL.Query().Get("appId")
+	if appIDStr == "" {
+		http.Error(w, "appId is required", http.StatusBadRequest)
+		return nil, false
+	}
+
+	appID, err := strconv.ParseInt(appIDStr, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid appId", http.StatusBadRequest)
+		return nil, false
+	}
+
+	app, err := models.GetApplicationByID(appID)
+	if err != nil || app == nil {
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return nil, false
+	}
+	if !AuthorizeProject(w, r, app.ProjectID) {
+		return nil, false
+	}
+	return app, true
+}
+
+// closes the connection and calls cancel once the request's credentials or, when projectID is
+// set, its user's membership of the project are gone. returns when ctx is done
+func WatchAccess(ctx context.Context, r *http.Request, conn *websocket.Conn, projectID *int64, cancel context.CancelFunc) {
+	userInfo, _ := middleware.GetUser(r)
+
+	ticker := time.NewTicker(accessCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		allowed := middleware.StillAuthenticated(r)
+		if allowed && projectID != nil && userInfo != nil {
+			isUserMember, err := models.HasUserAccessToProject(userInfo.ID, *projectID)
+			// a failed check is not a revocation, try again next time
+			allowed = err != nil || isUserMember
+		}
+		if allowed {
+			continue
+		}
+
+		if userInfo != nil {
+			log.Info().Int64("user_id", userInfo.ID).Str("path", r.URL.Path).Msg("Access revoked, closing websocket")
+		}
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "access revoked"),
+			time.Now().Add(time.Second))
+		cancel()
+		conn.Close()
+		return
+	}
+}
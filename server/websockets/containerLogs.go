@@ -6,11 +6,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/corecollectives/mist/docker"
-	"github.com/corecollectives/mist/models"
 	"github.com/gorilla/websocket"
 	"github.com/moby/moby/client"
 	"github.com/rs/zerolog/log"
@@ -29,23 +27,11 @@ var containerLogsUpgrader = websocket.Upgrader{
 }
 
 func ContainerLogsHandler(w http.ResponseWriter, r *http.Request) {
-	appIDStr := r.URL.Query().Get("appId")
-	if appIDStr == "" {
-		http.Error(w, "appId is required", http.StatusBadRequest)
-		return
-	}
-
-	appID, err := strconv.ParseInt(appIDStr, 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid appId", http.StatusBadRequest)
-		return
-	}
-
-	app, err := models.GetApplicationByID(appID)
-	if err != nil {
-		http.Error(w, "Application not found", http.StatusNotFound)
+	app, ok := authorizeApp(w, r)
+	if !ok {
 		return
 	}
+	appID := app.ID
 
 	conn, err := containerLogsUpgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -88,6 +74,7 @@ func ContainerLogsHandler(w http.ResponseWriter, r *http.Request) {
 			"container": containerName,
 			"state":     status.State,
 			"status":    status.Status,
+			"health":    status.Health,
 		},
 	})
 
@@ -105,6 +92,8 @@ func ContainerLogsHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	go WatchAccess(ctx, r, conn, &app.ProjectID, cancel)
+
 	type logMessage struct {
 		line       string
 		streamType string
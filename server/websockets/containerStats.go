@@ -5,11 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/corecollectives/mist/docker"
-	"github.com/corecollectives/mist/models"
 	"github.com/gorilla/websocket"
 	"github.com/moby/moby/api/types/container"
 	"github.com/moby/moby/client"
@@ -41,23 +39,11 @@ var containerStatsUpgrader = websocket.Upgrader{
 }
 
 func ContainerStatsHandler(w http.ResponseWriter, r *http.Request) {
-	appIDStr := r.URL.Query().Get("appId")
-	if appIDStr == "" {
-		http.Error(w, "appId is required", http.StatusBadRequest)
-		return
-	}
-
-	appID, err := strconv.ParseInt(appIDStr, 10, 64)
-	if err != nil {
-		http.Error(w, "invalid appID", http.StatusBadRequest)
-		return
-	}
-
-	app, err := models.GetApplicationByID(appID)
-	if err != nil {
-		http.Error(w, "application not found", http.StatusNotFound)
+	app, ok := authorizeApp(w, r)
+	if !ok {
 		return
 	}
+	appID := app.ID
 
 	cli, err := client.New(client.FromEnv)
 	if err != nil {
@@ -107,6 +93,7 @@ func ContainerStatsHandler(w http.ResponseWriter, r *http.Request) {
 			"container": containerName,
 			"state":     status.State,
 			"status":    status.Status,
+			"health":    status.Health,
 		},
 	})
 
@@ -124,6 +111,8 @@ func ContainerStatsHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	go WatchAccess(ctx, r, conn, &app.ProjectID, cancel)
+
 	statsChan := make(chan *ContainerStatsData, 10)
 	errChan := make(chan error, 1)
 
@@ -182,6 +171,32 @@ func ContainerStatsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
+	// health changes are pushed as status events so the ui doesn't have to poll
+	healthChan := make(chan *docker.ContainerStatus, 1)
+	go func() {
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+
+		lastHealth := status.Health
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				current, err := docker.GetContainerStatus(containerName)
+				if err != nil || current.Health == lastHealth {
+					continue
+				}
+				lastHealth = current.Health
+				select {
+				case healthChan <- current:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
 	go func() {
 		ticker := time.NewTicker(30 * time.Second)
 		defer ticker.Stop()
@@ -229,6 +244,20 @@ func ContainerStatsHandler(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 
+		case current := <-healthChan:
+			if err := conn.WriteJSON(ContainerStatsEvent{
+				Type:      "status",
+				Timestamp: time.Now().Format(time.RFC3339),
+				Data: map[string]interface{}{
+					"container": containerName,
+					"state":     current.State,
+					"status":    current.Status,
+					"health":    current.Health,
+				},
+			}); err != nil {
+				return
+			}
+
 		case statsData, ok := <-statsChan:
 			if !ok {
 				conn.WriteJSON(ContainerStatsEvent{
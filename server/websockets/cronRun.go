@@ -0,0 +1,135 @@
+package websockets
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/corecollectives/mist/api/middleware"
+	"github.com/corecollectives/mist/models"
+	"github.com/corecollectives/mist/scheduler"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+type CronRunEvent struct {
+	Type      string                 `json:"type"`
+	Timestamp string                 `json:"timestamp"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+var cronRunUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     CheckOriginWithSettings,
+}
+
+// runs a cron job right away and streams its output. the run is recorded like a scheduled
+// one and keeps going when the client disconnects
+func CronRunHandler(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middleware.GetUser(r)
+	if !ok {
+		http.Error(w, "Not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	cronID, err := strconv.ParseInt(r.URL.Query().Get("cronId"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid cronId", http.StatusBadRequest)
+		return
+	}
+
+	cron, err := models.GetCronByID(cronID)
+	if err != nil {
+		http.Error(w, "Cron job not found", http.StatusNotFound)
+		return
+	}
+	app, err := models.GetApplicationByID(cron.AppID)
+	if err != nil || app == nil {
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	}
+	if !AuthorizeProject(w, r, app.ProjectID) {
+		return
+	}
+
+	conn, err := cronRunUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to upgrade websocket connection for cron run")
+		return
+	}
+	defer conn.Close()
+
+	// closing the stream doesn't stop the run, output is still recorded
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go WatchAccess(ctx, r, conn, &app.ProjectID, cancel)
+
+	out := &cronRunWriter{conn: conn}
+
+	run, err := scheduler.Run(cron, scheduler.RunOptions{
+		Trigger:     models.CronRunTriggerManual,
+		TriggeredBy: &userInfo.ID,
+		Output:      out,
+		OnStart: func(run *models.CronRun) {
+			models.LogUserAudit(userInfo.ID, "run", "cron", &cron.ID, map[string]interface{}{
+				"app_id":  cron.AppID,
+				"name":    cron.Name,
+				"command": cron.Command,
+			})
+			out.send("started", map[string]interface{}{
+				"runId":   run.ID,
+				"command": run.Command,
+				"runMode": run.RunMode,
+			})
+		},
+	})
+	if err != nil {
+		out.send("error", map[string]interface{}{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	out.send("finished", map[string]interface{}{
+		"runId":        run.ID,
+		"status":       run.Status,
+		"exitCode":     run.ExitCode,
+		"errorMessage": run.ErrorMessage,
+		"duration":     run.Duration,
+	})
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+// sends output chunks as events. write errors are dropped, a closed tab must not fail the run
+type cronRunWriter struct {
+	conn   *websocket.Conn
+	mu     sync.Mutex
+	closed bool
+}
+
+func (c *cronRunWriter) Write(p []byte) (int, error) {
+	c.send("output", map[string]interface{}{
+		"output": string(p),
+	})
+	return len(p), nil
+}
+
+func (c *cronRunWriter) send(eventType string, data map[string]interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	err := c.conn.WriteJSON(CronRunEvent{
+		Type:      eventType,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data:      data,
+	})
+	if err != nil {
+		c.closed = true
+	}
+}
@@ -1,6 +1,7 @@
 package websockets
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"sync"
@@ -35,6 +36,10 @@ func StatWsHandler(w http.ResponseWriter, r *http.Request) {
 		conn.Close()
 	}()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go WatchAccess(ctx, r, conn, nil, cancel)
+
 	for {
 		if _, _, err := conn.ReadMessage(); err != nil {
 			break
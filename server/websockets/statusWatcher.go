@@ -2,9 +2,11 @@ package websockets
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"time"
 
+	"github.com/corecollectives/mist/docker"
 	"github.com/corecollectives/mist/models"
 	"github.com/corecollectives/mist/utils"
 )
@@ -22,6 +24,7 @@ type StatusUpdate struct {
 	Progress     int    `json:"progress"`
 	Message      string `json:"message"`
 	ErrorMessage string `json:"error_message,omitempty"`
+	Health       string `json:"health,omitempty"`
 }
 
 type LogUpdate struct {
@@ -71,6 +74,8 @@ func WatchDeploymentStatus(ctx context.Context, depID int64, events chan<- Deplo
 	var lastStatus models.DeploymentStatus
 	var lastStage string
 	var lastProgress int
+	var lastHealth string
+	ticks := 0
 
 	for {
 		select {
@@ -82,10 +87,19 @@ func WatchDeploymentStatus(ctx context.Context, depID int64, events chan<- Deplo
 				continue
 			}
 
-			if dep.Status != lastStatus || dep.Stage != lastStage || dep.Progress != lastProgress {
+			// health is only polled while the container is being started and once the
+			// deployment finished, and at most every 2 seconds to spare the docker daemon
+			ticks++
+			health := lastHealth
+			if (dep.Stage == "deploying" && ticks%4 == 0) || dep.Status == "success" {
+				health = deploymentHealth(dep)
+			}
+
+			if dep.Status != lastStatus || dep.Stage != lastStage || dep.Progress != lastProgress || health != lastHealth {
 				lastStatus = dep.Status
 				lastStage = dep.Stage
 				lastProgress = dep.Progress
+				lastHealth = health
 
 				errMsg := ""
 				if dep.ErrorMessage != nil {
@@ -105,15 +119,29 @@ func WatchDeploymentStatus(ctx context.Context, depID int64, events chan<- Deplo
 						Progress:     dep.Progress,
 						Message:      utils.GetStageMessage(dep.Stage),
 						ErrorMessage: errMsg,
+						Health:       health,
 					},
 				}:
 				}
 			}
 
-			if dep.Status == "success" || dep.Status == "failed" {
+			if dep.Status == "success" || dep.Status == "failed" || dep.Status == "cancelled" {
 				time.Sleep(1 * time.Second)
 				return
 			}
 		}
 	}
 }
+
+// a blue-green deploy health checks the new container under its -next name before it takes over
+func deploymentHealth(dep *models.Deployment) string {
+	containerName := fmt.Sprintf("app-%d", dep.AppID)
+	if dep.Stage == "deploying" && docker.ContainerExists(containerName+"-next") {
+		containerName += "-next"
+	}
+	status, err := docker.GetContainerStatus(containerName)
+	if err != nil {
+		return ""
+	}
+	return status.Health
+}
@@ -45,6 +45,8 @@ func SystemLogsHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	go WatchAccess(ctx, r, conn, nil, cancel)
+
 	logChan := make(chan string, 100)
 	errChan := make(chan error, 1)
 
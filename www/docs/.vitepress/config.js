@@ -48,6 +48,7 @@ export default defineConfig({
           items: [
             { text: 'Logs', link: '/guide/logs' },
             { text: 'System Metrics', link: '/guide/metrics' },
+            { text: 'Notifications', link: '/guide/notifications' },
             { text: 'Audit Logs', link: '/guide/audit-logs' }
           ]
         },
@@ -67,8 +68,7 @@ export default defineConfig({
         {
           text: 'Coming Soon',
           items: [
-            { text: 'Rollback Deployments', link: '/guide/rollback' },
-            { text: 'Notifications', link: '/guide/notifications' }
+            { text: 'Rollback Deployments', link: '/guide/rollback' }
           ]
         }
       ],
@@ -85,6 +85,7 @@ export default defineConfig({
             { text: 'Domains', link: '/api/domains' },
             { text: 'Users', link: '/api/users' },
             { text: 'GitHub Integration', link: '/api/github' },
+            { text: 'Notifications', link: '/api/notifications' },
             { text: 'WebSockets', link: '/api/websockets' }
           ]
         }